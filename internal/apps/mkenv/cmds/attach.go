@@ -9,6 +9,7 @@ import (
 	"github.com/0xa1bed0/mkenv/internal/dockerclient"
 	"github.com/0xa1bed0/mkenv/internal/logs"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/supervisor"
 	"github.com/0xa1bed0/mkenv/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -83,7 +84,7 @@ func newAttachCmd() *cobra.Command {
 			}
 
 			if len(containers) == 1 {
-				return dockerClient.AttachToRunning(rt.Ctx(), containers[0].ContainerID, containers[0].Project, rt.Term())
+				return attachToContainer(rt, dockerClient, containers[0].ContainerID, containers[0].Project)
 			}
 
 			selected, err := logs.PromptSelectOne("Select container to attach to", ui.ToSelectOptions(containers))
@@ -100,9 +101,27 @@ func newAttachCmd() *cobra.Command {
 				}
 			}
 
-			return dockerClient.AttachToRunning(rt.Ctx(), selected.OptionID(), displayName, rt.Term())
+			return attachToContainer(rt, dockerClient, selected.OptionID(), displayName)
 		},
 	}
 
 	return cmd
 }
+
+// attachToContainer attaches the terminal to the container. If the project's supervisor is running,
+// the terminal is registered as its session so the container and port forwarding outlive other sessions.
+func attachToContainer(rt *runtime.Runtime, dockerClient *dockerclient.DockerClient, containerID, projectName string) error {
+	client, err := supervisor.Dial(rt.Ctx(), projectName)
+	if err != nil {
+		logs.Debugf("no supervisor for %s (%v). Attaching directly", projectName, err)
+		return dockerClient.AttachToRunning(rt.Ctx(), containerID, projectName, rt.Term())
+	}
+	defer client.Close()
+
+	client.HandleApprovals(rt.Term())
+	if _, err := client.Attach(rt.Ctx(), supervisor.SessionKindShell); err != nil {
+		return err
+	}
+
+	return dockerClient.AttachToRunning(rt.Ctx(), containerID, projectName, rt.Term())
+}
@@ -0,0 +1,254 @@
+package mkenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	"github.com/0xa1bed0/mkenv/internal/audit"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const (
+	auditOutputTable = ""
+	auditOutputJSON  = "json"
+)
+
+type auditOptions struct {
+	Since     string
+	Until     string
+	Port      int
+	Decision  string
+	Direction string
+	RunID     string
+	Output    string
+}
+
+func newAuditCmd() *cobra.Command {
+	opts := &auditOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "audit [PATH]",
+		Short: "Show network connections of the project's sandbox",
+		Long: `Show the network audit log of the project: every connection forwarded from the sandbox to the host,
+from the host to the sandbox and from the sandbox to the internet (egress mode), with bytes sent each way,
+the policy decision and, for sandbox-originated traffic, the process that opened it.
+
+If PATH is omitted, the current working directory is used.
+Use '--output json' to print the raw records, one JSON object per line.`,
+		Example: `  mkenv audit --since 1h
+  mkenv audit --decision deny --direction sandbox->host
+  mkenv audit ./api --port 5432 --since 2025-01-02T15:00:00Z`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running audit...")
+
+			switch opts.Output {
+			case auditOutputTable, auditOutputJSON:
+			default:
+				return fmt.Errorf("unknown output format %q (expected json)", opts.Output)
+			}
+
+			filter, err := opts.filter(time.Now())
+			if err != nil {
+				return err
+			}
+
+			rt := runtime.FromContext(cmd.Context())
+
+			pathArg := "."
+			if len(args) == 1 {
+				pathArg = args[0]
+			} else {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				pathArg = pwd
+			}
+
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			project, err := rt.ResolveProject(signalsCtx, pathArg, nil)
+			if err != nil {
+				return err
+			}
+
+			paths, err := hostappconfig.AuditLogPaths(project.Name())
+			if err != nil {
+				return err
+			}
+
+			records := []audit.Record{}
+			for _, path := range paths {
+				recs, err := audit.ReadFile(path, filter)
+				if err != nil {
+					logs.Warnf("can't read audit log %s: %v", path, err)
+					continue
+				}
+				for _, rec := range recs {
+					if opts.RunID == "" || rec.RunID == opts.RunID {
+						records = append(records, rec)
+					}
+				}
+			}
+			sort.SliceStable(records, func(i, j int) bool {
+				return records[i].Start.Before(records[j].Start)
+			})
+
+			if opts.Output == auditOutputJSON {
+				return renderAuditJSON(os.Stdout, records)
+			}
+
+			if len(records) == 0 {
+				fmt.Println("No connections found")
+				return nil
+			}
+
+			fmt.Println("")
+			renderAuditTable(os.Stdout, records)
+			fmt.Println("")
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.Since, "since", "", "Show connections open after this time (RFC 3339, YYYY-MM-DD or a duration ago, e.g. '1h')")
+	flags.StringVar(&opts.Until, "until", "", "Show connections open before this time (same formats as --since)")
+	flags.IntVar(&opts.Port, "port", 0, "Show connections to this port only")
+	flags.StringVar(&opts.Decision, "decision", "", "Show connections with this policy decision only: allow or deny")
+	flags.StringVar(&opts.Direction, "direction", "", "Show connections in this direction only: host->sandbox, sandbox->host or sandbox->internet")
+	flags.StringVar(&opts.RunID, "run", "", "Show connections of this run only")
+	flags.StringVarP(&opts.Output, "output", "o", "", "Output format: json")
+
+	return cmd
+}
+
+// filter validates the options and builds the audit filter. Relative times are resolved against now.
+func (ao *auditOptions) filter(now time.Time) (audit.Filter, error) {
+	filter := audit.Filter{
+		Port:      ao.Port,
+		Decision:  audit.Decision(ao.Decision),
+		Direction: audit.Direction(ao.Direction),
+	}
+
+	if ao.Port < 0 || ao.Port > 65535 {
+		return filter, fmt.Errorf("invalid port %d", ao.Port)
+	}
+
+	switch filter.Decision {
+	case "", audit.DecisionAllow, audit.DecisionDeny:
+	default:
+		return filter, fmt.Errorf("unknown decision %q (expected allow or deny)", ao.Decision)
+	}
+
+	switch filter.Direction {
+	case "", audit.DirectionHostToSandbox, audit.DirectionSandboxToHost, audit.DirectionSandboxToInternet:
+	default:
+		return filter, fmt.Errorf("unknown direction %q (expected host->sandbox, sandbox->host or sandbox->internet)", ao.Direction)
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(ao.Since, now); err != nil {
+		return filter, fmt.Errorf("invalid --since: %w", err)
+	}
+	if filter.Until, err = parseAuditTime(ao.Until, now); err != nil {
+		return filter, fmt.Errorf("invalid --until: %w", err)
+	}
+
+	return filter, nil
+}
+
+// parseAuditTime parses an RFC 3339 time, a local date or a duration before now.
+func parseAuditTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time or a duration", s)
+}
+
+func renderAuditTable(w io.Writer, records []audit.Record) {
+	table := ui.NewTable(
+		ui.Column{Header: "Start"},
+		ui.Column{Header: "Duration", Align: ui.AlignRight},
+		ui.Column{Header: "Direction"},
+		ui.Column{Header: "Target"},
+		ui.Column{Header: "Decision"},
+		ui.Column{Header: "To sandbox", Align: ui.AlignRight},
+		ui.Column{Header: "From sandbox", Align: ui.AlignRight},
+		ui.Column{Header: "Process", MaxWidth: 24},
+		ui.Column{Header: "Note", MaxWidth: 40},
+	)
+
+	for _, rec := range records {
+		target := fmt.Sprintf("%s/%d", rec.Proto, rec.Port)
+		if rec.Host != "" {
+			target = fmt.Sprintf("%s:%d", rec.Host, rec.Port)
+		}
+		process := ""
+		if rec.PID != 0 {
+			process = fmt.Sprintf("%s (%d)", rec.Cmd, rec.PID)
+		}
+		note := rec.Reason
+		if rec.Error != "" {
+			note = rec.Error
+		}
+		table.AddRow(
+			rec.Start.Local().Format(time.DateTime),
+			rec.End.Sub(rec.Start).Round(time.Millisecond).String(),
+			string(rec.Direction),
+			target,
+			string(rec.Decision),
+			formatBytes(rec.BytesToSandbox),
+			formatBytes(rec.BytesFromSandbox),
+			process,
+			note,
+		)
+	}
+
+	table.Render(w)
+}
+
+func renderAuditJSON(w io.Writer, records []audit.Record) error {
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,444 @@
+package mkenv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	"github.com/0xa1bed0/mkenv/internal/bricksengine"
+	"github.com/0xa1bed0/mkenv/internal/filesmanager"
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/ui"
+	"github.com/0xa1bed0/mkenv/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	bricksOutputTable = ""
+	bricksOutputJSON  = "json"
+)
+
+// brickSummary is a brick as printed by mkenv bricks.
+type brickSummary struct {
+	ID          bricksengine.BrickID      `json:"id"`
+	Description string                    `json:"description"`
+	Kinds       []string                  `json:"kinds"`
+	Source      string                    `json:"source"`
+	Detectable  bool                      `json:"detectable"` // a detector can propose the brick
+	Config      bricksengine.ConfigSchema `json:"config"`     // null if the brick doesn't declare its config
+}
+
+// brickDetection is the result of a detector as printed by mkenv bricks detect.
+type brickDetection struct {
+	Detector bricksengine.BrickID `json:"detector"` // brick the detector belongs to
+	Detected bool                 `json:"detected"`
+	BrickID  bricksengine.BrickID `json:"brick_id,omitempty"` // usually the detector's brick, plugins can propose others
+	Metadata map[string]string    `json:"metadata,omitempty"`
+	Evidence []string             `json:"evidence,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+func newBricksCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bricks",
+		Short: "Inspect the bricks environments are built from",
+	}
+
+	cmd.AddCommand(newBricksListCmd())
+	cmd.AddCommand(newBricksInfoCmd())
+	cmd.AddCommand(newBricksDetectCmd())
+
+	return cmd
+}
+
+type bricksOptions struct {
+	Output string
+}
+
+func newBricksListCmd() *cobra.Command {
+	opts := &bricksOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the bricks mkenv knows",
+		Long: fmt.Sprintf(`List built-in bricks, bricks defined in %s and mkenv-brick-* plugins on PATH
+with their kinds, description and whether mkenv detects them in projects.
+
+Bricks defined in .mkenv files are only known in their projects. Use 'mkenv bricks info <id>' for the config of a brick.`, hostappconfig.UserBricksPath()),
+		Example: `  mkenv bricks list
+  mkenv bricks list --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running bricks list...")
+
+			if err := checkBricksOutput(opts.Output); err != nil {
+				return err
+			}
+			if opts.Output != bricksOutputTable {
+				// logs go to stdout, keep it machine readable
+				restoreLogs := logs.Mute()
+				defer restoreLogs()
+			}
+
+			rt := runtime.FromContext(cmd.Context())
+
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			// there is no project, the policy of the current folder applies
+			pwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			registry := bricksengine.DefaultBricksRegistry
+			if err := registerHostBricks(signalsCtx, registry, pwd); err != nil {
+				return err
+			}
+
+			detectable := detectableBricks(registry)
+			summaries := []brickSummary{}
+			for _, id := range registry.ListBrickIDs() {
+				summary, err := summarizeBrick(registry, id, detectable)
+				if err != nil {
+					return err
+				}
+				summaries = append(summaries, summary)
+			}
+
+			if opts.Output == bricksOutputJSON {
+				return renderBricksJSON(os.Stdout, summaries)
+			}
+			renderBricksTable(os.Stdout, summaries)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Output format: json")
+
+	return cmd
+}
+
+func newBricksInfoCmd() *cobra.Command {
+	opts := &bricksOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "info <id>",
+		Short: "Print a brick and the bricks_config keys it accepts",
+		Long: fmt.Sprintf(`Print the description, kinds and source of a brick and the schema of its config:
+the keys bricks_config of .mkenv files, policies and '--brick-config' can set, their types, defaults and allowed values.
+
+Built-in bricks, bricks defined in %s and mkenv-brick-* plugins on PATH are known.`, hostappconfig.UserBricksPath()),
+		Example: `  mkenv bricks info nodejs
+  mkenv bricks info claude-code --output json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running bricks info...")
+
+			if err := checkBricksOutput(opts.Output); err != nil {
+				return err
+			}
+			if opts.Output != bricksOutputTable {
+				// logs go to stdout, keep it machine readable
+				restoreLogs := logs.Mute()
+				defer restoreLogs()
+			}
+
+			rt := runtime.FromContext(cmd.Context())
+
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			// there is no project, the policy of the current folder applies
+			pwd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			registry := bricksengine.DefaultBricksRegistry
+			if err := registerHostBricks(signalsCtx, registry, pwd); err != nil {
+				return err
+			}
+
+			summary, err := summarizeBrick(registry, bricksengine.BrickID(args[0]), detectableBricks(registry))
+			if err != nil {
+				return err
+			}
+
+			if opts.Output == bricksOutputJSON {
+				return renderBricksJSON(os.Stdout, summary)
+			}
+			renderBrickInfo(os.Stdout, summary)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Output format: json")
+
+	return cmd
+}
+
+func newBricksDetectCmd() *cobra.Command {
+	opts := &bricksOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "detect [PATH]",
+		Short: "Run brick detectors against a project",
+		Long: `Run every brick detector against the project and print the bricks they propose,
+the metadata they found (e.g. versions) and the files that made them propose the brick.
+
+Settings of .mkenv files (enabled_bricks, disabled_bricks, disable_auto) are not applied: the output is
+what auto-detection sees. The command exits with code 1 if a detector fails.
+
+If PATH is omitted, the current working directory is used.`,
+		Example: `  mkenv bricks detect
+  mkenv bricks detect ./services/api --output json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running bricks detect...")
+
+			if err := checkBricksOutput(opts.Output); err != nil {
+				return err
+			}
+			if opts.Output != bricksOutputTable {
+				// logs go to stdout, keep it machine readable
+				restoreLogs := logs.Mute()
+				defer restoreLogs()
+			}
+
+			rt := runtime.FromContext(cmd.Context())
+
+			pathArg := "."
+			if len(args) == 1 {
+				pathArg = args[0]
+			} else {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				pathArg = pwd
+			}
+
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			project, err := rt.ResolveProject(signalsCtx, pathArg, nil)
+			if err != nil {
+				return err
+			}
+			folderPtr, err := project.FolderPtr()
+			if err != nil {
+				return err
+			}
+
+			registry := bricksengine.DefaultBricksRegistry
+			if err := registerHostBricks(signalsCtx, registry, project.Path()); err != nil {
+				return err
+			}
+
+			detections := detectBricks(registry.AllDetectors(), folderPtr)
+
+			if opts.Output == bricksOutputJSON {
+				err = renderBricksJSON(os.Stdout, detections)
+			} else {
+				renderDetectionsTable(os.Stdout, detections)
+			}
+			if err != nil {
+				return err
+			}
+
+			for _, d := range detections {
+				if d.Error != "" {
+					return &runtime.ExitError{Code: 1}
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Output format: json")
+
+	return cmd
+}
+
+// detectBricks scans the project with every detector. A failing detector doesn't stop the others.
+func detectBricks(detectors []bricksengine.BrickDetector, folderPtr filesmanager.FileManager) []brickDetection {
+	out := make([]brickDetection, 0, len(detectors))
+	for _, d := range detectors {
+		detection := brickDetection{Detector: d.BrickInfo().ID()}
+		id, meta, err := d.Scan(folderPtr)
+		switch {
+		case err != nil:
+			detection.Error = err.Error()
+		case id != "":
+			detection.Detected = true
+			detection.BrickID = id
+			detection.Metadata = meta
+			if explainer, ok := d.(bricksengine.DetectionExplainer); ok {
+				detection.Evidence = explainer.Evidence()
+			}
+		}
+		out = append(out, detection)
+	}
+	return out
+}
+
+func checkBricksOutput(output string) error {
+	switch output {
+	case bricksOutputTable, bricksOutputJSON:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (expected json)", output)
+	}
+}
+
+// registerHostBricks registers the bricks of the user's bricks folder and the plugins the policy of the project
+// at projectPath allows. Bricks defined in .mkenv files of a project are only known when the project's env config
+// is resolved.
+func registerHostBricks(ctx context.Context, registry *bricksengine.BricksRegistry, projectPath string) error {
+	globalPolicy, err := guardrails.LoadPolicy()
+	if err != nil {
+		return err
+	}
+
+	defs, err := bricksengine.LoadBrickDefinitions(hostappconfig.UserBricksPath())
+	if err != nil {
+		return fmt.Errorf("failed to load user bricks: %w", err)
+	}
+	return runtime.RegisterCustomBricks(ctx, registry, globalPolicy.ForProject(projectPath), defs)
+}
+
+// detectableBricks returns the bricks a detector belongs to.
+func detectableBricks(registry *bricksengine.BricksRegistry) map[bricksengine.BrickID]bool {
+	out := map[bricksengine.BrickID]bool{}
+	for _, d := range registry.AllDetectors() {
+		out[d.BrickInfo().ID()] = true
+	}
+	return out
+}
+
+func summarizeBrick(registry *bricksengine.BricksRegistry, id bricksengine.BrickID, detectable map[bricksengine.BrickID]bool) (brickSummary, error) {
+	info, err := registry.BrickInfo(id)
+	if err != nil {
+		return brickSummary{}, err
+	}
+	kinds := []string{}
+	for _, kind := range info.Kinds().All() {
+		kinds = append(kinds, kind.Name())
+	}
+	schema, _ := registry.ConfigSchema(id)
+	return brickSummary{
+		ID:          id,
+		Description: info.Description(),
+		Kinds:       kinds,
+		Source:      registry.Source(id),
+		Detectable:  detectable[id],
+		Config:      schema,
+	}, nil
+}
+
+func renderBricksTable(w io.Writer, bricks []brickSummary) {
+	table := ui.NewTable(
+		ui.Column{Header: "ID"},
+		ui.Column{Header: "Kinds"},
+		ui.Column{Header: "Detectable"},
+		ui.Column{Header: "Source"},
+		ui.Column{Header: "Description"},
+	)
+	for _, b := range bricks {
+		table.AddRow(string(b.ID), strings.Join(b.Kinds, ", "), yesNo(b.Detectable), b.Source, b.Description)
+	}
+	fmt.Fprintln(w, "")
+	table.Render(w)
+	fmt.Fprintln(w, "")
+}
+
+func renderDetectionsTable(w io.Writer, detections []brickDetection) {
+	table := ui.NewTable(
+		ui.Column{Header: "Brick"},
+		ui.Column{Header: "Metadata"},
+		ui.Column{Header: "Why"},
+	)
+	detected := 0
+	notDetected := []string{}
+	for _, d := range detections {
+		switch {
+		case d.Error != "":
+			fmt.Fprintf(w, "Detector of %s failed: %s\n", d.Detector, d.Error)
+		case d.Detected:
+			detected++
+			meta := []string{}
+			for _, k := range utils.SortedKeys(d.Metadata) {
+				meta = append(meta, k+"="+d.Metadata[k])
+			}
+			why := d.Evidence
+			if d.BrickID != d.Detector || len(why) == 0 {
+				why = append(why, fmt.Sprintf("proposed by the %s detector", d.Detector))
+			}
+			table.AddRow(string(d.BrickID), strings.Join(meta, ", "), strings.Join(why, "; "))
+		default:
+			notDetected = append(notDetected, string(d.Detector))
+		}
+	}
+
+	if detected == 0 {
+		fmt.Fprintln(w, "No bricks detected")
+	} else {
+		fmt.Fprintln(w, "")
+		table.Render(w)
+		fmt.Fprintln(w, "")
+	}
+	if len(notDetected) > 0 {
+		fmt.Fprintf(w, "Not detected: %s\n", strings.Join(notDetected, ", "))
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func renderBrickInfo(w io.Writer, b brickSummary) {
+	fmt.Fprintf(w, "ID:          %s\n", b.ID)
+	fmt.Fprintf(w, "Description: %s\n", b.Description)
+	fmt.Fprintf(w, "Kinds:       %s\n", strings.Join(b.Kinds, ", "))
+	fmt.Fprintf(w, "Source:      %s\n", b.Source)
+	fmt.Fprintf(w, "Detectable:  %s\n", yesNo(b.Detectable))
+	fmt.Fprintln(w, "")
+
+	switch {
+	case b.Config == nil:
+		fmt.Fprintln(w, "The brick doesn't declare its config, bricks_config keys aren't checked")
+		return
+	case len(b.Config) == 0:
+		fmt.Fprintln(w, "The brick has no config")
+		return
+	}
+
+	table := ui.NewTable(
+		ui.Column{Header: "Key"},
+		ui.Column{Header: "Type"},
+		ui.Column{Header: "Default"},
+		ui.Column{Header: "Allowed"},
+		ui.Column{Header: "Description"},
+	)
+	for _, k := range b.Config {
+		table.AddRow(k.Key, string(k.Type), k.Default, strings.Join(k.Allowed, ", "), k.Description)
+	}
+	table.Render(w)
+}
+
+func renderBricksJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false) // version constraints like >=18 stay readable
+	return enc.Encode(v)
+}
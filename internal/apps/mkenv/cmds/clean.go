@@ -1,8 +1,19 @@
 package mkenv
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/0xa1bed0/mkenv/internal/dockerclient"
+	"github.com/0xa1bed0/mkenv/internal/dockerimage"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/state"
+	"github.com/0xa1bed0/mkenv/internal/versioncheck"
 	"github.com/spf13/cobra"
 )
 
@@ -12,18 +23,54 @@ type cleanOptions struct {
 	Volumes    bool
 	Cache      bool
 	All        bool
+
+	DryRun    bool
+	OlderThan time.Duration
+}
+
+// cleanScope narrows down what clean is allowed to touch.
+type cleanScope struct {
+	// projectName is empty when cleaning all projects
+	projectName string
+	// cutoff is zero when --older-than is not set
+	cutoff time.Time
+}
+
+// ownsImage returns true if the image belongs to the scope. The mkenv.project label only names the project
+// that built the image first, other projects reuse it through the image cache, so the images used by the
+// containers of the project (projectImages) are its images too.
+func (s *cleanScope) ownsImage(img *dockerclient.MkenvImage, projectImages map[string]bool) bool {
+	return s.projectName == "" || img.Project == s.projectName || projectImages[img.ImageID]
+}
+
+func (s *cleanScope) isOldEnough(createdAt time.Time) bool {
+	if s.cutoff.IsZero() {
+		return true
+	}
+	if createdAt.IsZero() {
+		// unknown age - keep it to be on the safe side
+		return false
+	}
+	return createdAt.Before(s.cutoff)
 }
 
 func newCleanCmd() *cobra.Command {
 	opts := &cleanOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "clean",
+		Use:   "clean [PATH]",
 		Short: "Clean up mkenv containers, images, volumes, and cache",
 		Long: `Clean up mkenv artifacts.
 
 By default, '--all' is implied, which cleans containers, images, volumes, and cache.
-Use flags to be more granular.`,
+Use flags to be more granular.
+
+If PATH is given, only containers, images and volumes of that project are cleaned.
+The cache is shared between projects, so it is only pruned when PATH is not given.
+
+Use '--older-than' to keep recently created objects and running containers,
+and '--dry-run' to print what would be removed without removing anything.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// If no specific flags and !All explicitly set, treat as All
 			if !opts.Containers && !opts.Images && !opts.Volumes && !opts.Cache && !opts.All {
@@ -37,19 +84,34 @@ Use flags to be more granular.`,
 				opts.Cache = true
 			}
 
-			fmt.Println("Cleaning:")
-			fmt.Printf("  containers: %v\n", opts.Containers)
-			fmt.Printf("  images:     %v\n", opts.Images)
-			fmt.Printf("  volumes:    %v\n", opts.Volumes)
-			fmt.Printf("  cache:      %v\n", opts.Cache)
+			if opts.OlderThan < 0 {
+				return fmt.Errorf("--older-than must not be negative")
+			}
 
-			// TODO:
-			// - docker stop/remove containers with mkenv labels
-			// - docker rmi images with mkenv labels
-			// - docker volume rm volumes with mkenv labels
-			// - delete local cache file/dir
+			rt := runtime.FromContext(cmd.Context())
 
-			return nil
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			scope := &cleanScope{}
+			if opts.OlderThan > 0 {
+				scope.cutoff = time.Now().Add(-opts.OlderThan)
+			}
+
+			if len(args) == 1 {
+				project, err := rt.ResolveProject(signalsCtx, args[0], nil)
+				if err != nil {
+					return err
+				}
+				scope.projectName = project.Name()
+			}
+
+			dockerClient, err := dockerclient.DefaultDockerClient()
+			if err != nil {
+				return err
+			}
+
+			return runClean(signalsCtx, dockerClient, opts, scope)
 		},
 	}
 
@@ -58,6 +120,186 @@ Use flags to be more granular.`,
 	cmd.Flags().BoolVar(&opts.Images, "images", false, "Clean images")
 	cmd.Flags().BoolVar(&opts.Volumes, "volumes", false, "Clean volumes")
 	cmd.Flags().BoolVar(&opts.Cache, "cache", false, "Clean cache")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Print objects that would be removed without removing them")
+	cmd.Flags().DurationVar(&opts.OlderThan, "older-than", 0, "Only clean objects created (or cache entries last used) earlier than this duration ago, e.g. 72h")
 
 	return cmd
 }
+
+func runClean(ctx context.Context, dockerClient *dockerclient.DockerClient, opts *cleanOptions, scope *cleanScope) error {
+	// Containers of all projects are listed even if they are not cleaned:
+	// images and volumes used by remaining containers must stay.
+	containers, err := dockerClient.ListCleanupContainers(ctx, "")
+	if err != nil {
+		return fmt.Errorf("list containers: %w", err)
+	}
+
+	usedImages := map[string]bool{}
+	usedVolumes := map[string]bool{}
+	projectImages := map[string]bool{}
+
+	var containersToRemove []*dockerclient.MkenvCleanupContainer
+	for _, c := range containers {
+		inScope := scope.projectName == "" || c.Project == scope.projectName
+		if inScope {
+			projectImages[c.ImageID] = true
+		}
+		// running containers are considered active when --older-than is set
+		keep := !inScope || !opts.Containers || !scope.isOldEnough(c.CreatedAt) || (!scope.cutoff.IsZero() && c.State == "running")
+		if keep {
+			usedImages[c.ImageID] = true
+			for _, vol := range c.Volumes {
+				usedVolumes[vol] = true
+			}
+			continue
+		}
+		containersToRemove = append(containersToRemove, c)
+	}
+
+	var imagesToRemove []*dockerclient.MkenvImage
+	if opts.Images {
+		images, err := dockerClient.ListImages(ctx)
+		if err != nil {
+			return fmt.Errorf("list images: %w", err)
+		}
+		for _, img := range images {
+			if !scope.ownsImage(img, projectImages) || usedImages[img.ImageID] || !scope.isOldEnough(img.CreatedAt) {
+				continue
+			}
+			imagesToRemove = append(imagesToRemove, img)
+		}
+	}
+
+	var volumesToRemove []*dockerclient.MkenvCacheVolume
+	if opts.Volumes {
+		volumes, err := dockerClient.ListCacheVolumes(ctx, scope.projectName)
+		if err != nil {
+			return fmt.Errorf("list volumes: %w", err)
+		}
+		for _, vol := range volumes {
+			if usedVolumes[vol.Name] || !scope.isOldEnough(vol.CreatedAt) {
+				continue
+			}
+			volumesToRemove = append(volumesToRemove, vol)
+		}
+	}
+
+	var kvStore *state.KVStore
+	var cacheEntries []state.Entry
+	if opts.Cache {
+		if scope.projectName != "" {
+			logs.Infof("Cache is shared between projects. Skipping cache pruning for a single project...")
+		} else {
+			kvStore, err = state.DefaultKVStore(ctx)
+			if err != nil {
+				return fmt.Errorf("open state database: %w", err)
+			}
+			unused, err := kvStore.ListUnusedBefore(ctx, cacheCutoff(scope))
+			if err != nil {
+				return err
+			}
+			for _, entry := range unused {
+				if isCacheEntry(entry.Key) {
+					cacheEntries = append(cacheEntries, entry)
+				}
+			}
+		}
+	}
+
+	printCleanPlan(containersToRemove, imagesToRemove, volumesToRemove, cacheEntries)
+
+	if opts.DryRun {
+		fmt.Println("Dry run: nothing was removed")
+		return nil
+	}
+
+	failed := 0
+
+	for _, c := range containersToRemove {
+		if err := dockerClient.RemoveContainer(ctx, c.ContainerID); err != nil {
+			logs.Warnf("can't remove container %s: %v", c.Name, err)
+			failed++
+		}
+	}
+
+	for _, img := range imagesToRemove {
+		if err := dockerClient.RemoveImage(ctx, img.ImageID); err != nil {
+			logs.Warnf("can't remove image %s: %v", shortID(img.ImageID), err)
+			failed++
+		}
+	}
+
+	for _, vol := range volumesToRemove {
+		if err := dockerClient.RemoveVolume(ctx, vol.Name); err != nil {
+			logs.Warnf("can't remove volume %s: %v", vol.Name, err)
+			failed++
+		}
+	}
+
+	for _, entry := range cacheEntries {
+		if err := kvStore.Delete(ctx, entry.Key); err != nil {
+			logs.Warnf("can't prune cache entry %s: %v", entry.Key, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d object(s)", failed)
+	}
+
+	fmt.Println("Done")
+	return nil
+}
+
+// isCacheEntry returns true if the KV store entry is a cache clean can prune. The rest of the store is state
+// that must survive a cache clean: pinned .mkenv files, port and scan decisions, policy serials...
+func isCacheEntry(key state.KVStoreKey) bool {
+	return dockerimage.IsCacheKey(key) || versioncheck.IsCacheKey(key)
+}
+
+// cacheCutoff returns the moment before which unused cache entries are pruned.
+// Without --older-than every entry is pruned.
+func cacheCutoff(scope *cleanScope) time.Time {
+	if scope.cutoff.IsZero() {
+		// last_used is stored with second precision
+		return time.Now().Add(time.Second)
+	}
+	return scope.cutoff
+}
+
+func printCleanPlan(containers []*dockerclient.MkenvCleanupContainer, images []*dockerclient.MkenvImage, volumes []*dockerclient.MkenvCacheVolume, cacheEntries []state.Entry) {
+	fmt.Printf("Containers (%d):\n", len(containers))
+	for _, c := range containers {
+		fmt.Printf("  %s  %s  project=%s  state=%s  created=%s\n", shortID(c.ContainerID), c.Name, c.Project, c.State, c.CreatedAt.Local().Format(time.RFC1123Z))
+	}
+
+	fmt.Printf("Images (%d):\n", len(images))
+	for _, img := range images {
+		tags := "<none>"
+		if len(img.Tags) > 0 {
+			tags = fmt.Sprint(img.Tags)
+		}
+		fmt.Printf("  %s  %s  size=%dMB  created=%s\n", shortID(img.ImageID), tags, img.Size/1024/1024, img.CreatedAt.Local().Format(time.RFC1123Z))
+	}
+
+	fmt.Printf("Volumes (%d):\n", len(volumes))
+	for _, vol := range volumes {
+		fmt.Printf("  %s  project=%s  mount=%s\n", vol.Name, vol.Project, vol.MountPath)
+	}
+
+	fmt.Printf("Cache entries (%d):\n", len(cacheEntries))
+	for _, entry := range cacheEntries {
+		fmt.Printf("  %s  last_used=%s\n", entry.Key, entry.LastUsed.Local().Format(time.RFC1123Z))
+	}
+}
+
+// shortID trims the "sha256:" prefix and shortens docker object id to 12 chars like docker cli does.
+func shortID(id string) string {
+	if len(id) > 7 && id[:7] == "sha256:" {
+		id = id[7:]
+	}
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,63 @@
+// Tests in this file exercise which images and state entries mkenv clean prunes.
+package mkenv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/0xa1bed0/mkenv/internal/dockerclient"
+	"github.com/0xa1bed0/mkenv/internal/state"
+)
+
+func TestIsCacheEntry(t *testing.T) {
+	t.Parallel()
+
+	hash := strings.Repeat("ab", 32)
+	cache := []state.KVStoreKey{
+		state.KVStoreKey("image-cache:" + hash),
+		state.KVStoreKey(hash), // written before image cache keys had a prefix
+		"versioncheck:stable",
+	}
+	for _, key := range cache {
+		if !isCacheEntry(key) {
+			t.Fatalf("expected %s to be pruned", key)
+		}
+	}
+
+	kept := []state.KVStoreKey{
+		"mkenv-file:/home/dev/app/.mkenv",
+		"port-trust:home-dev-app:5432",
+		"scan-allow:home-dev-app:" + state.KVStoreKey(hash),
+		"policy-serial:org",
+		"project:/home/dev/app",
+		state.KVStoreKey(hash[:62]),
+	}
+	for _, key := range kept {
+		if isCacheEntry(key) {
+			t.Fatalf("expected %s to survive a cache clean", key)
+		}
+	}
+}
+
+func TestCleanScopeOwnsImage(t *testing.T) {
+	t.Parallel()
+
+	builtByApp := &dockerclient.MkenvImage{ImageID: "sha256:a", Project: "app"}
+	// built by another project first, reused by app through the image cache
+	reused := &dockerclient.MkenvImage{ImageID: "sha256:b", Project: "web"}
+	other := &dockerclient.MkenvImage{ImageID: "sha256:c", Project: "web"}
+	projectImages := map[string]bool{"sha256:b": true}
+
+	scope := &cleanScope{projectName: "app"}
+	for _, img := range []*dockerclient.MkenvImage{builtByApp, reused} {
+		if !scope.ownsImage(img, projectImages) {
+			t.Fatalf("expected %s to belong to app", img.ImageID)
+		}
+	}
+	if scope.ownsImage(other, projectImages) {
+		t.Fatalf("expected %s not to belong to app", other.ImageID)
+	}
+	if !(&cleanScope{}).ownsImage(other, nil) {
+		t.Fatalf("expected a clean without PATH to own every image")
+	}
+}
@@ -0,0 +1,92 @@
+package mkenv
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	runcmd "github.com/0xa1bed0/mkenv/internal/apps/mkenv/cmds/run"
+	"github.com/0xa1bed0/mkenv/internal/dockerclient"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/supervisor"
+	"github.com/spf13/cobra"
+)
+
+func newExecCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec [PATH] -- <cmd...>",
+		Short: "Run a command in the project's dev container",
+		Long: `Run a command in the project's dev container as the non-root user in /workdir.
+
+If the project already has a running container, the command runs in it.
+Otherwise a new container is started and removed once no other session uses it.
+
+stdout and stderr are streamed and the exit code of the command is propagated.
+A TTY is only allocated when stdin is a terminal, so it is safe to use from git hooks and CI.`,
+		Example: `  mkenv exec -- go test ./...
+  mkenv exec ./api -- npm run lint`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running exec...")
+
+			dash := cmd.ArgsLenAtDash()
+			if dash < 0 {
+				return errors.New("command is required: mkenv exec [PATH] -- <cmd...>")
+			}
+			if dash > 1 {
+				return errors.New("only one PATH is allowed before '--'")
+			}
+			command := args[dash:]
+			if len(command) == 0 {
+				return errors.New("command is required: mkenv exec [PATH] -- <cmd...>")
+			}
+
+			rt := runtime.FromContext(cmd.Context())
+
+			pathArg := "."
+			if dash == 1 {
+				pathArg = args[0]
+			} else {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				pathArg = pwd
+			}
+
+			dockerClient, err := dockerclient.DefaultDockerClient()
+			if err != nil {
+				return err
+			}
+
+			client, _, err := runcmd.EnsureSupervisor(cmd, pathArg, false)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			// without TTY Ctrl+C must stop the command instead of killing mkenv
+			sessionCtx, stopSessionCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSessionCtx()
+
+			session, err := client.Attach(sessionCtx, supervisor.SessionKindExec)
+			if err != nil {
+				return err
+			}
+
+			exitCode, err := dockerClient.ExecCommand(sessionCtx, session.ContainerID, command, rt.Term())
+			if err != nil {
+				return err
+			}
+			if exitCode != 0 {
+				return &runtime.ExitError{Code: exitCode}
+			}
+			return nil
+		},
+	}
+
+	runcmd.AttachRunCmdFlags(cmd)
+
+	return cmd
+}
@@ -1,9 +1,13 @@
 package mkenv
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/0xa1bed0/mkenv/internal/dockerclient"
@@ -11,18 +15,40 @@ import (
 	"github.com/0xa1bed0/mkenv/internal/runtime"
 	"github.com/0xa1bed0/mkenv/internal/ui"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
+const (
+	listOutputTable = ""
+	listOutputWide  = "wide"
+	listOutputJSON  = "json"
+	listOutputYAML  = "yaml"
+)
+
+type listOptions struct {
+	Output string
+}
+
 func newListCmd() *cobra.Command {
+	opts := &listOptions{}
+
 	cmd := &cobra.Command{
 		Use:     "list [PATH]",
 		Aliases: []string{"ls"},
 		Short:   "List dev containers for project.",
-		Long:    "List running / known mkenv containers. If PATH is given, filter by that project.",
-		Args:    cobra.MaximumNArgs(1),
+		Long: `List running / known mkenv containers. If PATH is given, filter by that project.
+
+Use '--output json' or '--output yaml' for machine-readable output and '--output wide' for more columns.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			logs.Debugf("running list...")
 
+			switch opts.Output {
+			case listOutputTable, listOutputWide, listOutputJSON, listOutputYAML:
+			default:
+				return fmt.Errorf("unknown output format %q (expected one of: json, yaml, wide)", opts.Output)
+			}
+
 			rt := runtime.FromContext(cmd.Context())
 
 			pathArg := "."
@@ -64,28 +90,20 @@ func newListCmd() *cobra.Command {
 				}
 			}
 
+			switch opts.Output {
+			case listOutputJSON:
+				return renderContainersJSON(os.Stdout, containers)
+			case listOutputYAML:
+				return renderContainersYAML(os.Stdout, containers)
+			}
+
 			if len(containers) == 0 {
 				fmt.Println("No containers found")
 				return nil
 			}
 
-			colums := []ui.Column{
-				{Header: "Project"},
-				{Header: "Name"},
-				{Header: "State"},
-				{Header: "Status"},
-				{Header: "Created"},
-				{Header: "Command"},
-			}
-
-			table := ui.NewTable(colums...)
-
-			for _, container := range containers {
-				table.AddRow(container.Project, container.Name, container.State, container.Status, container.Created, container.Command)
-			}
-
 			fmt.Println("")
-			table.Render(os.Stdout)
+			renderContainersTable(os.Stdout, containers, opts.Output == listOutputWide)
 			fmt.Println("")
 			fmt.Println("Use 'mkenv a [name]' to attach or 'mkenv rm [name]' to remove")
 
@@ -93,5 +111,59 @@ func newListCmd() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Output format: json, yaml or wide")
+
 	return cmd
 }
+
+func renderContainersTable(w io.Writer, containers []*dockerclient.MkenvContainerInfo, wide bool) {
+	colums := []ui.Column{
+		{Header: "Project"},
+		{Header: "Name"},
+		{Header: "State"},
+		{Header: "Status"},
+		{Header: "Created"},
+		{Header: "Command"},
+	}
+	if wide {
+		colums = append(colums,
+			ui.Column{Header: "Container ID"},
+			ui.Column{Header: "Image"},
+			ui.Column{Header: "Bricks"},
+			ui.Column{Header: "Proxy port"},
+			ui.Column{Header: "Run ID"},
+			ui.Column{Header: "Path"},
+		)
+	}
+
+	table := ui.NewTable(colums...)
+
+	for _, container := range containers {
+		row := []string{container.Project, container.Name, container.State, container.Status, container.Created, container.Command}
+		if wide {
+			proxyPort := ""
+			if container.ProxyPort != 0 {
+				proxyPort = strconv.Itoa(container.ProxyPort)
+			}
+			row = append(row, shortID(container.ContainerID), container.ImageTag, strings.Join(container.Bricks, ","), proxyPort, container.RunID, container.ProjectPath)
+		}
+		table.AddRow(row...)
+	}
+
+	table.Render(w)
+}
+
+func renderContainersJSON(w io.Writer, containers []*dockerclient.MkenvContainerInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(containers)
+}
+
+func renderContainersYAML(w io.Writer, containers []*dockerclient.MkenvContainerInfo) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(containers); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,124 @@
+package mkenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newPolicyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect mkenv policies",
+	}
+
+	cmd.AddCommand(newPolicyShowCmd())
+
+	return cmd
+}
+
+type policyShowOptions struct {
+	Explain bool
+}
+
+func newPolicyShowCmd() *cobra.Command {
+	opts := &policyShowOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show [PATH]",
+		Short: "Print the effective policy of a project",
+		Long: fmt.Sprintf(`Print the effective policy of the project: the org, team and user policies merged so that the stricter setting always wins.
+Every policy can have sections keyed by path prefix, the most specific section matching the project applies.
+
+Policy files:
+  org   %s (signed)
+  team  %s (signed)
+  user  %s
+
+Org and team policies must be signed with one of the keys in %s.
+Once trusted keys are installed both are required, and their serial can't go back.
+Use '--explain' to see which policy every rule comes from.
+
+If PATH is omitted, the current working directory is used.`,
+			hostappconfig.OrgPolicyPath(), hostappconfig.TeamPolicyPath(), hostappconfig.UserPolicyPath(), hostappconfig.PolicyTrustedKeysPath()),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running policy show...")
+
+			rt := runtime.FromContext(cmd.Context())
+
+			pathArg := "."
+			if len(args) == 1 {
+				pathArg = args[0]
+			} else {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				pathArg = pwd
+			}
+
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			project, err := rt.ResolveProject(signalsCtx, pathArg, nil)
+			if err != nil {
+				return err
+			}
+
+			policy, err := guardrails.LoadPolicy()
+			if err != nil {
+				return err
+			}
+
+			rules := policy.ForProject(project.Path()).Explain()
+
+			if opts.Explain {
+				renderPolicyRulesTable(os.Stdout, rules)
+				return nil
+			}
+			return renderPolicyJSON(os.Stdout, rules)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.Explain, "explain", false, "Show where every rule comes from")
+
+	return cmd
+}
+
+func renderPolicyRulesTable(w io.Writer, rules []guardrails.PolicyRule) {
+	table := ui.NewTable(
+		ui.Column{Header: "Rule"},
+		ui.Column{Header: "Value"},
+		ui.Column{Header: "From"},
+	)
+	for _, rule := range rules {
+		value, _ := json.Marshal(rule.Value)
+		table.AddRow(rule.Field, string(value), strings.Join(rule.Sources, ", "))
+	}
+
+	fmt.Fprintln(w, "")
+	table.Render(w)
+	fmt.Fprintln(w, "")
+}
+
+func renderPolicyJSON(w io.Writer, rules []guardrails.PolicyRule) error {
+	effective := make(map[string]any, len(rules))
+	for _, rule := range rules {
+		effective[rule.Field] = rule.Value
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(effective)
+}
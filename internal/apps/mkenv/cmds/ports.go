@@ -0,0 +1,148 @@
+package mkenv
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/state"
+	"github.com/0xa1bed0/mkenv/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newPortsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ports",
+		Short: "Manage host ports the sandbox can reach",
+	}
+
+	cmd.AddCommand(newPortsTrustCmd())
+
+	return cmd
+}
+
+type portsTrustOptions struct {
+	Allow     []int
+	Deny      []int
+	Revoke    []int
+	RevokeAll bool
+}
+
+func newPortsTrustCmd() *cobra.Command {
+	opts := &portsTrustOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "trust [PATH]",
+		Short: "Show or change remembered host port decisions of the project",
+		Long: `Show or change which host ports the project's sandbox can reach through the reverse proxy.
+
+Decisions are used when the reverse proxy policy is in "ask" mode: a host port is offered for approval
+the first time the sandbox dials it, and "Allow for this project" is remembered here.
+Revoked ports are asked again. Ports denied by the policy can't be allowed.
+
+If PATH is omitted, the current working directory is used.`,
+		Example: `  mkenv ports trust
+  mkenv ports trust --allow 5432,6379
+  mkenv ports trust --revoke 5432`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running ports trust...")
+
+			for _, port := range append(append(append([]int{}, opts.Allow...), opts.Deny...), opts.Revoke...) {
+				if port <= 0 || port > 65535 {
+					return fmt.Errorf("invalid port %d", port)
+				}
+			}
+
+			rt := runtime.FromContext(cmd.Context())
+
+			pathArg := "."
+			if len(args) == 1 {
+				pathArg = args[0]
+			} else {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				pathArg = pwd
+			}
+
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			kvStore, err := state.DefaultKVStore(signalsCtx)
+			if err != nil {
+				return err
+			}
+
+			project, err := rt.ResolveProject(signalsCtx, pathArg, nil)
+			if err != nil {
+				return err
+			}
+
+			trust := guardrails.NewPortTrust(kvStore, project.Name())
+
+			if opts.RevokeAll {
+				entries, err := trust.List(signalsCtx)
+				if err != nil {
+					return err
+				}
+				for _, entry := range entries {
+					opts.Revoke = append(opts.Revoke, entry.Port)
+				}
+			}
+			for _, port := range opts.Revoke {
+				if err := trust.Revoke(signalsCtx, port); err != nil {
+					return err
+				}
+			}
+			for _, port := range opts.Allow {
+				if err := trust.Set(signalsCtx, port, guardrails.PortAllow); err != nil {
+					return err
+				}
+			}
+			for _, port := range opts.Deny {
+				if err := trust.Set(signalsCtx, port, guardrails.PortDeny); err != nil {
+					return err
+				}
+			}
+
+			entries, err := trust.List(signalsCtx)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Printf("No remembered host ports for project %s\n", project.Name())
+				return nil
+			}
+
+			fmt.Println("")
+			table := ui.NewTable(
+				ui.Column{Header: "Port", Align: ui.AlignRight},
+				ui.Column{Header: "Decision"},
+				ui.Column{Header: "Since"},
+			)
+			for _, entry := range entries {
+				table.AddRow(strconv.Itoa(entry.Port), string(entry.Decision), entry.CreatedAt.Local().Format(time.DateTime))
+			}
+			table.Render(os.Stdout)
+			fmt.Println("")
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntSliceVar(&opts.Allow, "allow", nil, "Allow the sandbox to reach these host ports without asking")
+	flags.IntSliceVar(&opts.Deny, "deny", nil, "Deny these host ports without asking")
+	flags.IntSliceVar(&opts.Revoke, "revoke", nil, "Forget decisions for these host ports")
+	flags.BoolVar(&opts.RevokeAll, "revoke-all", false, "Forget all decisions of the project")
+
+	return cmd
+}
@@ -0,0 +1,169 @@
+package mkenv
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/0xa1bed0/mkenv/internal/dockerclient"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+type rmOptions struct {
+	Volumes bool
+	Force   bool
+}
+
+func newRmCmd() *cobra.Command {
+	opts := &rmOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rm [NAME...]",
+		Short: "Remove dev containers",
+		Long: `Remove mkenv containers by name or ID.
+
+If no NAME is given, you can select containers of the current project interactively.
+Running containers are stopped gracefully, which closes all sessions attached to them.
+Use '--volumes' to also delete the cache volumes of the removed containers' projects.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running rm...")
+
+			rt := runtime.FromContext(cmd.Context())
+
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			dockerClient, err := dockerclient.DefaultDockerClient()
+			if err != nil {
+				return err
+			}
+
+			var selected []*dockerclient.MkenvContainerInfo
+
+			if len(args) == 0 {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				project, err := rt.ResolveProject(signalsCtx, pwd, nil)
+				if err != nil {
+					return err
+				}
+				containers, err := dockerClient.ListContainers(signalsCtx, project, false)
+				if err != nil {
+					return err
+				}
+				if len(containers) == 0 {
+					fmt.Printf("No containers found for project %s. Use 'mkenv list' to see all containers\n", project.Name())
+					return nil
+				}
+
+				options, err := logs.PromptSelectMany("Select containers to remove", ui.ToSelectOptions(containers))
+				if err != nil {
+					return err
+				}
+				for _, option := range options {
+					for _, c := range containers {
+						if c.ContainerID == option.OptionID() {
+							selected = append(selected, c)
+						}
+					}
+				}
+			} else {
+				containers, err := dockerClient.ListAllContainer(signalsCtx, false)
+				if err != nil {
+					return err
+				}
+				for _, name := range args {
+					c := findContainer(containers, name)
+					if c == nil {
+						return fmt.Errorf("container %q not found", name)
+					}
+					selected = append(selected, c)
+				}
+			}
+
+			if len(selected) == 0 {
+				fmt.Println("Nothing to remove")
+				return nil
+			}
+
+			if !opts.Force {
+				running := 0
+				for _, c := range selected {
+					if c.State == "running" {
+						running++
+					}
+				}
+				if running > 0 {
+					ok, err := logs.PromptConfirm(fmt.Sprintf("%d running container(s) will be stopped and all attached sessions closed. Continue?", running))
+					if err != nil {
+						return err
+					}
+					if !ok {
+						return nil
+					}
+				}
+			}
+
+			projects := map[string]bool{}
+			for _, c := range selected {
+				logs.Infof("Removing %s...", strings.TrimPrefix(c.Name, "/"))
+				if err := dockerClient.RemoveContainer(signalsCtx, c.ContainerID); err != nil {
+					return fmt.Errorf("remove container %s: %w", c.Name, err)
+				}
+				projects[c.Project] = true
+			}
+
+			if !opts.Volumes {
+				return nil
+			}
+
+			for projectName := range projects {
+				if projectName == "" {
+					continue
+				}
+				volumes, err := dockerClient.ListCacheVolumes(signalsCtx, projectName)
+				if err != nil {
+					return err
+				}
+				for _, vol := range volumes {
+					logs.Infof("Removing volume %s...", vol.Name)
+					if err := dockerClient.RemoveVolume(signalsCtx, vol.Name); err != nil {
+						// other containers of the same project may still use it
+						logs.Warnf("can't remove volume %s: %v", vol.Name, err)
+					}
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.Volumes, "volumes", false, "Also remove cache volumes of the removed containers' projects")
+	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "Do not ask for confirmation before stopping running containers")
+
+	return cmd
+}
+
+// findContainer looks a container up by name (with or without leading '/') or by ID prefix.
+func findContainer(containers []*dockerclient.MkenvContainerInfo, nameOrID string) *dockerclient.MkenvContainerInfo {
+	for _, c := range containers {
+		for name := range strings.SplitSeq(c.Name, ",") {
+			if strings.TrimPrefix(name, "/") == strings.TrimPrefix(nameOrID, "/") {
+				return c
+			}
+		}
+	}
+	for _, c := range containers {
+		if len(nameOrID) >= 4 && strings.HasPrefix(c.ContainerID, nameOrID) {
+			return c
+		}
+	}
+	return nil
+}
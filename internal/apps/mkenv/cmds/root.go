@@ -48,6 +48,14 @@ If PATH is omitted, the current working directory is used.`,
 	rootCmd.AddCommand(newListCmd())
 	rootCmd.AddCommand(newAttachCmd())
 	rootCmd.AddCommand(newCleanCmd())
+	rootCmd.AddCommand(newRmCmd())
+	rootCmd.AddCommand(newExecCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newPortsCmd())
+	rootCmd.AddCommand(newPolicyCmd())
+	rootCmd.AddCommand(newScanCmd())
+	rootCmd.AddCommand(newBricksCmd())
+	rootCmd.AddCommand(runcmd.NewSuperviseCmd())
 	rootCmd.AddCommand(newVersionCmd())
 
 	err := rootCmd.ExecuteContext(rt.Ctx())
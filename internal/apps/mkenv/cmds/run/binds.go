@@ -1,9 +1,13 @@
 package runcmd
 
 import (
+	"fmt"
+	"path"
 	"strings"
 
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
 	sandboxappconfig "github.com/0xa1bed0/mkenv/internal/apps/sandbox/config"
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
 	"github.com/0xa1bed0/mkenv/internal/utils"
 )
 
@@ -24,9 +28,30 @@ func ResolveBinds(binds []string) ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
+		// the resolved path is checked again: the symlinks may have changed since the env config was resolved
+		if guardrails.IsAbsolutelyForbidden(hostPath) {
+			return nil, fmt.Errorf("volume %s is not allowed by mkenv: %s is a forbidden location", bind, hostPath)
+		}
 		containerPath = strings.Replace(containerPath, "~", sandboxappconfig.HomeFolder, 1)
 		out = append(out, strings.Join([]string{hostPath, containerPath, perm}, ":"))
 	}
 
 	return out, nil
 }
+
+// MaskBinds returns binds that hide masked paths of the project mounted at workdir
+// behind an empty read-only file or folder. The rest of the project stays live.
+func MaskBinds(workdir string, masked []guardrails.MaskedPath) ([]string, error) {
+	out := []string{}
+	for _, m := range masked {
+		if strings.Contains(m.Rel, ":") {
+			return nil, fmt.Errorf("can't mask %s: paths with ':' can't be mounted", m.Rel)
+		}
+		source := hostappconfig.MaskFilePath()
+		if m.IsDir {
+			source = hostappconfig.MaskFolderPath()
+		}
+		out = append(out, source+":"+path.Join(workdir, m.Rel)+":ro")
+	}
+	return out, nil
+}
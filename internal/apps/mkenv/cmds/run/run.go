@@ -2,8 +2,11 @@ package runcmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/0xa1bed0/mkenv/internal/agentdist"
@@ -13,9 +16,11 @@ import (
 	"github.com/0xa1bed0/mkenv/internal/dockerclient"
 	"github.com/0xa1bed0/mkenv/internal/dockercontainer"
 	"github.com/0xa1bed0/mkenv/internal/dockerimage"
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
 	"github.com/0xa1bed0/mkenv/internal/logs"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
 	"github.com/0xa1bed0/mkenv/internal/state"
+	"github.com/0xa1bed0/mkenv/internal/supervisor"
 
 	"github.com/spf13/cobra"
 )
@@ -24,11 +29,13 @@ type runOptions struct {
 	Tools        []string
 	Langs        []string
 	Volumes      []string
+	BrickConfigs []string
 	Entrypoint   string
 	System       string
 	Shell        string
 	ForceRebuild bool
 	CleanCache   bool
+	Detach       bool
 }
 
 // AttachRunCmdFlags attaches the "run" cmd flags to the given command and
@@ -43,7 +50,9 @@ func AttachRunCmdFlags(cmd *cobra.Command) {
 	flags.StringVar(&opts.System, "system", "debian", "System brick id (e.g. 'debian')")
 	flags.StringVar(&opts.Shell, "shell", "ohmyzsh", "Shell to enable")
 	flags.StringSliceVar(&opts.Volumes, "volume", nil, "Bind mount in 'host:container' format (may be repeated)")
+	flags.StringArrayVar(&opts.BrickConfigs, "brick-config", nil, "Brick config in 'brick.key=value' format, like bricks_config of .mkenv (may be repeated)")
 	flags.BoolVar(&opts.ForceRebuild, "rebuild", false, "Force rebuild of the dev image. Update image cache for the next runs")
+	flags.BoolVarP(&opts.Detach, "detach", "d", false, "Keep the container running in the background. Use 'mkenv attach' to attach to it later")
 
 	// Store opts in command context before running
 	cmd.PreRun = func(cmd *cobra.Command, args []string) {
@@ -51,7 +60,12 @@ func AttachRunCmdFlags(cmd *cobra.Command) {
 	}
 }
 
-func (ro *runOptions) EnvConfig() runtime.EnvConfig {
+func (ro *runOptions) EnvConfig() (runtime.EnvConfig, error) {
+	bricksConfigs, err := parseBrickConfigs(ro.BrickConfigs)
+	if err != nil {
+		return nil, err
+	}
+
 	enableBricks := []bricksengine.BrickID{}
 
 	for _, b := range ro.Tools {
@@ -71,9 +85,28 @@ func (ro *runOptions) EnvConfig() runtime.EnvConfig {
 		runtime.WithDefaultEntrypointBrickID(bricksengine.BrickID(ro.Entrypoint)),
 		runtime.WithDefaultSystemBrickID(bricksengine.BrickID(ro.System)),
 		runtime.WithVolumes(ro.Volumes),
+		runtime.WithBricksConfigs(bricksConfigs),
 	)
 
-	return cliRunConfig
+	return cliRunConfig, nil
+}
+
+// parseBrickConfigs parses --brick-config values. Brick ids can contain dots, keys can't: the key is after the last dot.
+func parseBrickConfigs(values []string) (map[bricksengine.BrickID]map[string]string, error) {
+	out := map[bricksengine.BrickID]map[string]string{}
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, "=")
+		dot := strings.LastIndex(name, ".")
+		if !ok || dot <= 0 || dot == len(name)-1 {
+			return nil, fmt.Errorf("invalid --brick-config %q: expected brick.key=value", value)
+		}
+		brickID := bricksengine.BrickID(name[:dot])
+		if out[brickID] == nil {
+			out[brickID] = map[string]string{}
+		}
+		out[brickID][name[dot+1:]] = v
+	}
+	return out, nil
 }
 
 func NewRunCmd() *cobra.Command {
@@ -99,8 +132,6 @@ func RunCmdRunE(cmd *cobra.Command, args []string) error {
 	rt := runtime.FromContext(cmd.Context())
 	opts := getRunOptions(cmd.Context())
 	if opts == nil {
-		// This should not normally happen because addRunFlags sets it,
-		// but keep a safe fallback for root or tests.
 		opts = &runOptions{}
 	}
 
@@ -115,33 +146,88 @@ func RunCmdRunE(cmd *cobra.Command, args []string) error {
 		pathArg = pwd
 	}
 
-	signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
-	defer stopSignalsCtx()
+	client, project, err := EnsureSupervisor(cmd, pathArg, opts.Detach)
+	if err != nil {
+		return err
+	}
+	// closing the client detaches the session
+	defer client.Close()
 
-	kvStore, err := state.DefaultKVStore(signalsCtx)
+	if opts.Detach {
+		fmt.Printf("%s is running in the background.\n", project.Name())
+		fmt.Printf("Use 'mkenv attach %s' to attach or 'mkenv rm' to stop it\n", pathArg)
+		return nil
+	}
+
+	client.HandleApprovals(rt.Term())
+	session, err := client.Attach(rt.Ctx(), supervisor.SessionKindShell)
 	if err != nil {
 		return err
 	}
 
-	project, err := rt.ResolveProject(signalsCtx, pathArg, kvStore)
+	dockerClient, err := dockerclient.DefaultDockerClient()
 	if err != nil {
 		return err
 	}
 
-	project.SetEnvConfigOverride(opts.EnvConfig())
+	return dockerClient.AttachToRunning(rt.Ctx(), session.ContainerID, filepath.Base(project.Path()), rt.Term())
+}
 
-	dockerImageResolver, err := dockerimage.DefaultDockerImageResolver(signalsCtx)
+// resolveEnvironment resolves the project at pathArg and builds its image if needed.
+// This is the interactive part of starting an environment (scanner warnings, first run confirmation, build output).
+func resolveEnvironment(ctx context.Context, rt *runtime.Runtime, opts *runOptions, pathArg string) (*runtime.Project, error) {
+	kvStore, err := state.DefaultKVStore(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	imageID, err := dockerImageResolver.ResolveImageID(signalsCtx, rt.Project(), opts.ForceRebuild)
+	project, err := rt.ResolveProject(ctx, pathArg, kvStore)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	override, err := opts.EnvConfig()
+	if err != nil {
+		return nil, err
+	}
+	project.SetEnvConfigOverride(override)
+
+	dockerImageResolver, err := dockerimage.DefaultDockerImageResolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	imageID, err := dockerImageResolver.ResolveImageID(ctx, rt.Project(), opts.ForceRebuild)
+	if err != nil {
+		return nil, err
 	}
 
 	rt.Container().SetImageTag(string(imageID))
 
+	return project, nil
+}
+
+// StartEnvironment builds (if needed) and runs a dev container for the project at pathArg
+// using run flags attached by AttachRunCmdFlags.
+// If session is nil the terminal is attached to the container's entrypoint,
+// otherwise the container runs in the background while session is running.
+func StartEnvironment(cmd *cobra.Command, pathArg string, session dockercontainer.ContainerSession) error {
+	rt := runtime.FromContext(cmd.Context())
+	opts := getRunOptions(cmd.Context())
+	if opts == nil {
+		// This should not normally happen because addRunFlags sets it,
+		// but keep a safe fallback for root or tests.
+		opts = &runOptions{}
+	}
+
+	signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignalsCtx()
+
+	project, err := resolveEnvironment(signalsCtx, rt, opts, pathArg)
+	if err != nil {
+		return err
+	}
+
 	binds, err := mkbinds(signalsCtx, rt, project)
 	if err != nil {
 		return err
@@ -160,6 +246,10 @@ func RunCmdRunE(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if session != nil {
+		containerOrchestrator.SetSession(session)
+	}
+
 	return containerOrchestrator.Start()
 }
 
@@ -170,6 +260,19 @@ func mkbinds(ctx context.Context, rt *runtime.Runtime, project *runtime.Project)
 	}
 
 	binds = append(binds, project.Path()+":/workdir")
+
+	masked, err := guardrails.ResolveMaskedPaths(ctx, project.Path(), project.EnvConfig(ctx).MaskedPaths())
+	if err != nil {
+		return nil, err
+	}
+	maskBinds, err := MaskBinds("/workdir", masked)
+	if err != nil {
+		return nil, err
+	}
+	if len(maskBinds) > 0 {
+		logs.Infof("Masking %d sensitive path(s) in the sandbox", len(maskBinds))
+	}
+	binds = append(binds, maskBinds...)
 	// this is a hack for docker desktop race condition on folders on host
 	// TODO: invesatigate and fix
 	binds = append(binds, hostappconfig.ProjectDataPath(project.Name())+":/mnthack:ro")
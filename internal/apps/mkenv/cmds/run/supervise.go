@@ -0,0 +1,262 @@
+package runcmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	"github.com/0xa1bed0/mkenv/internal/dockerclient"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/supervisor"
+	"github.com/spf13/cobra"
+)
+
+// supervisorStartTimeout is how long we wait for the supervisor to report the container is up.
+const supervisorStartTimeout = 2 * time.Minute
+
+// supervisorReadyFd is the file descriptor the supervisor reports readiness to.
+// It is the first (and only) entry of exec.Cmd.ExtraFiles.
+const supervisorReadyFd = 3
+
+// supervisorArgs returns run flags to pass to the supervisor process so it resolves the same environment.
+// --rebuild is not passed on purpose: the image is already (re)built by the foreground process.
+func (ro *runOptions) supervisorArgs() []string {
+	args := []string{}
+	if len(ro.Tools) > 0 {
+		args = append(args, "--tools", strings.Join(ro.Tools, ","))
+	}
+	if len(ro.Langs) > 0 {
+		args = append(args, "--langs", strings.Join(ro.Langs, ","))
+	}
+	args = append(args, "--entrypoint="+ro.Entrypoint)
+	args = append(args, "--system="+ro.System)
+	args = append(args, "--shell="+ro.Shell)
+	for _, vol := range ro.Volumes {
+		args = append(args, "--volume", vol)
+	}
+	for _, c := range ro.BrickConfigs {
+		args = append(args, "--brick-config", c)
+	}
+	return args
+}
+
+// EnsureSupervisor connects to the supervisor of the project at pathArg, starting it
+// (and the container) in the background if it is not running yet.
+// If keepAlive is true the container keeps running when no sessions are attached.
+func EnsureSupervisor(cmd *cobra.Command, pathArg string, keepAlive bool) (*supervisor.Client, *runtime.Project, error) {
+	rt := runtime.FromContext(cmd.Context())
+	opts := getRunOptions(cmd.Context())
+	if opts == nil {
+		opts = &runOptions{}
+	}
+
+	signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignalsCtx()
+
+	// resolve the environment in the foreground: it may need user confirmation or rebuild the image
+	project, err := resolveEnvironment(signalsCtx, rt, opts, pathArg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := supervisor.Dial(signalsCtx, project.Name())
+	if err != nil {
+		logs.Debugf("no supervisor for %s (%v). Starting a new one...", project.Name(), err)
+
+		spawnErr := spawnSupervisor(signalsCtx, rt, project, opts, keepAlive)
+
+		// someone could start the supervisor concurrently, so try to connect anyway
+		client, err = supervisor.Dial(signalsCtx, project.Name())
+		if err != nil {
+			if spawnErr != nil {
+				return nil, nil, spawnErr
+			}
+			return nil, nil, fmt.Errorf("connect to supervisor: %w", err)
+		}
+		return client, project, nil
+	}
+
+	logs.Debugf("connected to running supervisor of %s", project.Name())
+	if opts.ForceRebuild {
+		logs.Warnf("Container of %s is already running. The rebuilt image will be used after it is stopped", project.Name())
+	}
+
+	if keepAlive {
+		if err := client.SetKeepAlive(signalsCtx, true); err != nil {
+			client.Close()
+			return nil, nil, err
+		}
+	}
+
+	return client, project, nil
+}
+
+// spawnSupervisor starts the supervisor process in its own session, so it survives the terminal being closed,
+// and waits until it reports the container is running.
+func spawnSupervisor(ctx context.Context, rt *runtime.Runtime, project *runtime.Project, opts *runOptions, keepAlive bool) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("resolve mkenv executable: %w", err)
+	}
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+
+	logPath := hostappconfig.SupervisorLogPath(project.Name(), rt.RunID())
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		readyWriter.Close()
+		return err
+	}
+	defer logFile.Close()
+
+	args := []string{"supervise", project.Path(), "--ready-fd", strconv.Itoa(supervisorReadyFd)}
+	if keepAlive {
+		args = append(args, "--keep-alive")
+	}
+	args = append(args, opts.supervisorArgs()...)
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = nil // /dev/null
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.ExtraFiles = []*os.File{readyWriter}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	logs.Debugf("starting supervisor: %s %v", exe, args)
+	err = cmd.Start()
+	// the child has its own copy of the write end now
+	readyWriter.Close()
+	if err != nil {
+		return fmt.Errorf("start supervisor: %w", err)
+	}
+
+	// do not wait for the child, it must outlive us
+	_ = cmd.Process.Release()
+
+	readyCh := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(readyReader).ReadString('\n')
+		if strings.TrimSpace(line) == "" {
+			if err == nil {
+				err = errors.New("empty response")
+			}
+			readyCh <- err
+			return
+		}
+		readyCh <- nil
+	}()
+
+	logs.Infof("Starting %s...", project.Name())
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(supervisorStartTimeout):
+		return fmt.Errorf("supervisor did not start the container in %s. See logs: %s", supervisorStartTimeout, logPath)
+	case err := <-readyCh:
+		if err != nil {
+			return fmt.Errorf("supervisor failed to start the container (%v). See logs: %s", err, logPath)
+		}
+		return nil
+	}
+}
+
+// NewSuperviseCmd returns the hidden command EnsureSupervisor starts in the background.
+// It owns the container, its control plane, port forwarders and reverse proxy, and serves
+// the supervisor API sessions attach through. The container is stopped once no sessions
+// are attached, unless --keep-alive is set.
+func NewSuperviseCmd() *cobra.Command {
+	var readyFd int
+	var keepAlive bool
+
+	cmd := &cobra.Command{
+		Use:    "supervise PATH",
+		Short:  "Run and supervise a dev container in the background",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running supervisor...")
+
+			rt := runtime.FromContext(cmd.Context())
+
+			var ready *os.File
+			if readyFd > 0 {
+				ready = os.NewFile(uintptr(readyFd), "ready")
+				defer ready.Close()
+			}
+
+			dockerClient, err := dockerclient.DefaultDockerClient()
+			if err != nil {
+				return err
+			}
+
+			session := func(ctx context.Context, containerID string) error {
+				server, err := supervisor.Listen(rt, rt.Project().Name(), containerID, keepAlive)
+				if err != nil {
+					return err
+				}
+				defer server.Close()
+
+				// sensitive sandbox requests are approved by the user in one of the attached sessions
+				rt.SetApprover(server.RequestApproval)
+				rt.SetChooser(server.RequestChoice)
+
+				if ready != nil {
+					if _, err := fmt.Fprintln(ready, containerID); err != nil {
+						logs.Warnf("can't report readiness: %v", err)
+					}
+					ready.Close()
+				}
+				logs.Infof("container %s started", containerID)
+
+				// keep control plane and forwarders alive until the container stops (e.g. 'mkenv rm'),
+				// nobody needs it anymore or the supervisor is asked to stop
+				waitCtx, stopWaitCtx := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+				defer stopWaitCtx()
+
+				containerStopped := make(chan error, 1)
+				rt.GoNamed("Supervisor;WaitContainer", func() {
+					containerStopped <- dockerClient.WaitContainer(waitCtx, containerID)
+				})
+
+				idle := make(chan error, 1)
+				rt.GoNamed("Supervisor;WaitIdle", func() {
+					idle <- server.WaitIdle(waitCtx)
+				})
+
+				select {
+				case err := <-containerStopped:
+					logs.Infof("container stopped")
+					return err
+				case err := <-idle:
+					if err == nil {
+						logs.Infof("no sessions attached. Stopping container...")
+					}
+					return nil
+				}
+			}
+
+			return StartEnvironment(cmd, args[0], session)
+		},
+	}
+
+	AttachRunCmdFlags(cmd)
+	cmd.Flags().IntVar(&readyFd, "ready-fd", 0, "File descriptor to report the started container ID to")
+	cmd.Flags().BoolVar(&keepAlive, "keep-alive", false, "Keep the container running when no sessions are attached")
+
+	return cmd
+}
@@ -0,0 +1,40 @@
+// Tests in this file exercise the run flags passed to the background supervisor.
+package runcmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestSupervisorArgsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	want := &runOptions{
+		Tools:        []string{"tmux", "nvim"},
+		Langs:        []string{"nodejs"},
+		Volumes:      []string{"/tmp/a:/a", "/tmp/b:/b:ro"},
+		BrickConfigs: []string{"nodejs.version=20", "claude-code.version=latest,next"},
+		Entrypoint:   "tmux",
+		System:       "debian",
+		Shell:        "zsh",
+	}
+
+	cmd := &cobra.Command{Use: "run"}
+	AttachRunCmdFlags(cmd)
+	if err := cmd.ParseFlags(want.supervisorArgs()); err != nil {
+		t.Fatalf("failed to parse supervisor args: %v", err)
+	}
+	cmd.SetContext(context.Background())
+	cmd.PreRun(cmd, nil)
+
+	got := getRunOptions(cmd.Context())
+	if got == nil {
+		t.Fatalf("expected run options in the command context")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("supervisor resolved different options:\n got %+v\nwant %+v", got, want)
+	}
+}
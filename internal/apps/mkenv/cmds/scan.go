@@ -0,0 +1,294 @@
+package mkenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/state"
+	"github.com/0xa1bed0/mkenv/internal/ui"
+	"github.com/0xa1bed0/mkenv/internal/version"
+	"github.com/spf13/cobra"
+)
+
+const (
+	scanOutputTable = ""
+	scanOutputText  = "text"
+	scanOutputJSON  = "json"
+	scanOutputSARIF = "sarif"
+)
+
+const (
+	scanStatusNew      = "new"
+	scanStatusAccepted = "accepted"
+	scanStatusMasked   = "masked"
+)
+
+type scanOptions struct {
+	Output string
+	All    bool
+	Accept bool
+	Revoke []string
+}
+
+// scanFinding is a finding as printed by mkenv scan.
+type scanFinding struct {
+	Path        string `json:"path"`
+	Line        int    `json:"line,omitempty"`
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Fingerprint string `json:"fingerprint"`
+	Status      string `json:"status"`
+}
+
+func newScanCmd() *cobra.Command {
+	opts := &scanOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "scan [PATH]",
+		Short: "Scan the project for secrets",
+		Long: `Scan the project for files that look like secrets: by file name, by content rules and by high-entropy tokens.
+Rules of the policy (secret_scan) and of the project .mkenv files are added to the built-in ones.
+
+Findings accepted before (when starting the sandbox or with '--accept') and findings in masked paths are not reported
+unless '--all' is set. masked_paths are only used from .mkenv files you trusted, so a change to the repository can't
+mask its own secrets. The command exits with code 1 if new findings remain, so it can run in CI.
+
+If PATH is omitted, the current working directory is used.`,
+		Example: `  mkenv scan
+  mkenv scan --output sarif > mkenv.sarif
+  mkenv scan --accept
+  mkenv scan --revoke 3f2a9c...`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logs.Debugf("running scan...")
+
+			switch opts.Output {
+			case scanOutputText:
+				opts.Output = scanOutputTable
+			case scanOutputTable, scanOutputJSON, scanOutputSARIF:
+			default:
+				return fmt.Errorf("unknown output format %q (expected text, json or sarif)", opts.Output)
+			}
+			if opts.Output != scanOutputTable {
+				// logs go to stdout, keep it machine readable
+				restoreLogs := logs.Mute()
+				defer restoreLogs()
+			}
+
+			rt := runtime.FromContext(cmd.Context())
+
+			pathArg := "."
+			if len(args) == 1 {
+				pathArg = args[0]
+			} else {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				pathArg = pwd
+			}
+
+			signalsCtx, stopSignalsCtx := signal.NotifyContext(rt.Ctx(), os.Interrupt, syscall.SIGTERM)
+			defer stopSignalsCtx()
+
+			kvStore, err := state.DefaultKVStore(signalsCtx)
+			if err != nil {
+				return err
+			}
+
+			project, err := rt.ResolveProject(signalsCtx, pathArg, kvStore)
+			if err != nil {
+				return err
+			}
+
+			allowlist := project.SecretScanAllowlist()
+			for _, fingerprint := range opts.Revoke {
+				if err := allowlist.Remove(signalsCtx, fingerprint); err != nil {
+					return err
+				}
+			}
+
+			result, err := project.ScanSecrets(signalsCtx)
+			if err != nil {
+				return err
+			}
+
+			if opts.Accept && len(result.Pending) > 0 {
+				if err := allowlist.Add(signalsCtx, result.Pending...); err != nil {
+					return err
+				}
+				result.Accepted = append(result.Accepted, result.Pending...)
+				result.Pending = nil
+			}
+
+			findings := scanFindings(result.Pending, scanStatusNew)
+			if opts.All {
+				findings = append(findings, scanFindings(result.Accepted, scanStatusAccepted)...)
+				findings = append(findings, scanFindings(result.Masked, scanStatusMasked)...)
+			}
+
+			switch opts.Output {
+			case scanOutputJSON:
+				err = renderScanJSON(os.Stdout, findings)
+			case scanOutputSARIF:
+				err = renderScanSARIF(os.Stdout, findings)
+			default:
+				renderScanTable(os.Stdout, findings, len(result.Accepted), len(result.Masked))
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(result.Pending) > 0 {
+				return &runtime.ExitError{Code: 1}
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.Output, "output", "o", "", "Output format: text (default), json or sarif")
+	// --format is what other secret scanners call it
+	flags.StringVar(&opts.Output, "format", "", "Alias of --output")
+	_ = flags.MarkHidden("format")
+	flags.BoolVar(&opts.All, "all", false, "Also show accepted findings and findings in masked paths")
+	flags.BoolVar(&opts.Accept, "accept", false, "Accept all new findings, they won't be reported again")
+	flags.StringArrayVar(&opts.Revoke, "revoke", nil, "Forget an accepted finding by its fingerprint (repeatable)")
+
+	return cmd
+}
+
+func scanFindings(warnings []*guardrails.SensitivityWarning, status string) []scanFinding {
+	out := make([]scanFinding, 0, len(warnings))
+	for _, warn := range warnings {
+		out = append(out, scanFinding{
+			Path:        warn.RelPath,
+			Line:        warn.Line,
+			Rule:        warn.Rule,
+			Description: warn.Reason,
+			Fingerprint: warn.Fingerprint,
+			Status:      status,
+		})
+	}
+	return out
+}
+
+func renderScanTable(w io.Writer, findings []scanFinding, accepted, masked int) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "No new findings")
+	} else {
+		table := ui.NewTable(
+			ui.Column{Header: "Path"},
+			ui.Column{Header: "Line", Align: ui.AlignRight},
+			ui.Column{Header: "Rule"},
+			ui.Column{Header: "Status"},
+			ui.Column{Header: "Fingerprint"},
+		)
+		for _, f := range findings {
+			line := ""
+			if f.Line > 0 {
+				line = strconv.Itoa(f.Line)
+			}
+			table.AddRow(f.Path, line, f.Rule, f.Status, f.Fingerprint)
+		}
+		fmt.Fprintln(w, "")
+		table.Render(w)
+		fmt.Fprintln(w, "")
+	}
+	if accepted > 0 || masked > 0 {
+		fmt.Fprintf(w, "%d accepted and %d masked findings are hidden, use --all to show them\n", accepted, masked)
+	}
+}
+
+func renderScanJSON(w io.Writer, findings []scanFinding) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
+
+// renderScanSARIF prints findings as a SARIF 2.1.0 log, the format code scanning tools of CI systems read.
+// Accepted and masked findings are marked as suppressed.
+func renderScanSARIF(w io.Writer, findings []scanFinding) error {
+	type sarifRule struct {
+		ID string `json:"id"`
+	}
+	type sarifRegion struct {
+		StartLine int `json:"startLine"`
+	}
+	type sarifPhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	}
+	type sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	type sarifMessage struct {
+		Text string `json:"text"`
+	}
+	type sarifSuppression struct {
+		Kind          string `json:"kind"`
+		Justification string `json:"justification"`
+	}
+	type sarifResult struct {
+		RuleID              string             `json:"ruleId"`
+		Level               string             `json:"level"`
+		Message             sarifMessage       `json:"message"`
+		Locations           []sarifLocation    `json:"locations"`
+		PartialFingerprints map[string]string  `json:"partialFingerprints"`
+		Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+	}
+
+	rules := []sarifRule{}
+	seenRules := map[string]bool{}
+	results := []sarifResult{}
+	for _, f := range findings {
+		if !seenRules[f.Rule] {
+			seenRules[f.Rule] = true
+			rules = append(rules, sarifRule{ID: f.Rule})
+		}
+
+		location := sarifLocation{}
+		location.PhysicalLocation.ArtifactLocation.URI = f.Path
+		if f.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line}
+		}
+		result := sarifResult{
+			RuleID:              f.Rule,
+			Level:               "error",
+			Message:             sarifMessage{Text: f.Description},
+			Locations:           []sarifLocation{location},
+			PartialFingerprints: map[string]string{"mkenv/v1": f.Fingerprint},
+		}
+		if f.Status != scanStatusNew {
+			result.Suppressions = []sarifSuppression{{Kind: "external", Justification: f.Status + " in mkenv"}}
+		}
+		results = append(results, result)
+	}
+
+	log := map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []any{map[string]any{
+			"tool": map[string]any{"driver": map[string]any{
+				"name":           "mkenv",
+				"version":        version.Get(),
+				"informationUri": "https://github.com/0xa1bed0/mkenv",
+				"rules":          rules,
+			}},
+			"results": results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
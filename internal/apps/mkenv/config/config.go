@@ -1,11 +1,20 @@
 package hostappconfig
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"syscall"
 )
 
+// maxUnixSocketPathLen is the portable limit for unix socket paths (104 on darwin, 108 on linux).
+const maxUnixSocketPathLen = 100
+
 // ensureFolder recursively creates a folder if it does not exist.
 func ensureFolder(path string) error {
 	return os.MkdirAll(path, 0o755)
@@ -40,6 +49,40 @@ func ConfigBasePath() string {
 	return p
 }
 
+// SystemConfigPath is the machine-wide config folder managed by administrators (org policy, trusted keys).
+func SystemConfigPath() string {
+	if runtime.GOOS == "darwin" {
+		return "/Library/Application Support/mkenv"
+	}
+	return "/etc/mkenv"
+}
+
+// OrgPolicyPath is the signed policy of the organization.
+func OrgPolicyPath() string {
+	return filepath.Join(SystemConfigPath(), "policy.json")
+}
+
+// TeamPolicyPath is the signed policy of the user's team. It is managed by administrators like the org policy:
+// in the user's config folder it could be deleted or rolled back.
+func TeamPolicyPath() string {
+	return filepath.Join(SystemConfigPath(), "team-policy.json")
+}
+
+// UserPolicyPath is the user's own policy.
+func UserPolicyPath() string {
+	return filepath.Join(ConfigBasePath(), "policy.json")
+}
+
+// UserBricksPath holds the user's declarative bricks, one YAML or JSON file per brick.
+func UserBricksPath() string {
+	return filepath.Join(ConfigBasePath(), "bricks")
+}
+
+// PolicyTrustedKeysPath lists ed25519 public keys org and team policies must be signed with.
+func PolicyTrustedKeysPath() string {
+	return filepath.Join(SystemConfigPath(), "trusted-keys")
+}
+
 func ProjectDataPath(projectName string) string {
 	p := filepath.Join(ConfigBasePath(), "projects", projectName)
 	return p
@@ -64,18 +107,95 @@ func RunLogPathOpen(projectName, runID string) (*os.File, error) {
 	return os.OpenFile(RunLogPath(projectName, runID), os.O_CREATE|os.O_RDWR, 0o644)
 }
 
+func SupervisorLogPath(projectName, runID string) string {
+	p := filepath.Join(logsPath(projectName), "supervisor-run-"+runID+".log")
+	ensureFile(p)
+	return p
+}
+
 func AgentLogsPathOnHost(projectName, runID string) string {
 	p := filepath.Join(logsPath(projectName), "agent-run-"+runID+".log")
 	ensureFile(p)
 	return p
 }
 
+// AuditLogPath returns the JSONL network audit log of the run.
+func AuditLogPath(projectName, runID string) string {
+	return filepath.Join(logsPath(projectName), "audit-run-"+runID+".jsonl")
+}
+
+// AuditLogPaths returns audit logs of all runs of the project.
+func AuditLogPaths(projectName string) ([]string, error) {
+	return filepath.Glob(filepath.Join(logsPath(projectName), "audit-run-*.jsonl"))
+}
+
+// MaskFilePath returns the empty file bound read-only over masked project files.
+func MaskFilePath() string {
+	p := filepath.Join(ConfigBasePath(), "mask", "empty")
+	ensureFile(p)
+	return p
+}
+
+// MaskFolderPath returns the empty folder bound read-only over masked project folders.
+func MaskFolderPath() string {
+	p := filepath.Join(ConfigBasePath(), "mask", "empty.d")
+	ensureFolder(p)
+	return p
+}
+
 func AgentBinaryPath(projectName string) string {
 	p := filepath.Join(ProjectDataPath(projectName), "bin")
 	ensureFolder(p)
 	return p
 }
 
+// SupervisorSocketPath returns the unix socket the project's supervisor listens on.
+// When the path under the project data folder is too long for a unix socket it falls back to
+// $XDG_RUNTIME_DIR, or to a folder in the temp dir only the user can access.
+func SupervisorSocketPath(projectName string) (string, error) {
+	p := filepath.Join(ProjectDataPath(projectName), "supervisor.sock")
+	if len(p) <= maxUnixSocketPathLen {
+		ensureFolder(filepath.Dir(p))
+		return p, nil
+	}
+
+	sum := sha256.Sum256([]byte(projectName))
+	name := "mkenv-" + hex.EncodeToString(sum[:8]) + ".sock"
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && len(filepath.Join(dir, name)) <= maxUnixSocketPathLen {
+		return filepath.Join(dir, name), nil
+	}
+
+	dir, err := userTempFolder()
+	if err != nil {
+		return "", fmt.Errorf("no safe place for the supervisor socket of %s: %w", projectName, err)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// userTempFolder returns a folder in the temp dir owned by the user and closed to everyone else.
+// The temp dir is shared, so a folder somebody else created in advance is refused.
+func userTempFolder() (string, error) {
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("mkenv-%d", os.Getuid()))
+	if err := os.Mkdir(dir, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", err
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a folder", dir)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != os.Getuid() {
+		return "", fmt.Errorf("%s is not owned by the current user", dir)
+	}
+	if info.Mode().Perm() != 0o700 {
+		return "", fmt.Errorf("%s must only be accessible by the current user (mode %o)", dir, info.Mode().Perm())
+	}
+	return dir, nil
+}
+
 func ContainerProxyPort() int {
 	return 45454
 }
@@ -0,0 +1,43 @@
+// Tests in this file exercise where the supervisor socket is placed.
+package hostappconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSupervisorSocketPathFallback(t *testing.T) {
+	t.Setenv("HOME", filepath.Join(t.TempDir(), strings.Repeat("long", 30)))
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	path, err := SupervisorSocketPath("demo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dir := filepath.Join(tmp, fmt.Sprintf("mkenv-%d", os.Getuid()))
+	if filepath.Dir(path) != dir {
+		t.Fatalf("expected the socket in %s, got %s", dir, path)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0o700 {
+		t.Fatalf("expected %s to be created with mode 0700: %v %v", dir, info, err)
+	}
+
+	// a folder other users can write to is refused
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if _, err := SupervisorSocketPath("demo"); err == nil {
+		t.Fatalf("expected a world writable folder to be refused")
+	}
+
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	if path, err := SupervisorSocketPath("demo"); err != nil || filepath.Dir(path) != runtimeDir {
+		t.Fatalf("expected the socket in %s, got %s (%v)", runtimeDir, path, err)
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"strconv"
 	"strings"
 
+	sandboxappconfig "github.com/0xa1bed0/mkenv/internal/apps/sandbox/config"
 	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/sandbox"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
 	"github.com/spf13/cobra"
 )
@@ -58,6 +60,16 @@ func daemonCmdRunE(cmd *cobra.Command, args []string) error {
 	portsOrchestrator.StartPrebindLoop()
 	portsOrchestrator.StartSnapshotReporter()
 
+	// host sets SSH_AUTH_SOCK only if it has an ssh agent to forward
+	if os.Getenv("SSH_AUTH_SOCK") == sandboxappconfig.SSHAgentSocket {
+		rt.GoNamed("SSHAgent", func() {
+			err := sandbox.ServeSSHAgent(rt.Ctx(), rt, portsOrchestrator.controlConn, sandboxappconfig.SSHAgentSocket)
+			if err != nil {
+				logs.Errorf("ssh agent forwarding stopped: %v", err)
+			}
+		})
+	}
+
 	rt.Wait()
 	logs.Infof("daemon exiting")
 	return nil
@@ -12,20 +12,30 @@ import (
 
 	"github.com/0xa1bed0/mkenv/internal/logs"
 	"github.com/0xa1bed0/mkenv/internal/networking/sandbox"
+	"github.com/0xa1bed0/mkenv/internal/networking/transport"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
 )
 
+// reverseProxyDialTimeout is how long connecting to the host reverse proxy may take.
+const reverseProxyDialTimeout = 5 * time.Second
+
 type prebinds struct {
 	mu               sync.Mutex
 	ports            map[int]io.Closer
 	reverseProxyAddr string
+	// reverseProxy carries connections of all reverse forwarders to the host
+	reverseProxy *transport.MuxDialer
 }
 
 func newPrebinds(reverseProxyAddr string) *prebinds {
-	return &prebinds{
+	p := &prebinds{
 		ports:            map[int]io.Closer{},
 		reverseProxyAddr: reverseProxyAddr,
 	}
+	if reverseProxyAddr != "" {
+		p.reverseProxy = transport.NewMuxDialer(reverseProxyAddr, 1, 1, reverseProxyDialTimeout)
+	}
+	return p
 }
 
 func (p *prebinds) Has(port int) bool {
@@ -60,7 +70,7 @@ func (p *prebinds) Add(port int) {
 	if p.reverseProxyAddr != "" {
 		logs.Infof("creating reverse forwarder for host port %d -> %s", port, p.reverseProxyAddr)
 
-		forwarder := sandbox.NewReverseForwarder(port, p.reverseProxyAddr)
+		forwarder := sandbox.NewReverseForwarder(port, p.reverseProxy)
 		if err := forwarder.Start(); err != nil {
 			logs.Errorf("can't start reverse forwarder on port %d: %v", port, err)
 			return
@@ -122,8 +132,15 @@ func newPortsOrchestrator(rt *runtime.Runtime) *portsOrchestrator {
 		logs.Infof("Reverse proxy enabled, will forward to %s", reverseProxyAddr)
 	}
 
+	prebinds := newPrebinds(reverseProxyAddr)
+	if prebinds.reverseProxy != nil {
+		rt.OnShutdown(func(context.Context) {
+			_ = prebinds.reverseProxy.Close()
+		})
+	}
+
 	return &portsOrchestrator{
-		prebinds:    newPrebinds(reverseProxyAddr),
+		prebinds:    prebinds,
 		rt:          rt,
 		controlConn: conn,
 	}
@@ -218,6 +235,13 @@ func (po *portsOrchestrator) StartSnapshotReporter() {
 					}
 				}
 
+				// udp ports are forwarded best-effort, the process is never killed for them
+				for port, bindResult := range resp.UDPResponse {
+					if bindResult != "ok" {
+						logs.Debugf("udp port %d is not forwarded: %s", port, bindResult)
+					}
+				}
+
 				// if port was in snapshot but host ignores it
 				for port := range snap.Listeners {
 					if _, ok := resp.Response[port]; !ok {
@@ -0,0 +1,187 @@
+package egress
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	sandboxappconfig "github.com/0xa1bed0/mkenv/internal/apps/sandbox/config"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/spf13/cobra"
+)
+
+// hostGatewayName resolves to the host from inside the container (docker --add-host host.docker.internal:host-gateway).
+const hostGatewayName = "host.docker.internal"
+
+func NewEgressLockdownCmd() *cobra.Command {
+	var hostPorts []int
+
+	cmd := &cobra.Command{
+		Use:   "egress-lockdown",
+		Short: "Drop outbound traffic except to mkenv host services",
+		Long: `Installs firewall rules which reject every outbound connection of the container
+except loopback, replies to inbound connections and the given ports of the host
+(control plane, reverse proxy and egress proxy).
+
+The host runs it as root right after the container starts in egress mode,
+the container entrypoint (see egress-wait) doesn't start anything before it's done.
+The container user has no capabilities to change the rules afterwards.`,
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if os.Geteuid() != 0 {
+				return errors.New("egress lockdown must run as root")
+			}
+			if len(hostPorts) == 0 {
+				return errors.New("at least one --host-port is required")
+			}
+
+			hostIPs, err := net.LookupIP(hostGatewayName)
+			if err != nil {
+				return fmt.Errorf("resolve %s: %w", hostGatewayName, err)
+			}
+
+			var v4, v6 []string
+			for _, ip := range hostIPs {
+				if ip.To4() != nil {
+					v4 = append(v4, ip.String())
+				} else {
+					v6 = append(v6, ip.String())
+				}
+			}
+
+			if err := applyRules("iptables", "icmp-port-unreachable", v4, hostPorts); err != nil {
+				return err
+			}
+
+			if err := applyRules("ip6tables", "icmp6-port-unreachable", v6, hostPorts); err != nil {
+				if hasIPv6() {
+					return err
+				}
+				// no IPv6 in the container, so nothing to leak through
+				logs.Debugf("ip6tables is not available, container has no ipv6: %v", err)
+			}
+
+			// the folder is a root-owned tmpfs, the container user can't create the marker
+			if err := os.WriteFile(sandboxappconfig.EgressLockdownMarker, nil, 0o644); err != nil {
+				return fmt.Errorf("mark egress lockdown: %w", err)
+			}
+
+			logs.Infof("egress lockdown applied: only %s ports %v are reachable directly", hostGatewayName, hostPorts)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntSliceVar(&hostPorts, "host-port", nil, "Host port the container may connect to (repeatable)")
+
+	return cmd
+}
+
+func NewEgressWaitCmd() *cobra.Command {
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "egress-wait [-- COMMAND [ARG...]]",
+		Short: "Wait for the egress lockdown and run the container command",
+		Long: `Entrypoint of egress mode containers: waits until egress-lockdown has installed
+the firewall and replaces itself with COMMAND (the entrypoint and command of the image).
+
+Nothing of the container user runs before the firewall is in place. If the lockdown
+doesn't happen in time the container exits.`,
+		Hidden: true,
+		Args:   cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := waitEgressLockdown(timeout); err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				return nil
+			}
+
+			bin, err := exec.LookPath(args[0])
+			if err != nil {
+				return err
+			}
+			return syscall.Exec(bin, args, os.Environ())
+		},
+	}
+
+	cmd.Flags().DurationVar(&timeout, "timeout", egressWaitTimeout, "How long to wait for the lockdown")
+
+	return cmd
+}
+
+// egressWaitTimeout is how long the entrypoint waits for the host to run egress-lockdown.
+const egressWaitTimeout = time.Minute
+
+func waitEgressLockdown(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		info, err := os.Stat(sandboxappconfig.EgressLockdownMarker)
+		if err == nil {
+			// only root can create it, anything else is not a lockdown
+			if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid == 0 {
+				return nil
+			}
+			return fmt.Errorf("%s is not owned by root", sandboxappconfig.EgressLockdownMarker)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return errors.New("egress lockdown was not applied in time")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func applyRules(bin, rejectWith string, hostIPs []string, hostPorts []int) error {
+	rules := [][]string{
+		{"-F", "OUTPUT"},
+		{"-A", "OUTPUT", "-o", "lo", "-j", "ACCEPT"},
+		// replies to connections coming from the host (forwarded ports)
+		{"-A", "OUTPUT", "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"},
+	}
+	for _, ip := range hostIPs {
+		for _, port := range hostPorts {
+			rules = append(rules, []string{"-A", "OUTPUT", "-p", "tcp", "-d", ip, "--dport", strconv.Itoa(port), "-j", "ACCEPT"})
+		}
+	}
+	rules = append(rules,
+		[]string{"-A", "OUTPUT", "-p", "tcp", "-j", "REJECT", "--reject-with", "tcp-reset"},
+		[]string{"-A", "OUTPUT", "-j", "REJECT", "--reject-with", rejectWith},
+	)
+
+	for _, rule := range rules {
+		out, err := exec.Command(bin, append([]string{"-w"}, rule...)...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s %s: %w: %s", bin, strings.Join(rule, " "), err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// hasIPv6 returns true if the container has a non-loopback IPv6 address.
+func hasIPv6() bool {
+	f, err := os.Open("/proc/net/if_inet6")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[len(fields)-1] != "lo" {
+			return true
+		}
+	}
+	return false
+}
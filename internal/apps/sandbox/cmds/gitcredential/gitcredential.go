@@ -0,0 +1,98 @@
+package gitcredential
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/networking/sandbox"
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+	"github.com/spf13/cobra"
+)
+
+func NewGitCredentialCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "git-credential <get|store|erase>",
+		Short: "Git credential helper backed by the host",
+		Long: `Git credential helper which asks the host to supply credentials.
+
+Credentials are only supplied for hosts allowed by the host policy (git_credential_hosts)
+and every request has to be approved on the host. mkenv configures git to use this helper
+for the allowed hosts automatically.
+
+store and erase are no-op: credentials are managed on the host.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"get", "store", "erase"},
+		RunE:      runGitCredential,
+	}
+
+	return cmd
+}
+
+func runGitCredential(cmd *cobra.Command, args []string) error {
+	if args[0] != "get" {
+		// drain input so git does not get EPIPE
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		return nil
+	}
+
+	request, err := readGitCredentialRequest(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	controlClient, err := sandbox.NewControlClientFromEnv(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer controlClient.Close()
+
+	// leave the user time to answer the approval prompt on the host
+	ctx, cancel := context.WithTimeout(cmd.Context(), 2*time.Minute)
+	defer cancel()
+
+	response, err := controlClient.GitCredential(ctx, request)
+	if err != nil {
+		return fmt.Errorf("git credential: %w", err)
+	}
+
+	if response.Username != "" {
+		fmt.Printf("username=%s\n", response.Username)
+	}
+	fmt.Printf("password=%s\n", response.Password)
+
+	return nil
+}
+
+// readGitCredentialRequest reads git credential helper input: key=value lines terminated by an empty line.
+func readGitCredentialRequest(r io.Reader) (shared.GitCredentialRequest, error) {
+	var request shared.GitCredentialRequest
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "protocol":
+			request.Protocol = value
+		case "host":
+			request.Host = value
+		case "path":
+			request.Path = value
+		case "username":
+			request.Username = value
+		}
+	}
+
+	return request, scanner.Err()
+}
@@ -2,7 +2,9 @@ package sandbox
 
 import (
 	"github.com/0xa1bed0/mkenv/internal/apps/sandbox/cmds/daemon"
+	"github.com/0xa1bed0/mkenv/internal/apps/sandbox/cmds/egress"
 	"github.com/0xa1bed0/mkenv/internal/apps/sandbox/cmds/expose"
+	"github.com/0xa1bed0/mkenv/internal/apps/sandbox/cmds/gitcredential"
 	"github.com/0xa1bed0/mkenv/internal/apps/sandbox/cmds/install"
 	logscmd "github.com/0xa1bed0/mkenv/internal/apps/sandbox/cmds/logs"
 	"github.com/0xa1bed0/mkenv/internal/logs"
@@ -41,6 +43,9 @@ This cli provides auditable, secure sandbox escape route for developer convinien
 	sandbox.AddCommand(expose.NewExposeCmd())
 	sandbox.AddCommand(install.NewInstallCmd())
 	sandbox.AddCommand(logscmd.NewLogsCmd())
+	sandbox.AddCommand(gitcredential.NewGitCredentialCmd())
+	sandbox.AddCommand(egress.NewEgressLockdownCmd())
+	sandbox.AddCommand(egress.NewEgressWaitCmd())
 
 	rootCmd.AddCommand(sandbox)
 
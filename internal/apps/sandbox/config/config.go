@@ -8,3 +8,11 @@ const UserLocalBin = "/home/dev/local/bin"
 
 const HostRunLogFile = "/home/dev/.local/state/mkenv/host-run.log"
 const DaemonBinFolder = "/home/dev/.local/share/mkenv"
+
+// SSHAgentSocket is where the sandbox daemon serves the SSH agent forwarded from the host.
+const SSHAgentSocket = "/home/dev/.local/state/mkenv/ssh-agent.sock"
+
+// EgressLockdownDir is a root-owned tmpfs of egress mode containers. The lockdown creates EgressLockdownMarker in it
+// once the firewall is installed, the container entrypoint waits for it before starting anything.
+const EgressLockdownDir = "/run/mkenv"
+const EgressLockdownMarker = EgressLockdownDir + "/egress-lockdown"
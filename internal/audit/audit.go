@@ -0,0 +1,176 @@
+// Package audit records every connection mkenv proxies between the host, the sandbox and the internet
+// as a JSONL stream, one file per run.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+)
+
+// Direction tells which side opened the connection and where it went.
+type Direction string
+
+const (
+	// DirectionHostToSandbox is a host client connected to a port forwarded from the sandbox.
+	DirectionHostToSandbox Direction = "host->sandbox"
+	// DirectionSandboxToHost is a sandbox process connected to a host port through the reverse proxy.
+	DirectionSandboxToHost Direction = "sandbox->host"
+	// DirectionSandboxToInternet is a sandbox request going through the egress proxy.
+	DirectionSandboxToInternet Direction = "sandbox->internet"
+)
+
+// Decision is what the policy decided about the connection.
+type Decision string
+
+const (
+	DecisionAllow Decision = "allow"
+	DecisionDeny  Decision = "deny"
+)
+
+// Record describes a single proxied connection (or UDP session).
+type Record struct {
+	RunID     string       `json:"run_id"`
+	Direction Direction    `json:"direction"`
+	Proto     shared.Proto `json:"proto"`
+	Port      int          `json:"port"`
+	Host      string       `json:"host,omitempty"`   // destination host of egress requests
+	Remote    string       `json:"remote,omitempty"` // address of the connecting client
+	Start     time.Time    `json:"start"`
+	End       time.Time    `json:"end"`
+
+	BytesToSandbox   int64 `json:"bytes_to_sandbox"`
+	BytesFromSandbox int64 `json:"bytes_from_sandbox"`
+
+	Decision Decision `json:"decision"`
+	Reason   string   `json:"reason,omitempty"`
+
+	// sandbox process that opened the connection, only known for sandbox->host traffic
+	PID int    `json:"pid,omitempty"`
+	Cmd string `json:"cmd,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Log appends records to the audit file of a run. A nil *Log discards records.
+type Log struct {
+	runID string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens (or creates) the audit file at path for appending.
+func Open(path, runID string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	return &Log{runID: runID, f: f}, nil
+}
+
+// Write appends rec to the log. Errors are returned but callers usually only report them:
+// a broken audit file must not break the proxied connection.
+func (l *Log) Write(rec Record) error {
+	if l == nil {
+		return nil
+	}
+	if rec.RunID == "" {
+		rec.RunID = l.runID
+	}
+	if rec.End.IsZero() {
+		rec.End = time.Now()
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	_, err = l.f.Write(line)
+	return err
+}
+
+// Close closes the underlying file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// ReadFile returns records of the audit file at path matching filter.
+// Malformed lines (e.g. a line cut by a crash) are skipped.
+func ReadFile(path string, filter Filter) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := []Record{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if filter.Match(rec) {
+			out = append(out, rec)
+		}
+	}
+	return out, scanner.Err()
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	Since     time.Time
+	Until     time.Time
+	Port      int
+	Decision  Decision
+	Direction Direction
+}
+
+// Match returns true if rec is selected by the filter.
+// A connection matches a time range if it was open at any moment within it.
+func (f Filter) Match(rec Record) bool {
+	if !f.Since.IsZero() && rec.End.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && rec.Start.After(f.Until) {
+		return false
+	}
+	if f.Port != 0 && rec.Port != f.Port {
+		return false
+	}
+	if f.Decision != "" && rec.Decision != f.Decision {
+		return false
+	}
+	if f.Direction != "" && rec.Direction != f.Direction {
+		return false
+	}
+	return true
+}
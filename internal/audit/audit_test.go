@@ -0,0 +1,106 @@
+// Tests in this file exercise writing and querying audit logs.
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+)
+
+func TestLogWriteAndRead(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "logs", "audit-run-1.jsonl")
+	log, err := Open(path, "run-1")
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	start := time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Direction: DirectionSandboxToHost, Proto: shared.ProtoTCP, Port: 5432, Start: start, End: start.Add(time.Second), Decision: DecisionAllow, PID: 42, Cmd: "psql", BytesFromSandbox: 10, BytesToSandbox: 20},
+		{Direction: DirectionSandboxToHost, Proto: shared.ProtoTCP, Port: 22, Start: start.Add(time.Hour), Decision: DecisionDeny, Reason: "policy"},
+		{Direction: DirectionHostToSandbox, Proto: shared.ProtoUDP, Port: 5353, Start: start.Add(2 * time.Hour), End: start.Add(3 * time.Hour), Decision: DecisionAllow},
+	}
+	for _, rec := range records {
+		if err := log.Write(rec); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	all, err := ReadFile(path, Filter{})
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+	if len(all) != len(records) {
+		t.Fatalf("ReadFile returned %d records, want %d", len(all), len(records))
+	}
+	if all[0].RunID != "run-1" || all[0].PID != 42 || all[0].Cmd != "psql" || all[0].BytesToSandbox != 20 {
+		t.Fatalf("unexpected first record: %+v", all[0])
+	}
+	if all[1].End.IsZero() {
+		t.Fatal("Write must set End of records without it")
+	}
+
+	denied, err := ReadFile(path, Filter{Decision: DecisionDeny})
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+	if len(denied) != 1 || denied[0].Port != 22 {
+		t.Fatalf("deny filter returned %+v", denied)
+	}
+}
+
+func TestReadFileSkipsMalformedLines(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	content := `{"direction":"host->sandbox","port":3000,"decision":"allow"}
+{"direction":"host->sand
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadFile(path, Filter{})
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+	if len(got) != 1 || got[0].Port != 3000 {
+		t.Fatalf("ReadFile returned %+v", got)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+	rec := Record{Direction: DirectionSandboxToHost, Port: 5432, Start: start, End: start.Add(time.Hour), Decision: DecisionAllow}
+
+	cases := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty", Filter{}, true},
+		{"port", Filter{Port: 5432}, true},
+		{"other port", Filter{Port: 3000}, false},
+		{"direction", Filter{Direction: DirectionHostToSandbox}, false},
+		{"decision", Filter{Decision: DecisionDeny}, false},
+		{"open during since", Filter{Since: start.Add(30 * time.Minute)}, true},
+		{"ended before since", Filter{Since: start.Add(2 * time.Hour)}, false},
+		{"started after until", Filter{Until: start.Add(-time.Minute)}, false},
+		{"within range", Filter{Since: start.Add(-time.Hour), Until: start.Add(time.Minute)}, true},
+	}
+	for _, tc := range cases {
+		if got := tc.filter.Match(rec); got != tc.want {
+			t.Errorf("%s: Match = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
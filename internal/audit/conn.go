@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// CountingConn counts bytes read from and written to the wrapped connection.
+type CountingConn struct {
+	net.Conn
+
+	read    atomic.Int64
+	written atomic.Int64
+}
+
+// NewCountingConn wraps conn.
+func NewCountingConn(conn net.Conn) *CountingConn {
+	return &CountingConn{Conn: conn}
+}
+
+func (c *CountingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *CountingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// CloseWrite half-closes the wrapped connection if it supports it.
+func (c *CountingConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+// BytesRead returns how many bytes were read from the connection.
+func (c *CountingConn) BytesRead() int64 {
+	return c.read.Load()
+}
+
+// BytesWritten returns how many bytes were written to the connection.
+func (c *CountingConn) BytesWritten() int64 {
+	return c.written.Load()
+}
@@ -8,8 +8,9 @@ import (
 )
 
 const (
-	golangID          = bricksengine.BrickID("golang")
-	golangDescription = "Golang toolchain"
+	golangID             = bricksengine.BrickID("golang")
+	golangDescription    = "Golang toolchain"
+	golangDefaultVersion = "go1.25.3"
 )
 
 var golangKinds = []bricksengine.BrickKind{bricksengine.BrickKindCommon}
@@ -20,7 +21,7 @@ func NewGolang(metadata map[string]string) (bricksengine.Brick, error) {
 	}
 	version, ok := metadata["version"]
 	if !ok || version == "" {
-		version = "go1.25.3"
+		version = golangDefaultVersion
 	} else {
 		version = "go" + strings.Replace(version, "go", "", 1)
 	}
@@ -104,8 +105,16 @@ func (gd *golangDetector) Scan(folderPtr filesmanager.FileManager) (bricksengine
 	return "", nil, nil
 }
 
+// Evidence implements bricksengine.DetectionExplainer.
+func (gd *golangDetector) Evidence() []string {
+	return gd.langDetector.Evidence()
+}
+
 func init() {
 	bricksengine.RegisterBrick(golangID, NewGolang)
+	bricksengine.RegisterConfigSchema(golangID, bricksengine.ConfigSchema{
+		{Key: "version", Type: bricksengine.ConfigTypeVersion, Default: golangDefaultVersion, Description: "Go version installed with gvm"},
+	})
 	bricksengine.RegisterDetector(func() bricksengine.BrickDetector {
 		return &golangDetector{langDetector: bricksengine.NewLangDetector(string(golangID), "go.mod", "go", "go ", bricksengine.WithVersionSemantics(bricksengine.VersionSemanticsMinimum))}
 	})
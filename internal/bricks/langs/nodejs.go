@@ -1,28 +1,34 @@
 package langs
 
 import (
+	"slices"
+
 	"github.com/0xa1bed0/mkenv/internal/bricksengine"
 	"github.com/0xa1bed0/mkenv/internal/filesmanager"
 	"github.com/0xa1bed0/mkenv/internal/versions"
 )
 
 const (
-	nodejsID          = bricksengine.BrickID("nodejs")
-	nodejsDescription = "Golang toolchain"
+	NodejsID             = bricksengine.BrickID("nodejs")
+	nodejsDescription    = "Node.js toolchain"
+	nodejsDefaultVersion = "lts/*"
 )
 
 var nodejsKinds = []bricksengine.BrickKind{bricksengine.BrickKindCommon}
 
+// NodejsVersionAliases are nvm version names accepted besides versions, for bricks that pass a version to nodejs.
+var NodejsVersionAliases = []string{"lts/*", "node"}
+
 func NewNodejs(metadata map[string]string) (bricksengine.Brick, error) {
 	if metadata == nil {
 		metadata = make(map[string]string)
 	}
 	version, ok := metadata["version"]
 	if !ok || version == "" {
-		version = "lts/*"
+		version = nodejsDefaultVersion
 	}
 
-	brick, err := bricksengine.NewBrick(nodejsID, nodejsDescription,
+	brick, err := bricksengine.NewBrick(NodejsID, nodejsDescription,
 		bricksengine.WithKinds(nodejsKinds),
 		bricksengine.WithPackageRequest(bricksengine.PackageRequest{
 			Reason: "nvm install dependencies",
@@ -92,7 +98,7 @@ type nodejsDetector struct {
 }
 
 func (*nodejsDetector) BrickInfo() *bricksengine.BrickInfo {
-	return bricksengine.NewBrickInfo(nodejsID, nodejsDescription, nodejsKinds)
+	return bricksengine.NewBrickInfo(NodejsID, nodejsDescription, nodejsKinds)
 }
 
 func (nd *nodejsDetector) Scan(folderPtr filesmanager.FileManager) (bricksengine.BrickID, map[string]string, error) {
@@ -140,15 +146,29 @@ func (nd *nodejsDetector) Scan(folderPtr filesmanager.FileManager) (bricksengine
 		finalMeta = map[string]string{"version": npmrcVersion}
 	}
 
-	return nodejsID, finalMeta, nil
+	return NodejsID, finalMeta, nil
+}
+
+// Evidence implements bricksengine.DetectionExplainer.
+func (nd *nodejsDetector) Evidence() []string {
+	evidence := nd.packageJsonDetector.Evidence()
+	for _, e := range nd.npmrcDetector.Evidence() {
+		if !slices.Contains(evidence, e) {
+			evidence = append(evidence, e)
+		}
+	}
+	return evidence
 }
 
 func init() {
-	bricksengine.RegisterBrick(nodejsID, NewNodejs)
+	bricksengine.RegisterBrick(NodejsID, NewNodejs)
+	bricksengine.RegisterConfigSchema(NodejsID, bricksengine.ConfigSchema{
+		{Key: "version", Type: bricksengine.ConfigTypeVersion, Default: nodejsDefaultVersion, Allowed: NodejsVersionAliases, Description: "Node.js version installed with nvm"},
+	})
 	bricksengine.RegisterDetector(func() bricksengine.BrickDetector {
 		return &nodejsDetector{
-			packageJsonDetector: bricksengine.NewLangDetector(string(nodejsID), "package.json", "html,htm,htmlx,htmx,js,ts,jsx", `"node": "`),
-			npmrcDetector:       bricksengine.NewLangDetector(string(nodejsID), ".npmrc", "html,htm,htmlx,htmx,js,ts,jsx", "node-version="),
+			packageJsonDetector: bricksengine.NewLangDetector(string(NodejsID), "package.json", "html,htm,htmlx,htmx,js,ts,jsx", `"node": "`),
+			npmrcDetector:       bricksengine.NewLangDetector(string(NodejsID), ".npmrc", "html,htm,htmlx,htmx,js,ts,jsx", "node-version="),
 		}
 	})
 }
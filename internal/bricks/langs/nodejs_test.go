@@ -176,8 +176,8 @@ func (s *stubFileScanner) unreadByte(b byte) {
 
 func createNodejsDetector() *nodejsDetector {
 	return &nodejsDetector{
-		packageJsonDetector: bricksengine.NewLangDetector(string(nodejsID), "package.json", "html,htm,htmlx,htmx,js,ts,jsx", `"node": "`),
-		npmrcDetector:       bricksengine.NewLangDetector(string(nodejsID), ".npmrc", "html,htm,htmlx,htmx,js,ts,jsx", "node-version="),
+		packageJsonDetector: bricksengine.NewLangDetector(string(NodejsID), "package.json", "html,htm,htmlx,htmx,js,ts,jsx", `"node": "`),
+		npmrcDetector:       bricksengine.NewLangDetector(string(NodejsID), ".npmrc", "html,htm,htmlx,htmx,js,ts,jsx", "node-version="),
 	}
 }
 
@@ -195,8 +195,8 @@ func TestNodejsDetector_NpmrcWithNodeVersion(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if brickID != nodejsID {
-		t.Errorf("expected brickID=%s, got %s", nodejsID, brickID)
+	if brickID != NodejsID {
+		t.Errorf("expected brickID=%s, got %s", NodejsID, brickID)
 	}
 	if meta["version"] != "18.0.0" {
 		t.Errorf("expected version=18.0.0, got %s", meta["version"])
@@ -217,8 +217,8 @@ func TestNodejsDetector_NpmrcWithoutNodeVersion(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if brickID != nodejsID {
-		t.Errorf("expected brickID=%s, got %s", nodejsID, brickID)
+	if brickID != NodejsID {
+		t.Errorf("expected brickID=%s, got %s", NodejsID, brickID)
 	}
 	// No version in .npmrc and no package.json with engine - meta may be nil
 	if meta != nil && meta["version"] != "" {
@@ -246,8 +246,8 @@ func TestNodejsDetector_PackageJsonAndNpmrc(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if brickID != nodejsID {
-		t.Errorf("expected brickID=%s, got %s", nodejsID, brickID)
+	if brickID != NodejsID {
+		t.Errorf("expected brickID=%s, got %s", NodejsID, brickID)
 	}
 	// Should pick max of 16.0.0 and 18.0.0
 	if meta["version"] != "18.0.0" {
@@ -270,8 +270,8 @@ func TestNodejsDetector_NpmrcInNodeModulesIgnored(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	// Should still detect nodejs due to .js file
-	if brickID != nodejsID {
-		t.Errorf("expected brickID=%s, got %s", nodejsID, brickID)
+	if brickID != NodejsID {
+		t.Errorf("expected brickID=%s, got %s", NodejsID, brickID)
 	}
 	// But version from node_modules/.npmrc should be ignored
 	if meta != nil && meta["version"] == "22.0.0" {
@@ -294,8 +294,8 @@ func TestNodejsDetector_OnlyJSFiles(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	// Should detect nodejs due to .js files
-	if brickID != nodejsID {
-		t.Errorf("expected brickID=%s, got %s", nodejsID, brickID)
+	if brickID != NodejsID {
+		t.Errorf("expected brickID=%s, got %s", NodejsID, brickID)
 	}
 	// No version source available
 	if meta != nil && meta["version"] != "" {
@@ -317,8 +317,8 @@ func TestNodejsDetector_NpmrcVersionWithConstraint(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if brickID != nodejsID {
-		t.Errorf("expected brickID=%s, got %s", nodejsID, brickID)
+	if brickID != NodejsID {
+		t.Errorf("expected brickID=%s, got %s", NodejsID, brickID)
 	}
 	// MaxVersionFromConstraints resolves constraint to minimum version
 	if meta["version"] != "18.0.0" {
@@ -59,8 +59,16 @@ func (gd *phpDetector) Scan(folderPtr filesmanager.FileManager) (bricksengine.Br
 	return "", nil, nil
 }
 
+// Evidence implements bricksengine.DetectionExplainer.
+func (gd *phpDetector) Evidence() []string {
+	return gd.langDetector.Evidence()
+}
+
 func init() {
 	bricksengine.RegisterBrick(phpID, NewPHP)
+	bricksengine.RegisterConfigSchema(phpID, bricksengine.ConfigSchema{
+		{Key: "version", Type: bricksengine.ConfigTypeVersion, Description: "PHP version, e.g. 8.2 installs the php8.2 package; the system's default PHP if unset"},
+	})
 	bricksengine.RegisterDetector(func() bricksengine.BrickDetector {
 		return &phpDetector{langDetector: bricksengine.NewLangDetector(string(phpID), "composer.json", "php", `"php": "`)}
 	})
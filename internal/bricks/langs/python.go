@@ -8,8 +8,9 @@ import (
 )
 
 const (
-	pythonID          = bricksengine.BrickID("python")
-	pythonDescription = "Python toolchain"
+	pythonID             = bricksengine.BrickID("python")
+	pythonDescription    = "Python toolchain"
+	pythonDefaultVersion = "3.12"
 )
 
 var pythonKinds = []bricksengine.BrickKind{bricksengine.BrickKindCommon}
@@ -20,7 +21,7 @@ func NewPython(metadata map[string]string) (bricksengine.Brick, error) {
 	}
 	version, ok := metadata["version"]
 	if !ok || version == "" {
-		version = "3.12"
+		version = pythonDefaultVersion
 	} else {
 		version = strings.TrimPrefix(version, "python")
 		version = strings.TrimPrefix(version, "py")
@@ -143,8 +144,16 @@ func (pd *pythonDetector) Scan(folderPtr filesmanager.FileManager) (bricksengine
 	return "", nil, nil
 }
 
+// Evidence implements bricksengine.DetectionExplainer.
+func (pd *pythonDetector) Evidence() []string {
+	return pd.langDetector.Evidence()
+}
+
 func init() {
 	bricksengine.RegisterBrick(pythonID, NewPython)
+	bricksengine.RegisterConfigSchema(pythonID, bricksengine.ConfigSchema{
+		{Key: "version", Type: bricksengine.ConfigTypeVersion, Default: pythonDefaultVersion, Description: "Python version installed with pyenv"},
+	})
 	bricksengine.RegisterDetector(func() bricksengine.BrickDetector {
 		return &pythonDetector{langDetector: bricksengine.NewLangDetector(string(pythonID), "requirements.txt,pyproject.toml,setup.py,Pipfile", "py", `python_requires`)}
 	})
@@ -52,4 +52,5 @@ source $ZSH/oh-my-zsh.sh
 
 func init() {
 	bricksengine.RegisterBrick(ohmyzsh, NewOhMyZsh)
+	bricksengine.RegisterConfigSchema(ohmyzsh, bricksengine.ConfigSchema{})
 }
@@ -43,4 +43,5 @@ func NewZsh(metadata map[string]string) (bricksengine.Brick, error) {
 
 func init() {
 	bricksengine.RegisterBrick(zsh, NewZsh)
+	bricksengine.RegisterConfigSchema(zsh, bricksengine.ConfigSchema{})
 }
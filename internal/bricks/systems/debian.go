@@ -2,7 +2,10 @@ package systems
 
 import "github.com/0xa1bed0/mkenv/internal/bricksengine"
 
-const debian = "debian"
+const (
+	debian            = "debian"
+	debianDefaultBase = "debian:bookworm-slim"
+)
 
 func NewDebian(metadata map[string]string) (bricksengine.Brick, error) {
 	if metadata == nil {
@@ -10,7 +13,7 @@ func NewDebian(metadata map[string]string) (bricksengine.Brick, error) {
 	}
 	base, baseExists := metadata["base"]
 	if !baseExists || base == "" {
-		base = "debian:bookworm-slim"
+		base = debianDefaultBase
 	}
 
 	brick, err := bricksengine.NewBrick(debian, "Debian OS",
@@ -54,4 +57,7 @@ export PATH="$PATH:$MKENV_LOCAL_BIN"`,
 
 func init() {
 	bricksengine.RegisterBrick(debian, NewDebian)
+	bricksengine.RegisterConfigSchema(debian, bricksengine.ConfigSchema{
+		{Key: "base", Type: bricksengine.ConfigTypeString, Default: debianDefaultBase, Description: "Debian based image the environment is built from"},
+	})
 }
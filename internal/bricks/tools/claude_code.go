@@ -9,6 +9,9 @@ import (
 
 const claudeCode = "claude-code"
 
+// npmDistTags are npm tags accepted besides versions of npm packages.
+var npmDistTags = []string{"latest", "next", "stable"}
+
 func NewClaudeCode(metadata map[string]string) (bricksengine.Brick, error) {
 	if metadata == nil {
 		metadata = make(map[string]string)
@@ -27,14 +30,10 @@ func NewClaudeCode(metadata map[string]string) (bricksengine.Brick, error) {
 		nodeMeta = nil
 	}
 
-	nodeBrick, err := langs.NewNodejs(nodeMeta)
-	if err != nil {
-		return nil, err
-	}
-
 	brick, err := bricksengine.NewBrick(claudeCode, "Claude Code CLI",
 		bricksengine.WithKind(bricksengine.BrickKindCommon),
-		bricksengine.WithBrick(nodeBrick),
+		// node is shared with the project's nodejs brick, versions are merged by the planner
+		bricksengine.WithRequires(langs.NodejsID, nodeMeta),
 		bricksengine.WithCacheFolder("${MKENV_HOME}/.npm"),
 		bricksengine.WithCacheFolder("${MKENV_HOME}/.claude"),
 		bricksengine.WithCacheFile("${MKENV_HOME}/.claude.json"),
@@ -56,4 +55,8 @@ ln -sf "$(npm bin -g)/claude" "${MKENV_LOCAL_BIN}/claude"`, packageSpec)},
 
 func init() {
 	bricksengine.RegisterBrick(claudeCode, NewClaudeCode)
+	bricksengine.RegisterConfigSchema(claudeCode, bricksengine.ConfigSchema{
+		{Key: "version", Type: bricksengine.ConfigTypeVersion, Allowed: npmDistTags, Description: "Version of the @anthropic-ai/claude-code npm package, latest if unset"},
+		{Key: "node_version", Type: bricksengine.ConfigTypeVersion, Allowed: langs.NodejsVersionAliases, Description: "Node.js version, merged with the one of the nodejs brick"},
+	})
 }
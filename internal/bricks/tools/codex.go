@@ -27,14 +27,10 @@ func NewCodex(metadata map[string]string) (bricksengine.Brick, error) {
 		nodeMeta = nil
 	}
 
-	nodeBrick, err := langs.NewNodejs(nodeMeta)
-	if err != nil {
-		return nil, err
-	}
-
 	brick, err := bricksengine.NewBrick(codex, "OpenAI Codex CLI",
 		bricksengine.WithKind(bricksengine.BrickKindCommon),
-		bricksengine.WithBrick(nodeBrick),
+		// node is shared with the project's nodejs brick, versions are merged by the planner
+		bricksengine.WithRequires(langs.NodejsID, nodeMeta),
 		bricksengine.WithCacheFolder("${MKENV_HOME}/.npm"),
 		bricksengine.WithCacheFolder("${MKENV_HOME}/.codex"),
 		bricksengine.WithUserRun(bricksengine.Command{
@@ -55,4 +51,8 @@ ln -sf "$(npm bin -g)/codex" "${MKENV_LOCAL_BIN}/codex"`, packageSpec)},
 
 func init() {
 	bricksengine.RegisterBrick(codex, NewCodex)
+	bricksengine.RegisterConfigSchema(codex, bricksengine.ConfigSchema{
+		{Key: "version", Type: bricksengine.ConfigTypeVersion, Allowed: npmDistTags, Description: "Version of the @openai/codex npm package, latest if unset"},
+		{Key: "node_version", Type: bricksengine.ConfigTypeVersion, Allowed: langs.NodejsVersionAliases, Description: "Node.js version, merged with the one of the nodejs brick"},
+	})
 }
@@ -78,4 +78,5 @@ export EDITOR="nvim"
 
 func init() {
 	bricksengine.RegisterBrick(nvim, NewNvim)
+	bricksengine.RegisterConfigSchema(nvim, bricksengine.ConfigSchema{})
 }
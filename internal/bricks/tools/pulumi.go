@@ -38,4 +38,5 @@ func NewPulumi(map[string]string) (bricksengine.Brick, error) {
 
 func init() {
 	bricksengine.RegisterBrick(pulumi, NewPulumi)
+	bricksengine.RegisterConfigSchema(pulumi, bricksengine.ConfigSchema{})
 }
@@ -36,4 +36,5 @@ func NewTmux(map[string]string) (bricksengine.Brick, error) {
 
 func init() {
 	bricksengine.RegisterBrick(tmux, NewTmux)
+	bricksengine.RegisterConfigSchema(tmux, bricksengine.ConfigSchema{})
 }
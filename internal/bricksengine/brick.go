@@ -32,6 +32,20 @@ type Brick interface {
 	Entrypoint() []string
 	AttachInstruction() []string
 	Cmd() []string
+
+	// Requires lists bricks this brick needs, they are added to the environment and set up before it.
+	Requires() []Dependency
+	// Conflicts lists bricks that can't be in the same environment.
+	Conflicts() []BrickID
+	// Provides lists bricks this brick replaces: it satisfies their dependents and conflicts with them.
+	Provides() []BrickID
+}
+
+// Dependency is a brick required by another brick. Metadata is passed to the factory of the required brick,
+// "version" constraints of all dependents are merged.
+type Dependency struct {
+	ID       BrickID           `json:"id" yaml:"id"`
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata"`
 }
 
 type Command struct {
@@ -86,6 +100,10 @@ type brick struct {
 	entrypoint        []string
 	cmd               []string
 	attachInstruction []string
+
+	requires  []Dependency
+	conflicts []BrickID
+	provides  []BrickID
 }
 
 func (b *brick) BaseImage() string       { return b.baseImage }
@@ -106,10 +124,13 @@ func (b *brick) PackageManager() PackageManager  { return b.packageManager }
 func (b *brick) Entrypoint() []string            { return copyStrings(b.entrypoint) }
 func (b *brick) AttachInstruction() []string     { return copyStrings(b.attachInstruction) }
 func (b *brick) Cmd() []string                   { return copyStrings(b.cmd) }
+func (b *brick) Requires() []Dependency          { return copyDependencies(b.requires) }
+func (b *brick) Conflicts() []BrickID            { return CopyBrickIDs(b.conflicts) }
+func (b *brick) Provides() []BrickID             { return CopyBrickIDs(b.provides) }
 
 // Safe-copy helpers
 func copyPackageRequests(r []PackageRequest) []PackageRequest {
-	out := make([]PackageRequest, len(r))
+	out := make([]PackageRequest, 0, len(r))
 	for _, request := range r {
 		out = append(out, request.Clone())
 	}
@@ -149,6 +170,14 @@ func copyFragments(src []FileTemplate) []FileTemplate {
 	return out
 }
 
+func copyDependencies(src []Dependency) []Dependency {
+	out := make([]Dependency, 0, len(src))
+	for _, dep := range src {
+		out = append(out, Dependency{ID: dep.ID, Metadata: copyMap(dep.Metadata)})
+	}
+	return out
+}
+
 func copyStrings(src []string) []string {
 	if src == nil {
 		return nil
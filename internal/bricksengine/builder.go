@@ -230,6 +230,31 @@ func WithPackageManager(manager PackageManager) BrickOption {
 	}
 }
 
+// WithRequires makes the brick require another brick, built with metadata. See Brick.Requires.
+func WithRequires(id BrickID, metadata map[string]string) BrickOption {
+	return func(bi *brick) error {
+		if id == bi.id {
+			return fmt.Errorf("[brick %s] can't require itself", bi.id)
+		}
+		bi.requires = append(bi.requires, Dependency{ID: id, Metadata: copyMap(metadata)})
+		return nil
+	}
+}
+
+func WithConflicts(ids ...BrickID) BrickOption {
+	return func(bi *brick) error {
+		bi.conflicts = UniqueSortedBricks(append(bi.conflicts, ids...))
+		return nil
+	}
+}
+
+func WithProvides(ids ...BrickID) BrickOption {
+	return func(bi *brick) error {
+		bi.provides = UniqueSortedBricks(append(bi.provides, ids...))
+		return nil
+	}
+}
+
 func WithBrick(b Brick) BrickOption {
 	return func(bi *brick) error {
 		WithKinds(b.Kinds().All())(bi)
@@ -243,6 +268,9 @@ func WithBrick(b Brick) BrickOption {
 		WithCmd(b.Cmd())(bi)
 		WithCacheFolders(b.CacheFolders())(bi)
 		WithCacheFiles(b.CacheFiles())(bi)
+		bi.requires = append(bi.requires, b.Requires()...)
+		WithConflicts(b.Conflicts()...)(bi)
+		WithProvides(b.Provides()...)(bi)
 
 		return nil
 	}
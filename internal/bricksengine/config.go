@@ -0,0 +1,158 @@
+package bricksengine
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/0xa1bed0/mkenv/internal/utils"
+	"github.com/Masterminds/semver/v3"
+)
+
+// ConfigType is the type of a bricks_config value. Values are always strings in .mkenv files and policies,
+// the type says how they are read.
+type ConfigType string
+
+const (
+	ConfigTypeString  ConfigType = "string"
+	ConfigTypeBool    ConfigType = "bool"
+	ConfigTypeInt     ConfigType = "int"
+	ConfigTypeVersion ConfigType = "version" // a version or constraint (e.g. 20, ^20.1.0, >=18), a name prefix like go1.22 is fine
+	ConfigTypeList    ConfigType = "list"    // comma separated values
+)
+
+// ErrUnknownBrick is returned when bricks_config is validated for a brick the registry doesn't know.
+var ErrUnknownBrick = errors.New("unknown brick")
+
+// ConfigKey describes a bricks_config key a brick accepts.
+type ConfigKey struct {
+	Key         string     `json:"key"`
+	Type        ConfigType `json:"type"`
+	Default     string     `json:"default,omitempty"`
+	Allowed     []string   `json:"allowed,omitempty"` // accepted values, '*' wildcards allowed; for version keys names accepted besides versions
+	Description string     `json:"description,omitempty"`
+}
+
+// ConfigSchema lists the bricks_config keys of a brick. Keys it doesn't list are errors.
+type ConfigSchema []ConfigKey
+
+// Key returns the key named key.
+func (s ConfigSchema) Key(key string) (ConfigKey, bool) {
+	for _, k := range s {
+		if k.Key == key {
+			return k, true
+		}
+	}
+	return ConfigKey{}, false
+}
+
+// ValidateValue checks that key is in the schema and value fits it.
+func (s ConfigSchema) ValidateValue(key, value string) error {
+	k, ok := s.Key(key)
+	if !ok {
+		if len(s) == 0 {
+			return fmt.Errorf("unknown key %q: the brick has no config", key)
+		}
+		return fmt.Errorf("unknown key %q (expected %s)", key, strings.Join(s.keys(), ", "))
+	}
+	return k.ValidateValue(value)
+}
+
+// Validate checks the schema itself: keys are unique, types are known and defaults are valid.
+func (s ConfigSchema) Validate() error {
+	seen := map[string]bool{}
+	for _, k := range s {
+		if k.Key == "" {
+			return errors.New("config key without a name")
+		}
+		if seen[k.Key] {
+			return fmt.Errorf("config key %s is declared twice", k.Key)
+		}
+		seen[k.Key] = true
+		switch k.Type {
+		case ConfigTypeString, ConfigTypeBool, ConfigTypeInt, ConfigTypeVersion, ConfigTypeList:
+		default:
+			return fmt.Errorf("config key %s: unknown type %q (expected string, bool, int, version or list)", k.Key, k.Type)
+		}
+		if k.Default != "" {
+			if err := k.ValidateValue(k.Default); err != nil {
+				return fmt.Errorf("config key %s: default: %w", k.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s ConfigSchema) keys() []string {
+	out := make([]string, 0, len(s))
+	for _, k := range s {
+		out = append(out, k.Key)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ValidateValue checks that value has the type of the key and is one of the allowed values.
+// An empty value means the default and is always valid.
+func (k ConfigKey) ValidateValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	switch k.Type {
+	case ConfigTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q is not a bool (expected true or false)", value)
+		}
+	case ConfigTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%q is not an int", value)
+		}
+	case ConfigTypeVersion:
+		if k.allows(value) {
+			return nil
+		}
+		if _, err := semver.NewConstraint(strings.TrimLeft(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")); err != nil {
+			if len(k.Allowed) > 0 {
+				return fmt.Errorf("%q is not a version or one of %s", value, strings.Join(k.Allowed, ", "))
+			}
+			return fmt.Errorf("%q is not a version", value)
+		}
+		return nil
+	case ConfigTypeList:
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				return fmt.Errorf("%q has an empty item", value)
+			}
+			if len(k.Allowed) > 0 && !k.allows(item) {
+				return fmt.Errorf("%q is not allowed (expected %s)", item, strings.Join(k.Allowed, ", "))
+			}
+		}
+		return nil
+	}
+	if len(k.Allowed) > 0 && !k.allows(value) {
+		return fmt.Errorf("%q is not allowed (expected %s)", value, strings.Join(k.Allowed, ", "))
+	}
+	return nil
+}
+
+func (k ConfigKey) allows(value string) bool {
+	for _, pattern := range k.Allowed {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema returns the config schema of the definition: every config key is a string with its default.
+func (d *BrickDefinition) Schema() ConfigSchema {
+	schema := ConfigSchema{}
+	for _, key := range utils.SortedKeys(d.Config) {
+		schema = append(schema, ConfigKey{Key: key, Type: ConfigTypeString, Default: d.Config[key]})
+	}
+	return schema
+}
@@ -0,0 +1,86 @@
+// Tests in this file exercise brick config schemas and validation of bricks_config values against them.
+package bricksengine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfigSchemaValidateValue(t *testing.T) {
+	t.Parallel()
+
+	schema := ConfigSchema{
+		{Key: "version", Type: ConfigTypeVersion, Allowed: []string{"lts/*"}},
+		{Key: "debug", Type: ConfigTypeBool},
+		{Key: "jobs", Type: ConfigTypeInt},
+		{Key: "channel", Type: ConfigTypeString, Allowed: []string{"stable", "beta"}},
+		{Key: "features", Type: ConfigTypeList, Allowed: []string{"a", "b"}},
+	}
+	if err := schema.Validate(); err != nil {
+		t.Fatalf("unexpected schema error: %v", err)
+	}
+
+	cases := []struct {
+		key, value string
+		wantErr    string
+	}{
+		{"version", "20", ""},
+		{"version", "^20.1.0", ""},
+		{"version", "go1.22", ""},
+		{"version", "lts/iron", ""},
+		{"version", "twenty", "not a version"},
+		{"debug", "true", ""},
+		{"debug", "yes", "not a bool"},
+		{"jobs", "4", ""},
+		{"jobs", "four", "not an int"},
+		{"channel", "beta", ""},
+		{"channel", "nightly", "not allowed"},
+		{"features", "a, b", ""},
+		{"features", "a,c", `"c" is not allowed`},
+		{"verison", "20", `unknown key "verison"`},
+		{"jobs", "", ""},
+	}
+	for _, c := range cases {
+		err := schema.ValidateValue(c.key, c.value)
+		if c.wantErr == "" && err != nil {
+			t.Fatalf("%s=%q: unexpected error: %v", c.key, c.value, err)
+		}
+		if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+			t.Fatalf("%s=%q: expected error containing %q, got %v", c.key, c.value, c.wantErr, err)
+		}
+	}
+
+	if err := (ConfigSchema{{Key: "x", Type: "float"}}).Validate(); err == nil {
+		t.Fatalf("expected an unknown type to fail")
+	}
+	if err := (ConfigSchema{{Key: "x", Type: ConfigTypeBool, Default: "maybe"}}).Validate(); err == nil {
+		t.Fatalf("expected an invalid default to fail")
+	}
+}
+
+func TestRegistryValidateBrickConfig(t *testing.T) {
+	t.Parallel()
+
+	r := newTestRegistry(t, map[BrickID][]BrickOption{"node": nil, "loose": nil})
+	r.schemas["node"] = ConfigSchema{{Key: "version", Type: ConfigTypeVersion}}
+	if err := r.RegisterDefinition(BrickDefinition{ID: "ruby", Description: "Ruby", Config: map[string]string{"version": "3.3"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := r.ValidateBrickConfig("node", "version", "20"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.ValidateBrickConfig("node", "node_version", "20"); err == nil {
+		t.Fatalf("expected an unknown key to fail")
+	}
+	if err := r.ValidateBrickConfig("ruby", "gems", "rails"); err == nil || !strings.Contains(err.Error(), "expected version") {
+		t.Fatalf("expected keys of the definition to be listed, got %v", err)
+	}
+	if err := r.ValidateBrickConfig("loose", "anything", "x"); err != nil {
+		t.Fatalf("expected a brick without schema to accept any key, got %v", err)
+	}
+	if err := r.ValidateBrickConfig("missing", "version", "1"); !errors.Is(err, ErrUnknownBrick) {
+		t.Fatalf("expected ErrUnknownBrick, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package bricksengine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BrickDefinition is a brick declared in a YAML or JSON file instead of Go code:
+// in the user's bricks folder or in the "bricks" list of a .mkenv file.
+type BrickDefinition struct {
+	ID                BrickID                  `json:"id" yaml:"id"`
+	Description       string                   `json:"description" yaml:"description"`
+	Kinds             []string                 `json:"kinds,omitempty" yaml:"kinds"`       // common (default), entrypoint or platform
+	Packages          []string                 `json:"packages,omitempty" yaml:"packages"` // installed by the package manager of the system brick
+	Envs              map[string]string        `json:"envs,omitempty" yaml:"envs"`
+	RootRun           []string                 `json:"root_run,omitempty" yaml:"root_run"` // shell scripts run as root at build time
+	UserRun           []string                 `json:"user_run,omitempty" yaml:"user_run"` // shell scripts run as the sandbox user at build time
+	Files             []FileTemplateDefinition `json:"files,omitempty" yaml:"files"`
+	CacheFolders      []string                 `json:"cache_folders,omitempty" yaml:"cache_folders"`
+	CacheFiles        []string                 `json:"cache_files,omitempty" yaml:"cache_files"`
+	Entrypoint        []string                 `json:"entrypoint,omitempty" yaml:"entrypoint"`
+	AttachInstruction []string                 `json:"attach,omitempty" yaml:"attach"`
+	Cmd               []string                 `json:"cmd,omitempty" yaml:"cmd"`
+	Config            map[string]string        `json:"config,omitempty" yaml:"config"` // bricks_config keys the brick accepts and their defaults, used as ${config.KEY}
+	Requires          []Dependency             `json:"requires,omitempty" yaml:"requires"`
+	Conflicts         []BrickID                `json:"conflicts,omitempty" yaml:"conflicts"`
+	Provides          []BrickID                `json:"provides,omitempty" yaml:"provides"`
+
+	Source string `json:"-" yaml:"-"` // file the brick is defined in
+}
+
+// FileTemplateDefinition is content appended to a file of the sandbox at build time.
+type FileTemplateDefinition struct {
+	Path    string `json:"path" yaml:"path"` // "rc" is the shell rc file
+	Content string `json:"content" yaml:"content"`
+}
+
+var (
+	brickIDRegexp           = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+	brickConfigRefRegexp    = regexp.MustCompile(`\$\{config\.([A-Za-z0-9_]+)\}`)
+	brickDefinitionFileExts = []string{".yaml", ".yml", ".json"}
+)
+
+var definitionKinds = map[string]BrickKind{
+	"common":     BrickKindCommon,
+	"entrypoint": BrickKindEntrypoint,
+	"platform":   BrickKindPlatform,
+}
+
+// Validate checks the definition. System bricks can't be declared: they need a package manager.
+func (d *BrickDefinition) Validate() error {
+	if !brickIDRegexp.MatchString(string(d.ID)) {
+		return fmt.Errorf("brick id %q must be lowercase letters, digits, '.', '_' or '-'", d.ID)
+	}
+	if d.Description == "" {
+		return fmt.Errorf("brick %s: description is required", d.ID)
+	}
+	for _, kind := range d.Kinds {
+		if _, ok := definitionKinds[kind]; !ok {
+			return fmt.Errorf("brick %s: unknown kind %q (expected common, entrypoint or platform)", d.ID, kind)
+		}
+	}
+	if len(d.Entrypoint) > 0 && len(d.AttachInstruction) == 0 {
+		return fmt.Errorf("brick %s: attach is required with entrypoint", d.ID)
+	}
+	for i, file := range d.Files {
+		if file.Path == "" {
+			return fmt.Errorf("brick %s: files[%d] has no path", d.ID, i)
+		}
+	}
+	for _, dep := range d.Requires {
+		if dep.ID == d.ID || !brickIDRegexp.MatchString(string(dep.ID)) {
+			return fmt.Errorf("brick %s: invalid requirement %q", d.ID, dep.ID)
+		}
+	}
+	for _, id := range append(append([]BrickID{}, d.Conflicts...), d.Provides...) {
+		if id == d.ID || !brickIDRegexp.MatchString(string(id)) {
+			return fmt.Errorf("brick %s: invalid brick id %q in conflicts or provides", d.ID, id)
+		}
+	}
+	for _, s := range d.templatedStrings() {
+		for _, match := range brickConfigRefRegexp.FindAllStringSubmatch(s, -1) {
+			if _, ok := d.Config[match[1]]; !ok {
+				return fmt.Errorf("brick %s: ${config.%s} is used but %s is not declared in config", d.ID, match[1], match[1])
+			}
+		}
+	}
+	return nil
+}
+
+// templatedStrings returns the strings ${config.KEY} placeholders are replaced in.
+func (d *BrickDefinition) templatedStrings() []string {
+	out := append([]string{}, d.RootRun...)
+	out = append(out, d.UserRun...)
+	for _, v := range d.Envs {
+		out = append(out, v)
+	}
+	for _, file := range d.Files {
+		out = append(out, file.Content)
+	}
+	return out
+}
+
+// Factory returns the factory of the brick. bricks_config of the brick fills ${config.KEY} placeholders,
+// keys that are not set use the defaults of the definition.
+func (d BrickDefinition) Factory() BrickFactory {
+	return func(metadata map[string]string) (Brick, error) {
+		config := copyMap(d.Config)
+		for k, v := range metadata {
+			if _, ok := config[k]; !ok {
+				return nil, fmt.Errorf("brick %s (%s) has no config key %q", d.ID, d.Source, k)
+			}
+			config[k] = v
+		}
+		expand := func(s string) string {
+			return brickConfigRefRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+				return config[brickConfigRefRegexp.FindStringSubmatch(ref)[1]]
+			})
+		}
+
+		kinds := []BrickKind{}
+		for _, kind := range d.Kinds {
+			kinds = append(kinds, definitionKinds[kind])
+		}
+		if len(kinds) == 0 {
+			kinds = append(kinds, BrickKindCommon)
+		}
+
+		opts := []BrickOption{WithKinds(kinds), WithCacheFolders(d.CacheFolders), WithCacheFiles(d.CacheFiles)}
+		if len(d.Packages) > 0 {
+			request := PackageRequest{Reason: fmt.Sprintf("%s dependencies", d.ID)}
+			for _, name := range d.Packages {
+				request.Packages = append(request.Packages, PackageSpec{Name: name})
+			}
+			opts = append(opts, WithPackageRequest(request))
+		}
+		envs := make(map[string]string, len(d.Envs))
+		for k, v := range d.Envs {
+			envs[k] = expand(v)
+		}
+		opts = append(opts, WithEnvs(envs))
+		for _, script := range d.RootRun {
+			opts = append(opts, WithRootRun(Command{When: "build", Argv: []string{"/bin/sh", "-c", expand(script)}}))
+		}
+		for _, script := range d.UserRun {
+			opts = append(opts, WithUserRun(Command{When: "build", Argv: []string{"/bin/sh", "-c", expand(script)}}))
+		}
+		for i, file := range d.Files {
+			opts = append(opts, WithFileTemplate(FileTemplate{
+				ID:       fmt.Sprintf("%s-%d", d.ID, i),
+				FilePath: file.Path,
+				Content:  expand(file.Content),
+			}))
+		}
+		if len(d.Entrypoint) > 0 {
+			opts = append(opts, WithEntrypoint(d.Entrypoint, d.AttachInstruction))
+		}
+		if len(d.Cmd) > 0 {
+			opts = append(opts, WithCmd(d.Cmd))
+		}
+		for _, dep := range d.Requires {
+			opts = append(opts, WithRequires(dep.ID, dep.Metadata))
+		}
+		opts = append(opts, WithConflicts(d.Conflicts...), WithProvides(d.Provides...))
+
+		return NewBrick(d.ID, d.Description, opts...)
+	}
+}
+
+// ParseBrickDefinition parses a brick definition in YAML or JSON. Unknown fields are errors.
+func ParseBrickDefinition(path string, data []byte) (*BrickDefinition, error) {
+	var def BrickDefinition
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&def); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("brick definition %s is empty", path)
+		}
+		return nil, fmt.Errorf("brick definition %s: %w", path, err)
+	}
+	def.Source = path
+	if err := def.Validate(); err != nil {
+		return nil, fmt.Errorf("brick definition %s: %w", path, err)
+	}
+	return &def, nil
+}
+
+// LoadBrickDefinitions parses the *.yaml, *.yml and *.json files of dir, one brick per file.
+// A missing folder has no bricks.
+func LoadBrickDefinitions(dir string) ([]BrickDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	defs := []BrickDefinition{}
+	seen := map[BrickID]string{}
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || !containsString(brickDefinitionFileExts, ext) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		def, err := ParseBrickDefinition(path, data)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[def.ID]; ok {
+			return nil, fmt.Errorf("brick %s is defined in both %s and %s", def.ID, other, path)
+		}
+		seen[def.ID] = path
+		defs = append(defs, *def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].ID < defs[j].ID })
+	return defs, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
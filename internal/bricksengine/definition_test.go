@@ -0,0 +1,123 @@
+// Tests in this file exercise parsing, validation and registration of declarative bricks.
+package bricksengine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const rubyBrickYAML = `id: ruby
+description: Ruby from the system packages
+kinds: [common]
+packages: [ruby-full]
+envs:
+  GEM_HOME: ${config.gem_home}
+user_run:
+  - gem install bundler -v ${config.bundler}
+files:
+  - path: rc
+    content: export PATH="$GEM_HOME/bin:$PATH"
+cache_folders: ["${MKENV_HOME}/.gem"]
+config:
+  gem_home: /home/dev/.gem
+  bundler: "2.5.0"
+`
+
+func TestParseBrickDefinitionBuildsBrick(t *testing.T) {
+	t.Parallel()
+
+	def, err := ParseBrickDefinition("ruby.yaml", []byte(rubyBrickYAML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err := def.Factory()(map[string]string{"bundler": "2.4.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.ID() != "ruby" || !b.Kinds().Contains(BrickKindCommon) {
+		t.Fatalf("unexpected brick %s with kinds %v", b.ID(), b.Kinds())
+	}
+	if got := b.Envs()["GEM_HOME"]; got != "/home/dev/.gem" {
+		t.Fatalf("expected default config in env, got %q", got)
+	}
+	if argv := b.UserRun()[0].Argv; argv[len(argv)-1] != "gem install bundler -v 2.4.1" {
+		t.Fatalf("expected bricks_config to fill the placeholder, got %v", argv)
+	}
+	if reqs := b.PackageRequests(); len(reqs) != 1 || reqs[0].Packages[0].Name != "ruby-full" {
+		t.Fatalf("unexpected package requests %v", reqs)
+	}
+	if tpl := b.FileTemplates(); len(tpl) != 1 || tpl[0].FilePath != "rc" {
+		t.Fatalf("unexpected file templates %v", tpl)
+	}
+
+	if _, err := def.Factory()(map[string]string{"unknown": "x"}); err == nil {
+		t.Fatalf("expected an error for an undeclared config key")
+	}
+}
+
+func TestParseBrickDefinitionRejectsInvalid(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"unknown field":        "id: x\ndescription: d\nroot_runs: [true]\n",
+		"bad id":               "id: X Y\ndescription: d\n",
+		"system kind":          "id: x\ndescription: d\nkinds: [system]\n",
+		"undeclared config":    "id: x\ndescription: d\nroot_run: ['echo ${config.v}']\n",
+		"entrypoint no attach": "id: x\ndescription: d\nentrypoint: [bash]\n",
+		"empty":                "",
+	}
+	for name, data := range cases {
+		if _, err := ParseBrickDefinition(name, []byte(data)); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadBrickDefinitionsAndRegister(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"ruby.yaml": rubyBrickYAML,
+		"tool.json": `{"id": "tool", "description": "A tool", "root_run": ["echo tool"]}`,
+		"README.md": "not a brick",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	defs, err := LoadBrickDefinitions(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(defs) != 2 || defs[0].ID != "ruby" || defs[1].ID != "tool" {
+		t.Fatalf("unexpected definitions %v", defs)
+	}
+	if defs[1].Source != filepath.Join(dir, "tool.json") {
+		t.Fatalf("expected source to be set, got %q", defs[1].Source)
+	}
+
+	if defs, err := LoadBrickDefinitions(filepath.Join(dir, "missing")); err != nil || len(defs) != 0 {
+		t.Fatalf("expected no bricks for a missing folder, got %v, %v", defs, err)
+	}
+
+	r := NewRegistry()
+	r.bricks["tool"] = defs[1].Factory()
+	if err := r.RegisterDefinition(defs[1]); err == nil || !strings.Contains(err.Error(), "built-in") {
+		t.Fatalf("expected built-in bricks to be protected, got %v", err)
+	}
+	if err := r.RegisterDefinition(defs[0]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.RegisterDefinition(defs[0]); err != nil {
+		t.Fatalf("expected a definition to be replaceable, got %v", err)
+	}
+	if ids := r.ListBrickIDs(); len(ids) != 2 || ids[0] != "ruby" {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+}
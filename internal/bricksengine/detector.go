@@ -2,6 +2,8 @@ package bricksengine
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"unicode"
 
 	"github.com/0xa1bed0/mkenv/internal/filesmanager"
@@ -17,6 +19,14 @@ type BrickDetector interface {
 
 type LangDetector interface {
 	ScanFiles(folderPtr filesmanager.FileManager) (found bool, brickMeta map[string]string, err error)
+	// Evidence returns what the last ScanFiles found, see DetectionExplainer.
+	Evidence() []string
+}
+
+// DetectionExplainer is implemented by detectors that can tell why their last Scan proposed a brick.
+type DetectionExplainer interface {
+	// Evidence returns what the last Scan found in the project, e.g. "go.mod requires >=1.22".
+	Evidence() []string
 }
 
 // VersionSemantics controls how detected version strings are interpreted.
@@ -45,6 +55,11 @@ type langDetector struct {
 	fileExtentions   string // coma separated (e.g. ts,js,jsx)
 	versionPrefix    string
 	versionSemantics VersionSemantics
+	evidence         []string
+}
+
+func (ld *langDetector) Evidence() []string {
+	return append([]string{}, ld.evidence...)
 }
 
 func (ld *langDetector) ScanFiles(folderPtr filesmanager.FileManager) (found bool, brickMeta map[string]string, err error) {
@@ -54,6 +69,7 @@ func (ld *langDetector) ScanFiles(folderPtr filesmanager.FileManager) (found boo
 		return false, nil, errors.New("at least one targetFile or fileExtentions has to be provided")
 	}
 	versionPrefix := ld.versionPrefix
+	ld.evidence = nil
 
 	// TODO: maybe we should have global set of folders we ignore?
 	ignorePath := []string{"vendor", "node_modules", ".gomod"}
@@ -63,6 +79,9 @@ func (ld *langDetector) ScanFiles(folderPtr filesmanager.FileManager) (found boo
 		if er != nil {
 			return false, nil, er
 		}
+		if hasFiles {
+			ld.evidence = append(ld.evidence, fmt.Sprintf("project has .%s files", strings.ReplaceAll(fileExtentions, ",", ", .")))
+		}
 		if hasFiles && targetFile == "" {
 			return true, nil, nil
 		}
@@ -105,6 +124,7 @@ func (ld *langDetector) ScanFiles(folderPtr filesmanager.FileManager) (found boo
 		if findError := scanner.Find([]byte(versionPrefix)); findError != nil {
 			// TODO: make custom error
 			if findError.Error() == "prefix not found" {
+				ld.evidence = append(ld.evidence, "found "+gomod)
 				logs.Debugf("detector[%s]: prefix %q not found in %s, checking next file", ld.brickName, versionPrefix, gomod)
 				continue // Check other files
 			}
@@ -120,6 +140,7 @@ func (ld *langDetector) ScanFiles(folderPtr filesmanager.FileManager) (found boo
 
 		// Skip invalid version strings (e.g. "." extracted from paths like "./modules/index.js")
 		if !hasDigit(v) {
+			ld.evidence = append(ld.evidence, "found "+gomod)
 			logs.Debugf("detector[%s]: skipping invalid version %q in %s (no digits)", ld.brickName, v, gomod)
 			continue
 		}
@@ -128,6 +149,7 @@ func (ld *langDetector) ScanFiles(folderPtr filesmanager.FileManager) (found boo
 			v = ">=" + v
 		}
 		logs.Debugf("detector[%s]: found version %q in %s", ld.brickName, v, gomod)
+		ld.evidence = append(ld.evidence, fmt.Sprintf("%s requires %s", gomod, v))
 		versionsFound = append(versionsFound, v)
 	}
 
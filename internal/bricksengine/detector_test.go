@@ -226,6 +226,12 @@ func TestLangDetector_Golang_MultipleGoMod_FirstWithoutVersion(t *testing.T) {
 	if meta["version"] != "1.22.0" {
 		t.Errorf("expected version=1.22.0 (from subpkg), got %s", meta["version"])
 	}
+	evidence := strings.Join(detector.Evidence(), "; ")
+	for _, want := range []string{"project has .go files", "found go.mod", "subpkg/go.mod requires >=1.22"} {
+		if !strings.Contains(evidence, want) {
+			t.Errorf("expected evidence to contain %q, got %q", want, evidence)
+		}
+	}
 }
 
 func TestLangDetector_Golang_MultipleGoModWithConflicts(t *testing.T) {
@@ -1,6 +1,9 @@
 package bricksengine
 
-import "slices"
+import (
+	"slices"
+	"strings"
+)
 
 // BrickKind identifies capabilities a brick provides.
 type BrickKind string
@@ -53,3 +56,8 @@ func (s BrickKindsSet) Clone() BrickKindsSet {
 
 	return out
 }
+
+// Name returns the kind as written in brick definitions, e.g. "common".
+func (k BrickKind) Name() string {
+	return strings.TrimPrefix(string(k), "brick_kind_")
+}
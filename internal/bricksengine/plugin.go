@@ -0,0 +1,385 @@
+package bricksengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/filesmanager"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+)
+
+// Plugins are mkenv-brick-<id> executables on PATH. mkenv runs them with a command argument:
+//
+//	info  - prints PluginInfo
+//	scan  - detects the brick in the project, the result is PluginScanResult
+//	brick - builds the brick from metadata, the result is a BrickDefinition
+//
+// mkenv writes a PluginRequest line to stdin, then the plugin writes JSON lines to stdout: queries mkenv
+// answers with a line on stdin (see PluginQuery), and finally {"result": ...} or {"error": "..."}.
+// stderr goes to debug logs.
+const (
+	PluginPrefix          = "mkenv-brick-"
+	PluginProtocolVersion = 1
+
+	pluginTimeout = 30 * time.Second
+)
+
+const (
+	PluginQueryFindFile               = "find_file"
+	PluginQueryHasFilesWithExtensions = "has_files_with_extensions"
+	PluginQueryReadFile               = "read_file"
+)
+
+const (
+	pluginDefaultReadKiB filesmanager.KiB = 64
+	pluginMaxReadKiB     filesmanager.KiB = 255
+)
+
+// PluginInfo describes the brick of a plugin, printed by the info command.
+type PluginInfo struct {
+	Protocol    int      `json:"protocol"`
+	ID          BrickID  `json:"id"`
+	Description string   `json:"description"`
+	Kinds       []string `json:"kinds"`    // like kinds of a BrickDefinition
+	Detector    bool     `json:"detector"` // the plugin implements scan
+	// Config lists the bricks_config keys of the brick. Without it bricks_config of the plugin isn't validated.
+	Config ConfigSchema `json:"config,omitempty"`
+}
+
+// PluginRequest is the first line a plugin reads from stdin.
+type PluginRequest struct {
+	Protocol int               `json:"protocol"`
+	Metadata map[string]string `json:"metadata,omitempty"` // brick command only: detected metadata or bricks_config
+}
+
+// PluginQuery asks mkenv about project files, like FileManager does. Paths are relative to the project.
+//
+//	find_file                 {filename, ignore_paths} -> {"files": [...]}
+//	has_files_with_extensions {extensions, ignore_paths} -> {"found": bool}
+//	read_file                 {path, prefix, max_kib} -> {"content": "..."}, content after prefix if set
+//
+// Failed queries are answered with {"error": "..."}.
+type PluginQuery struct {
+	Method      string   `json:"method"`
+	Filename    string   `json:"filename,omitempty"`
+	Extensions  string   `json:"extensions,omitempty"`
+	IgnorePaths []string `json:"ignore_paths,omitempty"`
+	Path        string   `json:"path,omitempty"`
+	Prefix      string   `json:"prefix,omitempty"`
+	MaxKiB      int      `json:"max_kib,omitempty"`
+}
+
+// PluginScanResult is the result of the scan command. An empty BrickID means nothing was detected.
+// A plugin can propose other bricks than its own, e.g. nodejs with a version from a custom manifest.
+type PluginScanResult struct {
+	BrickID  BrickID           `json:"brick_id"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Evidence []string          `json:"evidence,omitempty"` // what was found, e.g. "acme.toml requires 4.2"
+}
+
+type pluginMessage struct {
+	Query  *PluginQuery    `json:"query"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+type pluginAnswerError struct {
+	Error string `json:"error"`
+}
+
+// Plugin is a brick implemented by an external executable.
+type Plugin struct {
+	ctx      context.Context
+	path     string
+	info     PluginInfo
+	evidence []string // of the last scan
+}
+
+func (p *Plugin) ID() BrickID      { return p.info.ID }
+func (p *Plugin) Path() string     { return p.path }
+func (p *Plugin) Info() PluginInfo { return p.info }
+
+// DiscoverPlugins finds mkenv-brick-<id> executables in the folders of pathList (PATH syntax) and asks them
+// for their info. Like for commands, the first folder wins. Broken plugins are skipped with a warning.
+// Relative folders (., bin, node_modules/.bin) are skipped like exec.ErrDot does: they point into the current
+// folder, usually an untrusted project. ctx bounds every later call of the plugins.
+func DiscoverPlugins(ctx context.Context, pathList string) ([]*Plugin, error) {
+	seen := map[BrickID]bool{}
+	plugins := []*Plugin{}
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" || !filepath.IsAbs(dir) {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // PATH often lists missing folders
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), PluginPrefix) {
+				continue
+			}
+			id := BrickID(strings.TrimPrefix(entry.Name(), PluginPrefix))
+			if seen[id] || !brickIDRegexp.MatchString(string(id)) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+			seen[id] = true
+
+			plugin, err := loadPlugin(ctx, id, path)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				logs.Warnf("Skipping brick plugin %s: %v", path, err)
+				continue
+			}
+			logs.Debugf("found brick plugin %s at %s", id, path)
+			plugins = append(plugins, plugin)
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].ID() < plugins[j].ID() })
+	return plugins, nil
+}
+
+func loadPlugin(ctx context.Context, id BrickID, path string) (*Plugin, error) {
+	p := &Plugin{ctx: ctx, path: path}
+	out, stderr, err := p.command("info").output()
+	if err != nil {
+		return nil, pluginError(err, stderr)
+	}
+	if err := json.Unmarshal(out, &p.info); err != nil {
+		return nil, fmt.Errorf("invalid info: %w", err)
+	}
+	if p.info.Protocol != PluginProtocolVersion {
+		return nil, fmt.Errorf("protocol %d is not supported (expected %d)", p.info.Protocol, PluginProtocolVersion)
+	}
+	if p.info.ID != id {
+		return nil, fmt.Errorf("info has id %q, expected %q", p.info.ID, id)
+	}
+	if p.info.Description == "" {
+		return nil, errors.New("info has no description")
+	}
+	for _, kind := range p.info.Kinds {
+		if _, ok := definitionKinds[kind]; !ok {
+			return nil, fmt.Errorf("unknown kind %q (expected common, entrypoint or platform)", kind)
+		}
+	}
+	if err := p.info.Config.Validate(); err != nil {
+		return nil, fmt.Errorf("info config: %w", err)
+	}
+	return p, nil
+}
+
+// BrickInfo returns the info of the plugin's brick.
+func (p *Plugin) BrickInfo() *BrickInfo {
+	kinds := []BrickKind{}
+	for _, kind := range p.info.Kinds {
+		kinds = append(kinds, definitionKinds[kind])
+	}
+	if len(kinds) == 0 {
+		kinds = append(kinds, BrickKindCommon)
+	}
+	return NewBrickInfo(p.info.ID, p.info.Description, kinds)
+}
+
+// Scan implements BrickDetector.
+func (p *Plugin) Scan(folderPtr filesmanager.FileManager) (BrickID, map[string]string, error) {
+	p.evidence = nil
+	var result PluginScanResult
+	if err := p.call("scan", PluginRequest{Protocol: PluginProtocolVersion}, folderPtr, &result); err != nil {
+		return "", nil, err
+	}
+	if result.BrickID != "" && !brickIDRegexp.MatchString(string(result.BrickID)) {
+		return "", nil, fmt.Errorf("brick plugin %s proposed invalid brick id %q", p.info.ID, result.BrickID)
+	}
+	p.evidence = result.Evidence
+	return result.BrickID, result.Metadata, nil
+}
+
+// Evidence implements DetectionExplainer.
+func (p *Plugin) Evidence() []string {
+	return append([]string{}, p.evidence...)
+}
+
+// Factory returns the factory of the plugin's brick. The brick is built by the plugin, project files can't be queried.
+func (p *Plugin) Factory() BrickFactory {
+	return func(metadata map[string]string) (Brick, error) {
+		var def BrickDefinition
+		if err := p.call("brick", PluginRequest{Protocol: PluginProtocolVersion, Metadata: metadata}, nil, &def); err != nil {
+			return nil, err
+		}
+		def.Source = p.path
+		if def.ID != p.info.ID {
+			return nil, fmt.Errorf("brick plugin %s returned brick %q", p.info.ID, def.ID)
+		}
+		if err := def.Validate(); err != nil {
+			return nil, fmt.Errorf("brick plugin %s: %w", p.info.ID, err)
+		}
+		return def.Factory()(nil)
+	}
+}
+
+type pluginCommand struct {
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stderr *bytes.Buffer
+}
+
+func (p *Plugin) command(name string) *pluginCommand {
+	ctx, cancel := context.WithTimeout(p.ctx, pluginTimeout)
+	cmd := exec.CommandContext(ctx, p.path, name)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	return &pluginCommand{cmd: cmd, cancel: cancel, stderr: stderr}
+}
+
+func (c *pluginCommand) output() ([]byte, string, error) {
+	defer c.cancel()
+	out, err := c.cmd.Output()
+	return out, c.stderr.String(), err
+}
+
+// call runs the plugin command and answers its queries about folderPtr until the result, which is decoded into result.
+func (p *Plugin) call(name string, request PluginRequest, folderPtr filesmanager.FileManager, result any) error {
+	c := p.command(name)
+	defer c.cancel()
+
+	stdin, err := c.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return fmt.Errorf("brick plugin %s: %w", p.info.ID, err)
+	}
+
+	callErr := p.converse(stdin, stdout, request, folderPtr, result)
+	_ = stdin.Close()
+	waitErr := c.cmd.Wait()
+
+	stderr := c.stderr.String()
+	if stderr != "" {
+		logs.Debugf("brick plugin %s %s: %s", p.info.ID, name, strings.TrimSpace(stderr))
+	}
+	if callErr != nil {
+		return fmt.Errorf("brick plugin %s %s: %w", p.info.ID, name, pluginError(callErr, stderr))
+	}
+	if waitErr != nil {
+		return fmt.Errorf("brick plugin %s %s: %w", p.info.ID, name, pluginError(waitErr, stderr))
+	}
+	return nil
+}
+
+func (p *Plugin) converse(stdin io.Writer, stdout io.Reader, request PluginRequest, folderPtr filesmanager.FileManager, result any) error {
+	enc := json.NewEncoder(stdin)
+	if err := enc.Encode(request); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(stdout)
+	for {
+		var msg pluginMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("exited without a result")
+			}
+			return fmt.Errorf("invalid message: %w", err)
+		}
+		switch {
+		case msg.Error != "":
+			return errors.New(msg.Error)
+		case msg.Result != nil:
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				return fmt.Errorf("invalid result: %w", err)
+			}
+			return nil
+		case msg.Query != nil:
+			answer, err := answerPluginQuery(folderPtr, msg.Query)
+			if err != nil {
+				answer = pluginAnswerError{Error: err.Error()}
+			}
+			if err := enc.Encode(answer); err != nil {
+				return err
+			}
+		default:
+			return errors.New("message has no query, result or error")
+		}
+	}
+}
+
+func answerPluginQuery(folderPtr filesmanager.FileManager, q *PluginQuery) (any, error) {
+	if folderPtr == nil {
+		return nil, errors.New("project files can't be queried by this command")
+	}
+	switch q.Method {
+	case PluginQueryFindFile:
+		files, err := folderPtr.FindFile(q.Filename, q.IgnorePaths)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Files []string `json:"files"`
+		}{append([]string{}, files...)}, nil
+	case PluginQueryHasFilesWithExtensions:
+		found, err := folderPtr.HasFilesWithExtensions(q.Extensions, q.IgnorePaths)
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Found bool `json:"found"`
+		}{found}, nil
+	case PluginQueryReadFile:
+		if !filepath.IsLocal(q.Path) {
+			return nil, fmt.Errorf("path %q is not inside the project", q.Path)
+		}
+		maxKiB := pluginDefaultReadKiB
+		if q.MaxKiB > 0 {
+			maxKiB = filesmanager.KiB(min(q.MaxKiB, int(pluginMaxReadKiB)))
+		}
+		scanner, err := folderPtr.GetFileScanner(q.Path, 32)
+		if err != nil {
+			return nil, err
+		}
+		defer scanner.Close()
+		if err := scanner.Find([]byte(q.Prefix)); err != nil {
+			return nil, err
+		}
+		content, err := scanner.ReadWhile(maxKiB, func(byte) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Content string `json:"content"`
+		}{string(content)}, nil
+	default:
+		return nil, fmt.Errorf("unknown query method %q", q.Method)
+	}
+}
+
+func pluginError(err error, stderr string) error {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return err
+	}
+	if i := strings.LastIndex(stderr, "\n"); i >= 0 {
+		stderr = stderr[i+1:]
+	}
+	return fmt.Errorf("%w: %s", err, stderr)
+}
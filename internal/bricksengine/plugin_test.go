@@ -0,0 +1,144 @@
+// Tests in this file exercise discovery of mkenv-brick-* plugins and the JSON protocol they speak.
+package bricksengine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0xa1bed0/mkenv/internal/filesmanager"
+)
+
+const acmePluginScript = `#!/bin/sh
+case "$1" in
+info)
+  echo '{"protocol": 1, "id": "acme", "description": "Acme SDK", "kinds": ["common"], "detector": true}'
+  ;;
+scan)
+  read -r request
+  echo '{"query": {"method": "find_file", "filename": "acme.toml"}}'
+  read -r answer
+  case "$answer" in
+  *acme.toml*) ;;
+  *) echo '{"result": {"brick_id": ""}}'; exit 0 ;;
+  esac
+  echo '{"query": {"method": "read_file", "path": "acme.toml", "prefix": "sdk = "}}'
+  read -r answer
+  version=$(printf "%s\n" "$answer" | sed 's/.*"content":"\([0-9.]*\).*/\1/')
+  echo "{\"result\": {\"brick_id\": \"acme\", \"metadata\": {\"version\": \"$version\"}, \"evidence\": [\"acme.toml requires $version\"]}}"
+  ;;
+brick)
+  read -r request
+  version=$(printf "%s\n" "$request" | sed 's/.*"version":"\([0-9.]*\)".*/\1/')
+  echo "{\"result\": {\"id\": \"acme\", \"description\": \"Acme SDK\", \"root_run\": [\"install-acme $version\"]}}"
+  ;;
+esac
+`
+
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+		t.Fatalf("write plugin: %v", err)
+	}
+}
+
+func TestPluginDetectsAndBuildsBrick(t *testing.T) {
+	t.Parallel()
+
+	binDir := t.TempDir()
+	writePlugin(t, binDir, "mkenv-brick-acme", acmePluginScript)
+	writePlugin(t, binDir, "mkenv-brick-broken", "#!/bin/sh\necho 'not json'\n")
+	if err := os.WriteFile(filepath.Join(binDir, "mkenv-brick-noexec"), []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	plugins, err := DiscoverPlugins(context.Background(), strings.Join([]string{filepath.Join(binDir, "missing"), binDir}, string(os.PathListSeparator)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plugins) != 1 || plugins[0].ID() != "acme" {
+		t.Fatalf("expected only the acme plugin, got %v", plugins)
+	}
+	plugin := plugins[0]
+
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "acme.toml"), []byte("name = \"app\"\nsdk = 4.2\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	fm, err := filesmanager.NewFileManager(project)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	id, meta, err := plugin.Scan(fm)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "acme" || meta["version"] != "4.2" {
+		t.Fatalf("unexpected scan result %s %v", id, meta)
+	}
+	if evidence := plugin.Evidence(); len(evidence) != 1 || evidence[0] != "acme.toml requires 4.2" {
+		t.Fatalf("unexpected evidence %v", evidence)
+	}
+
+	r := NewRegistry()
+	if err := r.RegisterPlugin(plugin); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if detectors := r.AllDetectors(); len(detectors) != 1 || detectors[0].BrickInfo().ID() != "acme" {
+		t.Fatalf("expected the plugin detector, got %v", detectors)
+	}
+	factory, ok := r.GetBrickFactory("acme")
+	if !ok {
+		t.Fatalf("expected the plugin brick factory")
+	}
+	b, err := factory(meta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if argv := b.RootRun()[0].Argv; argv[len(argv)-1] != "install-acme 4.2" {
+		t.Fatalf("unexpected root run %v", argv)
+	}
+}
+
+func TestDiscoverPluginsSkipsRelativeFolders(t *testing.T) {
+	t.Parallel()
+
+	binDir := t.TempDir()
+	writePlugin(t, binDir, "mkenv-brick-acme", acmePluginScript)
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	relDir, err := filepath.Rel(cwd, binDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plugins, err := DiscoverPlugins(context.Background(), strings.Join([]string{".", relDir}, string(os.PathListSeparator)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plugins) != 0 {
+		t.Fatalf("expected plugins in relative PATH folders to be skipped, got %v", plugins)
+	}
+}
+
+func TestPluginQueriesStayInProject(t *testing.T) {
+	t.Parallel()
+
+	fm, err := filesmanager.NewFileManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, path := range []string{"../secret", "/etc/passwd"} {
+		if _, err := answerPluginQuery(fm, &PluginQuery{Method: PluginQueryReadFile, Path: path}); err == nil {
+			t.Fatalf("expected reading %s to fail", path)
+		}
+	}
+	if _, err := answerPluginQuery(nil, &PluginQuery{Method: PluginQueryFindFile, Filename: "x"}); err == nil {
+		t.Fatalf("expected queries without project files to fail")
+	}
+}
@@ -1,6 +1,7 @@
 package bricksengine
 
 import (
+	"fmt"
 	"sync"
 )
 
@@ -10,15 +11,21 @@ type (
 )
 
 type BricksRegistry struct {
-	mu        sync.RWMutex
-	bricks    map[BrickID]BrickFactory
-	detectors []DetectorFactory
+	mu          sync.RWMutex
+	bricks      map[BrickID]BrickFactory
+	definitions map[BrickID]BrickDefinition
+	plugins     map[BrickID]*Plugin
+	schemas     map[BrickID]ConfigSchema
+	detectors   []DetectorFactory
 }
 
 func NewRegistry() *BricksRegistry {
 	return &BricksRegistry{
-		bricks:    map[BrickID]BrickFactory{},
-		detectors: []DetectorFactory{},
+		bricks:      map[BrickID]BrickFactory{},
+		definitions: map[BrickID]BrickDefinition{},
+		plugins:     map[BrickID]*Plugin{},
+		schemas:     map[BrickID]ConfigSchema{},
+		detectors:   []DetectorFactory{},
 	}
 }
 
@@ -36,6 +43,17 @@ func RegisterDetector(f DetectorFactory) {
 	DefaultBricksRegistry.mu.Unlock()
 }
 
+// RegisterConfigSchema registers the bricks_config keys a built-in brick accepts.
+// A brick without config registers an empty schema, so any key of it is an error.
+func RegisterConfigSchema(id BrickID, schema ConfigSchema) {
+	if err := schema.Validate(); err != nil {
+		panic(fmt.Sprintf("brick %s: %v", id, err))
+	}
+	DefaultBricksRegistry.mu.Lock()
+	DefaultBricksRegistry.schemas[id] = schema
+	DefaultBricksRegistry.mu.Unlock()
+}
+
 func (r *BricksRegistry) GetBrickFactory(id BrickID) (BrickFactory, bool) {
 	r.mu.RLock()
 	f, ok := r.bricks[id]
@@ -46,23 +64,141 @@ func (r *BricksRegistry) GetBrickFactory(id BrickID) (BrickFactory, bool) {
 func (r *BricksRegistry) ListBrickIDs() []BrickID {
 	r.mu.RLock()
 	out := make([]BrickID, 0, len(r.bricks))
-	i := 0
-	for id, _ := range r.bricks {
-		out[i] = id
-		i++
+	for id := range r.bricks {
+		out = append(out, id)
 	}
 	out = UniqueSortedBricks(out)
 	r.mu.RUnlock()
 	return out
 }
 
+// RegisterDefinition registers a declarative brick. Definitions can replace each other but not bricks built into mkenv.
+func (r *BricksRegistry) RegisterDefinition(def BrickDefinition) error {
+	if err := def.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, plugin := r.plugins[def.ID]; plugin {
+		return fmt.Errorf("brick %s (%s) can't be defined: plugin %s has the same id", def.ID, def.Source, r.plugins[def.ID].Path())
+	}
+	if r.isBuiltin(def.ID) {
+		return fmt.Errorf("brick %s (%s) can't be defined: a built-in brick has the same id", def.ID, def.Source)
+	}
+	r.bricks[def.ID] = def.Factory()
+	r.definitions[def.ID] = def
+	return nil
+}
+
+// RegisterPlugin registers the brick and the detector of a plugin. Like definitions, plugins can't replace built-in bricks.
+func (r *BricksRegistry) RegisterPlugin(p *Plugin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if def, declared := r.definitions[p.ID()]; declared {
+		return fmt.Errorf("brick plugin %s (%s) can't be used: brick %s is defined in %s", p.ID(), p.Path(), p.ID(), def.Source)
+	}
+	if r.isBuiltin(p.ID()) {
+		return fmt.Errorf("brick plugin %s (%s) can't be used: a built-in brick has the same id", p.ID(), p.Path())
+	}
+	r.bricks[p.ID()] = p.Factory()
+	r.plugins[p.ID()] = p
+	return nil
+}
+
+// isBuiltin must be called with r.mu held.
+func (r *BricksRegistry) isBuiltin(id BrickID) bool {
+	_, registered := r.bricks[id]
+	_, declared := r.definitions[id]
+	_, plugin := r.plugins[id]
+	return registered && !declared && !plugin
+}
+
+// Definition returns the definition of a declarative brick. Built-in bricks have none.
+func (r *BricksRegistry) Definition(id BrickID) (BrickDefinition, bool) {
+	r.mu.RLock()
+	def, ok := r.definitions[id]
+	r.mu.RUnlock()
+	return def, ok
+}
+
+// ConfigSchema returns the bricks_config keys of the brick: registered for built-in bricks, the config of
+// a definition or the config a plugin declares. It returns false if the brick has no schema.
+func (r *BricksRegistry) ConfigSchema(id BrickID) (ConfigSchema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if def, ok := r.definitions[id]; ok {
+		return def.Schema(), true
+	}
+	if p, ok := r.plugins[id]; ok {
+		return p.info.Config, p.info.Config != nil
+	}
+	schema, ok := r.schemas[id]
+	return schema, ok
+}
+
+// ValidateBrickConfig checks a bricks_config value of the brick against its schema.
+// Unknown bricks return ErrUnknownBrick, bricks without a schema accept any key.
+func (r *BricksRegistry) ValidateBrickConfig(id BrickID, key, value string) error {
+	if _, ok := r.GetBrickFactory(id); !ok {
+		return ErrUnknownBrick
+	}
+	schema, ok := r.ConfigSchema(id)
+	if !ok {
+		return nil
+	}
+	return schema.ValidateValue(key, value)
+}
+
+// BrickInfo returns the id, description and kinds of a registered brick.
+// Plugins answer from their info, other bricks are built without metadata.
+func (r *BricksRegistry) BrickInfo(id BrickID) (*BrickInfo, error) {
+	r.mu.RLock()
+	p, plugin := r.plugins[id]
+	factory, ok := r.bricks[id]
+	r.mu.RUnlock()
+	if plugin {
+		return p.BrickInfo(), nil
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownBrick, id)
+	}
+	b, err := factory(nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewBrickInfo(b.ID(), b.Description(), b.Kinds().All()), nil
+}
+
+// Source returns where the brick comes from: "built-in", the file of a definition or the path of a plugin.
+func (r *BricksRegistry) Source(id BrickID) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if def, ok := r.definitions[id]; ok {
+		return def.Source
+	}
+	if p, ok := r.plugins[id]; ok {
+		return p.Path()
+	}
+	return "built-in"
+}
+
 func (r *BricksRegistry) AllDetectors() []BrickDetector {
 	r.mu.RLock()
 	fs := append([]DetectorFactory(nil), r.detectors...)
-	out := make([]BrickDetector, 0, len(fs))
+	out := make([]BrickDetector, 0, len(fs)+len(r.plugins))
 	for _, mk := range fs {
 		out = append(out, mk())
 	}
+	// plugins come after built-in detectors, in id order
+	pluginIDs := make([]BrickID, 0, len(r.plugins))
+	for id, p := range r.plugins {
+		if p.Info().Detector {
+			pluginIDs = append(pluginIDs, id)
+		}
+	}
+	for _, id := range UniqueSortedBricks(pluginIDs) {
+		out = append(out, r.plugins[id])
+	}
 	r.mu.RUnlock()
 	return out
 }
@@ -0,0 +1,264 @@
+package bricksengine
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/versions"
+)
+
+// maxResolveRounds bounds rebuilds of bricks whose metadata changes while dependencies are resolved.
+const maxResolveRounds = 100
+
+// BrickRequest asks for a brick: enabled by the user, proposed by a detector or required by another brick.
+type BrickRequest struct {
+	ID       BrickID
+	Metadata map[string]string
+	Reason   string
+}
+
+// ResolveBricks builds the requested bricks and, transitively, the bricks they require.
+// A brick requested several times is built once with the metadata of all requests merged (see mergeRequests).
+// Requests for disabled or unknown bricks are skipped with a warning, but a brick can't require them.
+// The result is checked for conflicts and is in dependency order (see OrderBricks).
+func (r *BricksRegistry) ResolveBricks(requests []BrickRequest, disabled map[BrickID]bool) ([]Brick, error) {
+	pending := map[BrickID][]BrickRequest{}
+	for _, req := range requests {
+		if disabled[req.ID] {
+			logs.Warnf("skipping disabled brick %s (reason: %s)", req.ID, req.Reason)
+			continue
+		}
+		if _, ok := r.GetBrickFactory(req.ID); !ok {
+			logs.Warnf("Unknown brick '%s' (reason: %s). Skipping...", req.ID, req.Reason)
+			continue
+		}
+		pending[req.ID] = append(pending[req.ID], req)
+	}
+
+	built := map[BrickID]Brick{}
+	dirty := map[BrickID]bool{}
+	for id := range pending {
+		dirty[id] = true
+	}
+
+	for round := 0; len(dirty) > 0; round++ {
+		if round == maxResolveRounds {
+			return nil, fmt.Errorf("brick dependencies don't settle, check requirements of %s", strings.Join(ToStrings(sortedIDs(dirty)), ", "))
+		}
+		for _, id := range sortedIDs(dirty) {
+			delete(dirty, id)
+
+			factory, _ := r.GetBrickFactory(id)
+			metadata, err := mergeRequests(id, pending[id])
+			if err != nil {
+				return nil, err
+			}
+			b, err := factory(metadata)
+			if err != nil {
+				return nil, err
+			}
+			built[id] = b
+
+			for _, dep := range b.Requires() {
+				if provider := providerOf(built, dep.ID); provider != nil && provider.ID() != dep.ID {
+					logs.Debugf("brick %s requires %s, provided by %s", id, dep.ID, provider.ID())
+					continue
+				}
+				if disabled[dep.ID] {
+					return nil, fmt.Errorf("brick %s requires %s, which is disabled", id, dep.ID)
+				}
+				if _, ok := r.GetBrickFactory(dep.ID); !ok {
+					return nil, fmt.Errorf("brick %s requires unknown brick %s", id, dep.ID)
+				}
+				req := BrickRequest{ID: dep.ID, Metadata: dep.Metadata, Reason: "required by " + string(id)}
+				if upsertRequest(pending, req) {
+					dirty[dep.ID] = true
+				}
+			}
+		}
+	}
+
+	bricks := make([]Brick, 0, len(built))
+	for _, b := range built {
+		bricks = append(bricks, b)
+	}
+	if err := checkConflicts(bricks); err != nil {
+		return nil, err
+	}
+	return OrderBricks(bricks)
+}
+
+// upsertRequest adds req to pending, replacing an earlier request with the same reason.
+// It returns false if pending already has the same request.
+func upsertRequest(pending map[BrickID][]BrickRequest, req BrickRequest) bool {
+	for i, existing := range pending[req.ID] {
+		if existing.Reason != req.Reason {
+			continue
+		}
+		if maps.Equal(existing.Metadata, req.Metadata) {
+			return false
+		}
+		pending[req.ID][i] = req
+		return true
+	}
+	pending[req.ID] = append(pending[req.ID], req)
+	return true
+}
+
+// mergeRequests merges metadata of all requests of a brick. "version" values are constraints, the highest version
+// satisfying all of them is used. Other keys must have the same value in every request that sets them.
+func mergeRequests(id BrickID, requests []BrickRequest) (map[string]string, error) {
+	if len(requests) == 1 {
+		return copyMap(requests[0].Metadata), nil
+	}
+
+	merged := map[string]string{}
+	setBy := map[string]string{}
+	constraints := []string{}
+	sources := []string{}
+	for _, req := range requests {
+		for k, v := range req.Metadata {
+			if v == "" {
+				continue
+			}
+			if k == "version" {
+				if !slices.Contains(constraints, v) {
+					constraints = append(constraints, v)
+				}
+				sources = append(sources, fmt.Sprintf("%s (%s)", v, req.Reason))
+				continue
+			}
+			if existing, ok := merged[k]; ok && existing != v {
+				return nil, fmt.Errorf("brick %s: %s is %q (%s) and %q (%s)", id, k, existing, setBy[k], v, req.Reason)
+			}
+			merged[k] = v
+			setBy[k] = req.Reason
+		}
+	}
+
+	switch len(constraints) {
+	case 0:
+	case 1:
+		merged["version"] = constraints[0]
+	default:
+		version, err := versions.MaxVersionFromConstraints(constraints)
+		if err != nil {
+			return nil, fmt.Errorf("brick %s: version constraints can't be reconciled: %s: %w", id, strings.Join(sources, ", "), err)
+		}
+		logs.Debugf("brick %s: version %s satisfies %s", id, version, strings.Join(sources, ", "))
+		merged["version"] = version
+	}
+	return merged, nil
+}
+
+// providerOf returns a brick that is or provides id.
+func providerOf(bricks map[BrickID]Brick, id BrickID) Brick {
+	if b, ok := bricks[id]; ok {
+		return b
+	}
+	for _, bid := range sortedIDs(bricks) {
+		if slices.Contains(bricks[bid].Provides(), id) {
+			return bricks[bid]
+		}
+	}
+	return nil
+}
+
+// checkConflicts returns an error if bricks conflict or several of them are or provide the same brick.
+func checkConflicts(bricks []Brick) error {
+	providers := map[BrickID][]BrickID{}
+	for _, b := range bricks {
+		providers[b.ID()] = append(providers[b.ID()], b.ID())
+		for _, p := range b.Provides() {
+			providers[p] = append(providers[p], b.ID())
+		}
+	}
+
+	errs := []error{}
+	for _, id := range sortedIDs(providers) {
+		if ps := providers[id]; len(ps) > 1 {
+			errs = append(errs, fmt.Errorf("bricks %s all provide %s, only one can be used", strings.Join(ToStrings(UniqueSortedBricks(ps)), ", "), id))
+		}
+	}
+	for _, b := range bricks {
+		for _, c := range b.Conflicts() {
+			for _, p := range providers[c] {
+				if p != b.ID() {
+					errs = append(errs, fmt.Errorf("brick %s conflicts with %s", b.ID(), p))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OrderBricks sorts bricks so that every brick comes after the bricks it requires (or their providers).
+// Independent bricks are sorted by id. A dependency cycle is an error.
+func OrderBricks(bricks []Brick) ([]Brick, error) {
+	byID := make(map[BrickID]Brick, len(bricks))
+	for _, b := range bricks {
+		byID[b.ID()] = b
+	}
+
+	dependents := map[BrickID][]BrickID{}
+	blockers := map[BrickID]int{}
+	for _, b := range bricks {
+		blockers[b.ID()] = 0
+	}
+	for _, b := range bricks {
+		for _, dep := range b.Requires() {
+			provider := providerOf(byID, dep.ID)
+			if provider == nil || provider.ID() == b.ID() {
+				continue // checked by ResolveBricks, here only the order matters
+			}
+			dependents[provider.ID()] = append(dependents[provider.ID()], b.ID())
+			blockers[b.ID()]++
+		}
+	}
+
+	ready := []BrickID{}
+	for id, n := range blockers {
+		if n == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	out := make([]Brick, 0, len(bricks))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return ready[i] < ready[j] })
+		id := ready[0]
+		ready = ready[1:]
+		out = append(out, byID[id])
+		for _, dependent := range dependents[id] {
+			blockers[dependent]--
+			if blockers[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(out) < len(byID) {
+		cycle := []string{}
+		for _, id := range sortedIDs(blockers) {
+			if blockers[id] > 0 {
+				cycle = append(cycle, string(id))
+			}
+		}
+		return nil, fmt.Errorf("bricks %s require each other", strings.Join(cycle, ", "))
+	}
+	return out, nil
+}
+
+func sortedIDs[V any](m map[BrickID]V) []BrickID {
+	ids := make([]BrickID, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,132 @@
+// Tests in this file exercise resolution of brick dependencies, version merging, conflicts and ordering.
+package bricksengine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/0xa1bed0/mkenv/internal/versions"
+)
+
+func newTestRegistry(t *testing.T, bricks map[BrickID][]BrickOption) *BricksRegistry {
+	t.Helper()
+	r := NewRegistry()
+	for id, opts := range bricks {
+		r.bricks[id] = func(metadata map[string]string) (Brick, error) {
+			return NewBrick(id, "test brick", append([]BrickOption{WithEnv("VERSION", metadata["version"])}, opts...)...)
+		}
+	}
+	return r
+}
+
+func brickIDs(bricks []Brick) string {
+	ids := []string{}
+	for _, b := range bricks {
+		ids = append(ids, string(b.ID()))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestResolveBricksMergesSharedDependency(t *testing.T) {
+	t.Parallel()
+
+	r := newTestRegistry(t, map[BrickID][]BrickOption{
+		"node":        nil,
+		"claude-code": {WithRequires("node", map[string]string{"version": ">=18"})},
+		"codex":       {WithRequires("node", nil)},
+	})
+
+	bricks, err := r.ResolveBricks([]BrickRequest{
+		{ID: "claude-code", Reason: "enabled by user settings"},
+		{ID: "codex", Reason: "enabled by user settings"},
+		{ID: "node", Metadata: map[string]string{"version": "^20.1.0"}, Reason: "proposed by detector"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := brickIDs(bricks); got != "node,claude-code,codex" {
+		t.Fatalf("expected required bricks first, got %s", got)
+	}
+	if v := bricks[0].Envs()["VERSION"]; v != "20.1.0" {
+		t.Fatalf("expected merged version 20.1.0, got %q", v)
+	}
+}
+
+func TestResolveBricksAddsRequiredBricks(t *testing.T) {
+	t.Parallel()
+
+	r := newTestRegistry(t, map[BrickID][]BrickOption{
+		"node": nil,
+		"tool": {WithRequires("node", map[string]string{"version": "22"})},
+	})
+
+	bricks, err := r.ResolveBricks([]BrickRequest{{ID: "tool", Reason: "enabled by user settings"}}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := brickIDs(bricks); got != "node,tool" {
+		t.Fatalf("expected node to be added, got %s", got)
+	}
+
+	if _, err := r.ResolveBricks([]BrickRequest{{ID: "tool", Reason: "enabled by user settings"}}, map[BrickID]bool{"node": true}); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Fatalf("expected an error for a disabled requirement, got %v", err)
+	}
+}
+
+func TestResolveBricksReportsIrreconcilableVersions(t *testing.T) {
+	t.Parallel()
+
+	r := newTestRegistry(t, map[BrickID][]BrickOption{
+		"node": nil,
+		"tool": {WithRequires("node", map[string]string{"version": ">=20"})},
+	})
+
+	_, err := r.ResolveBricks([]BrickRequest{
+		{ID: "tool", Reason: "enabled by user settings"},
+		{ID: "node", Metadata: map[string]string{"version": "18"}, Reason: "proposed by detector"},
+	}, nil)
+	if !errors.Is(err, versions.ErrConflictingConstraints) {
+		t.Fatalf("expected a version conflict, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "required by tool") || !strings.Contains(err.Error(), "proposed by detector") {
+		t.Fatalf("expected the error to name both sources, got %v", err)
+	}
+}
+
+func TestResolveBricksConflictsAndProviders(t *testing.T) {
+	t.Parallel()
+
+	r := newTestRegistry(t, map[BrickID][]BrickOption{
+		"node": nil,
+		"bun":  {WithProvides("node")},
+		"deno": {WithConflicts("node")},
+		"tool": {WithRequires("node", nil)},
+	})
+
+	bricks, err := r.ResolveBricks([]BrickRequest{{ID: "bun"}, {ID: "tool"}}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := brickIDs(bricks); got != "bun,tool" {
+		t.Fatalf("expected bun to satisfy node, got %s", got)
+	}
+
+	if _, err := r.ResolveBricks([]BrickRequest{{ID: "bun"}, {ID: "node"}}, nil); err == nil {
+		t.Fatalf("expected two providers of node to fail")
+	}
+	if _, err := r.ResolveBricks([]BrickRequest{{ID: "deno"}, {ID: "bun"}}, nil); err == nil || !strings.Contains(err.Error(), "deno conflicts with bun") {
+		t.Fatalf("expected deno to conflict with the provider of node, got %v", err)
+	}
+}
+
+func TestOrderBricksDetectsCycles(t *testing.T) {
+	t.Parallel()
+
+	a, _ := NewBrick("a", "a", WithRequires("b", nil))
+	b, _ := NewBrick("b", "b", WithRequires("a", nil))
+	c, _ := NewBrick("c", "c")
+	if _, err := OrderBricks([]Brick{a, b, c}); err == nil || !strings.Contains(err.Error(), "a, b") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+}
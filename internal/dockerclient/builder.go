@@ -14,7 +14,8 @@ import (
 	"github.com/0xa1bed0/mkenv/internal/logs"
 )
 
-func (dc *DockerClient) BuildImage(ctx context.Context, dockerfile string, tag string) (string, error) {
+// BuildImage builds the image and labels it with labels on top of the LABELs of the Dockerfile.
+func (dc *DockerClient) BuildImage(ctx context.Context, dockerfile string, tag string, labels map[string]string) (string, error) {
 	var buf bytes.Buffer
 	tarWriter := tar.NewWriter(&buf)
 
@@ -52,6 +53,7 @@ func (dc *DockerClient) BuildImage(ctx context.Context, dockerfile string, tag s
 		sdkimage.WithBuildOptions(build.ImageBuildOptions{
 			Dockerfile: "Dockerfile",
 			Remove:     true, // remove intermediate containers
+			Labels:     labels,
 		}),
 	)
 	tailbox.Close()
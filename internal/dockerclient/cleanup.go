@@ -0,0 +1,169 @@
+package dockerclient
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// MkenvCleanupContainer describes a container created by mkenv (labeled with mkenv.project).
+type MkenvCleanupContainer struct {
+	ContainerID string
+	Name        string
+	State       string
+	Project     string
+	ImageID     string
+	Volumes     []string
+	CreatedAt   time.Time
+}
+
+// MkenvImage describes an image built by mkenv (labeled with mkenv=true).
+type MkenvImage struct {
+	ImageID   string
+	Tags      []string
+	Project   string // the project the image was built for; other projects may reuse it
+	Size      int64
+	CreatedAt time.Time
+}
+
+// MkenvCacheVolume describes a cache volume created by resolveCacheVolumes or resolveCacheFileStore.
+type MkenvCacheVolume struct {
+	Name      string
+	Project   string
+	MountPath string
+	CreatedAt time.Time
+}
+
+// ListCleanupContainers returns all containers (running or not) labeled with mkenv.project.
+// If projectName is not empty only containers of that project are returned.
+func (dc *DockerClient) ListCleanupContainers(ctx context.Context, projectName string) ([]*MkenvCleanupContainer, error) {
+	args := filters.NewArgs()
+	if projectName == "" {
+		args.Add("label", "mkenv.project")
+	} else {
+		args.Add("label", "mkenv.project="+projectName)
+	}
+
+	result, err := dc.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*MkenvCleanupContainer{}
+	for _, c := range result {
+		volumes := []string{}
+		for _, m := range c.Mounts {
+			if m.Name != "" {
+				volumes = append(volumes, m.Name)
+			}
+		}
+		out = append(out, &MkenvCleanupContainer{
+			ContainerID: c.ID,
+			Name:        strings.TrimPrefix(strings.Join(c.Names, ","), "/"),
+			State:       c.State,
+			Project:     c.Labels["mkenv.project"],
+			ImageID:     c.ImageID,
+			Volumes:     volumes,
+			CreatedAt:   time.Unix(c.Created, 0),
+		})
+	}
+
+	return out, nil
+}
+
+// RemoveContainer gracefully stops the container (if running) and removes it.
+// Stopping sends SIGTERM to the container's main process so attached sessions get closed
+// by the shell/multiplexer itself and mkenv processes attached to it exit normally.
+// Volumes are never removed together with the container.
+func (dc *DockerClient) RemoveContainer(ctx context.Context, containerID string) error {
+	timeout := 10
+	err := dc.client.ContainerStop(ctx, containerID, container.StopOptions{Signal: "SIGTERM", Timeout: &timeout})
+	if err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	// mkenv process that started the container removes it on exit as well, so it may be already gone
+	err = dc.client.ContainerRemove(ctx, containerID, container.RemoveOptions{RemoveVolumes: false, Force: true})
+	if err != nil && !cerrdefs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// ListImages returns images labeled with mkenv=true.
+func (dc *DockerClient) ListImages(ctx context.Context) ([]*MkenvImage, error) {
+	args := filters.NewArgs()
+	args.Add("label", "mkenv=true")
+
+	result, err := dc.client.ImageList(ctx, image.ListOptions{
+		Filters: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*MkenvImage{}
+	for _, img := range result {
+		out = append(out, &MkenvImage{
+			ImageID:   img.ID,
+			Tags:      img.RepoTags,
+			Project:   img.Labels["mkenv.project"],
+			Size:      img.Size,
+			CreatedAt: time.Unix(img.Created, 0),
+		})
+	}
+
+	return out, nil
+}
+
+// RemoveImage removes the image together with its untagged parents.
+// It fails if the image is still used by a container.
+func (dc *DockerClient) RemoveImage(ctx context.Context, imageID string) error {
+	_, err := dc.client.ImageRemove(ctx, imageID, image.RemoveOptions{Force: false, PruneChildren: true})
+	return err
+}
+
+// ListCacheVolumes returns the cache volumes created by mkenv (labeled with mkenv_mount_path).
+// If projectName is not empty only volumes of that project are returned.
+func (dc *DockerClient) ListCacheVolumes(ctx context.Context, projectName string) ([]*MkenvCacheVolume, error) {
+	args := filters.NewArgs()
+	args.Add("label", "mkenv=1")
+	args.Add("label", "mkenv_mount_path")
+	if projectName != "" {
+		args.Add("label", "mkenv.project="+projectName)
+	}
+
+	result, err := dc.client.VolumeList(ctx, volume.ListOptions{
+		Filters: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*MkenvCacheVolume{}
+	for _, vol := range result.Volumes {
+		// Docker reports CreatedAt in RFC3339; zero time means "unknown"
+		createdAt, _ := time.Parse(time.RFC3339, vol.CreatedAt)
+		out = append(out, &MkenvCacheVolume{
+			Name:      vol.Name,
+			Project:   vol.Labels["mkenv.project"],
+			MountPath: vol.Labels["mkenv_mount_path"],
+			CreatedAt: createdAt,
+		})
+	}
+
+	return out, nil
+}
+
+// RemoveVolume removes the volume. It fails if the volume is still in use by a container.
+func (dc *DockerClient) RemoveVolume(ctx context.Context, name string) error {
+	return dc.client.VolumeRemove(ctx, name, false)
+}
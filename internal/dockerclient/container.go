@@ -7,11 +7,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	sandboxappconfig "github.com/0xa1bed0/mkenv/internal/apps/sandbox/config"
 	"github.com/0xa1bed0/mkenv/internal/logs"
 	"github.com/0xa1bed0/mkenv/internal/networking/host"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
@@ -22,13 +24,52 @@ import (
 )
 
 type MkenvContainerInfo struct {
-	ContainerID string
-	Name        string
-	State       string
-	Status      string
-	Created     string
-	Command     string
-	Project     string
+	ContainerID string   `json:"container_id" yaml:"container_id"`
+	Name        string   `json:"name" yaml:"name"`
+	State       string   `json:"state" yaml:"state"`
+	Status      string   `json:"status" yaml:"status"`
+	Created     string   `json:"created" yaml:"created"`
+	Command     string   `json:"command" yaml:"command"`
+	Project     string   `json:"project" yaml:"project"`
+	ProjectPath string   `json:"project_path" yaml:"project_path"`
+	ImageTag    string   `json:"image_tag" yaml:"image_tag"`
+	Bricks      []string `json:"bricks" yaml:"bricks"`
+	// ProxyPort is the host port the container proxy is published on. 0 if unknown.
+	ProxyPort int    `json:"proxy_port" yaml:"proxy_port"`
+	RunID     string `json:"run_id" yaml:"run_id"`
+}
+
+func newMkenvContainerInfo(c container.Summary) *MkenvContainerInfo {
+	created := time.Unix(c.Created, 0).Local().Format(time.RFC1123Z)
+
+	// mkenv.bricks label is inherited from the image
+	bricks := []string{}
+	if bricksLabel := c.Labels["mkenv.bricks"]; bricksLabel != "" {
+		bricks = strings.Split(bricksLabel, ",")
+	}
+
+	proxyPort := 0
+	for _, port := range c.Ports {
+		if int(port.PrivatePort) == hostappconfig.ContainerProxyPort() && port.PublicPort != 0 {
+			proxyPort = int(port.PublicPort)
+			break
+		}
+	}
+
+	return &MkenvContainerInfo{
+		ContainerID: c.ID,
+		Name:        strings.Join(c.Names, ","),
+		State:       c.State,
+		Status:      c.Status,
+		Created:     created,
+		Command:     c.Command,
+		Project:     c.Labels["mkenv.project"],
+		ProjectPath: c.Labels["mkenv.project_path"],
+		ImageTag:    c.Image,
+		Bricks:      bricks,
+		ProxyPort:   proxyPort,
+		RunID:       c.Labels["mkenv.run_id"],
+	}
 }
 
 func (ci *MkenvContainerInfo) OptionLabel() string {
@@ -43,6 +84,7 @@ func (dc *DockerClient) ListAllContainer(ctx context.Context, runningOnly bool)
 	args := filters.NewArgs()
 	args.Add("label", "mkenv=true")
 	result, err := dc.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
 		Filters: args,
 	})
 	if err != nil {
@@ -55,16 +97,7 @@ func (dc *DockerClient) ListAllContainer(ctx context.Context, runningOnly bool)
 		if runningOnly && container.State != "running" {
 			continue
 		}
-		created := time.Unix(container.Created, 0).Local().Format(time.RFC1123Z)
-		out = append(out, &MkenvContainerInfo{
-			ContainerID: container.ID,
-			Name:        strings.Join(container.Names, ","),
-			State:       container.State,
-			Status:      container.Status,
-			Created:     created,
-			Command:     container.Command,
-			Project:     container.Labels["mkenv.project"],
-		})
+		out = append(out, newMkenvContainerInfo(container))
 	}
 
 	return out, nil
@@ -74,6 +107,7 @@ func (dc *DockerClient) ListContainers(ctx context.Context, project *runtime.Pro
 	args := filters.NewArgs()
 	args.Add("label", "mkenv.project="+project.Name())
 	result, err := dc.client.ContainerList(ctx, container.ListOptions{
+		All:     true,
 		Filters: args,
 	})
 	if err != nil {
@@ -86,22 +120,15 @@ func (dc *DockerClient) ListContainers(ctx context.Context, project *runtime.Pro
 		if runningOnly && container.State != "running" {
 			continue
 		}
-		created := time.Unix(container.Created, 0).Local().Format(time.RFC1123Z)
-		out = append(out, &MkenvContainerInfo{
-			ContainerID: container.ID,
-			Name:        strings.Join(container.Names, ","),
-			State:       container.State,
-			Status:      container.Status,
-			Created:     created,
-			Command:     container.Command,
-			Project:     project.Name(),
-		})
+		out = append(out, newMkenvContainerInfo(container))
 	}
 
 	return out, nil
 }
 
-func (dc *DockerClient) CreateContainer(ctx context.Context, project *runtime.Project, imageTag string, envs, binds []string) (containerID string, containerPortReservation *host.PortReservation, err error) {
+// CreateContainer creates the project container. With egressLockdown the image command is wrapped so it only
+// starts after StartContainer has installed the egress firewall.
+func (dc *DockerClient) CreateContainer(ctx context.Context, project *runtime.Project, runID, imageTag string, envs, binds, capAdd []string, egressLockdown bool) (containerID string, containerPortReservation *host.PortReservation, err error) {
 	// Use folder name as hostname for friendly display in shell prompts
 	hostname := sanitizeHostname(filepath.Base(project.Path()))
 
@@ -118,7 +145,9 @@ func (dc *DockerClient) CreateContainer(ctx context.Context, project *runtime.Pr
 		AttachStdout: true,
 		AttachStderr: true,
 		Labels: map[string]string{
-			"mkenv.project": project.Name(),
+			"mkenv.project":      project.Name(),
+			"mkenv.project_path": project.Path(),
+			"mkenv.run_id":       runID,
 		},
 	}
 
@@ -145,6 +174,21 @@ func (dc *DockerClient) CreateContainer(ctx context.Context, project *runtime.Pr
 		ExtraHosts: []string{
 			"host.docker.internal:host-gateway",
 		},
+		// only root processes get them, the container user runs without capabilities
+		CapAdd: capAdd,
+	}
+
+	if egressLockdown {
+		imageInspect, inspectErr := dc.client.ImageInspect(ctx, imageTag)
+		if inspectErr != nil {
+			err = inspectErr
+			return
+		}
+		// the image command runs as the container user, it must not get a moment without the firewall
+		cfg.Entrypoint = []string{sandboxappconfig.UserLocalBin + "/mkenv", "sandbox", "egress-wait", "--"}
+		cfg.Cmd = append(slices.Clone(imageInspect.Config.Entrypoint), imageInspect.Config.Cmd...)
+		// a fresh root-owned tmpfs on every start, so the marker never outlives the firewall rules
+		hostCfg.Tmpfs = map[string]string{sandboxappconfig.EgressLockdownDir: "mode=0755"}
 	}
 
 	volumes, err := dc.resolveCacheVolumes(ctx, imageTag, project)
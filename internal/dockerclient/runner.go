@@ -8,13 +8,16 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	sandboxappconfig "github.com/0xa1bed0/mkenv/internal/apps/sandbox/config"
 	"github.com/0xa1bed0/mkenv/internal/logs"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
 )
 
 // setTerminalTitle sets the terminal tab/window title using ANSI escape sequences.
@@ -100,7 +103,7 @@ func (dc *DockerClient) AttachToRunning(ctx context.Context, containerID, displa
 	// stdin -> container
 	inErr := make(chan error, 1)
 	go func() {
-		_, e := io.Copy(hijack.Conn, os.Stdin)
+		_, e := io.Copy(hijack.Conn, term.Stdin())
 		inErr <- e
 	}()
 
@@ -240,6 +243,165 @@ func (dc *DockerClient) RunContainer(ctx context.Context, projectName, projectPa
 	return nil
 }
 
+// StartContainer starts the container and the sandbox daemon in it. If lockdownPorts are given the egress
+// firewall is installed first, letting the container reach only these host ports (see LockdownEgress).
+func (dc *DockerClient) StartContainer(ctx context.Context, projectName, containerID string, claimPort func() error, lockdownPorts []int) error {
+	err := claimPort()
+	if err != nil {
+		logs.Errorf("can't claim port. error: %v\nlet docker engine claim...", err)
+	}
+
+	err = dc.client.ContainerStart(ctx, containerID, container.StartOptions{})
+	if err != nil {
+		return err
+	}
+
+	if len(lockdownPorts) > 0 {
+		if err := dc.LockdownEgress(ctx, containerID, lockdownPorts); err != nil {
+			return err
+		}
+	}
+
+	return dc.startSandboxDaemon(ctx, projectName, containerID)
+}
+
+// WaitContainer blocks until the container stops running or ctx is done.
+func (dc *DockerClient) WaitContainer(ctx context.Context, containerID string) error {
+	statusCh, errCh := dc.client.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("container wait: %w", err)
+		}
+		return nil
+	case st := <-statusCh:
+		logs.Debugf("Container exited: %v", st)
+		return nil
+	}
+}
+
+// ExecCommand runs cmd in the running container as the non-root user in /workdir.
+// stdout and stderr are streamed to the host ones. If stdin is a terminal the command gets a TTY,
+// otherwise stdin is piped to the command as is. It returns the exit code of the command.
+// When ctx is done the command is interrupted, docker itself doesn't stop exec'd processes.
+func (dc *DockerClient) ExecCommand(ctx context.Context, containerID string, cmd []string, term *runtime.TerminalGuard) (int, error) {
+	tty := term.StdinIsTerminal()
+
+	// the shell records the pid of the command, it is needed to interrupt it
+	pidFile := fmt.Sprintf("/tmp/mkenv-exec-%d.pid", time.Now().UnixNano())
+	execResp, err := dc.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		User:         sandboxappconfig.UserName,
+		WorkingDir:   "/workdir",
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          tty,
+		Cmd:          append([]string{"/bin/sh", "-c", `echo $$ > "$0" && exec "$@"`, pidFile}, cmd...),
+	})
+	if err != nil {
+		return -1, fmt.Errorf("exec create: %w", err)
+	}
+
+	hijack, err := dc.client.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{Tty: tty})
+	if err != nil {
+		return -1, fmt.Errorf("exec attach: %w", err)
+	}
+	defer hijack.Close()
+
+	if tty {
+		restoreLogs := logs.Mute()
+		defer restoreLogs()
+
+		err = term.EnterRawAndWatch(func(width, height uint) {
+			_ = dc.client.ContainerExecResize(ctx, execResp.ID, container.ResizeOptions{
+				Height: height,
+				Width:  width,
+			})
+		})
+		if err != nil {
+			return -1, err
+		}
+		defer term.Restore()
+	}
+
+	// stdin -> container. Close write side on EOF so the command sees end of input.
+	go func() {
+		_, _ = io.Copy(hijack.Conn, os.Stdin)
+		_ = hijack.CloseWrite()
+	}()
+
+	outErr := make(chan error, 1)
+	go func() {
+		var e error
+		if tty {
+			// TTY=true => raw stream (no stdcopy)
+			_, e = io.Copy(os.Stdout, hijack.Reader)
+		} else {
+			_, e = stdcopy.StdCopy(os.Stdout, os.Stderr, hijack.Reader)
+		}
+		outErr <- e
+	}()
+
+	select {
+	case <-ctx.Done():
+		hijack.Close()
+		dc.stopExec(containerID, execResp.ID, pidFile)
+		return -1, ctx.Err()
+	case e := <-outErr:
+		if e != nil && !errors.Is(e, io.EOF) {
+			return -1, fmt.Errorf("stdout copy: %w", e)
+		}
+	}
+
+	inspectResp, err := dc.client.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return -1, fmt.Errorf("exec inspect: %w", err)
+	}
+	if _, err := dc.execAs(ctx, containerID, sandboxappconfig.UserName, []string{"rm", "-f", pidFile}); err != nil {
+		logs.Debugf("can't remove %s: %v", pidFile, err)
+	}
+
+	return inspectResp.ExitCode, nil
+}
+
+// execStopTimeout is how long an interrupted exec'd command has to exit before it is terminated.
+const execStopTimeout = 3 * time.Second
+
+// stopExec interrupts the command of the exec started by ExecCommand, like Ctrl+C would, and terminates it
+// if it is still running after execStopTimeout. The signals are sent by the container user, as the pid file
+// is written by the container user too.
+func (dc *DockerClient) stopExec(containerID, execID, pidFile string) {
+	// ctx of the command is done already
+	ctx, cancel := context.WithTimeout(context.Background(), 2*execStopTimeout)
+	defer cancel()
+
+	send := func(sig string) {
+		script := `kill -` + sig + ` "$(cat "$0")"`
+		if out, err := dc.execAs(ctx, containerID, sandboxappconfig.UserName, []string{"/bin/sh", "-c", script, pidFile}); err != nil {
+			logs.Debugf("can't send SIG%s to the exec'd command: %v: %s", sig, err, strings.TrimSpace(out))
+		}
+	}
+
+	send("INT")
+	deadline := time.Now().Add(execStopTimeout)
+	for time.Now().Before(deadline) {
+		inspect, err := dc.client.ContainerExecInspect(ctx, execID)
+		if err != nil || !inspect.Running {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if inspect, err := dc.client.ContainerExecInspect(ctx, execID); err == nil && inspect.Running {
+		send("TERM")
+	}
+
+	if _, err := dc.execAs(ctx, containerID, sandboxappconfig.UserName, []string{"rm", "-f", pidFile}); err != nil {
+		logs.Debugf("can't remove %s: %v", pidFile, err)
+	}
+}
+
 func (dc *DockerClient) KillContainer(containerID string) error {
 	// TODO: close attach
 	err := dc.client.ContainerKill(context.Background(), containerID, "SIGTERM")
@@ -270,9 +432,29 @@ func (dc *DockerClient) startSandboxDaemon(ctx context.Context, projectName, con
 	return nil
 }
 
+// LockdownEgress makes the container unable to connect anywhere but hostPorts of the host.
+// The container must be created with NET_ADMIN capability, egressLockdown and have iptables installed.
+func (dc *DockerClient) LockdownEgress(ctx context.Context, containerID string, hostPorts []int) error {
+	cmd := []string{sandboxappconfig.UserLocalBin + "/mkenv", "sandbox", "egress-lockdown"}
+	for _, port := range hostPorts {
+		cmd = append(cmd, "--host-port", strconv.Itoa(port))
+	}
+
+	out, err := dc.ExecAsRoot(ctx, containerID, cmd)
+	if err != nil {
+		return fmt.Errorf("egress lockdown: %w: %s", err, strings.TrimSpace(out))
+	}
+	return nil
+}
+
 func (dc *DockerClient) ExecAsRoot(ctx context.Context, containerID string, cmd []string) (string, error) {
+	return dc.execAs(ctx, containerID, "root", cmd)
+}
+
+// execAs runs cmd in the container as user and returns its combined output.
+func (dc *DockerClient) execAs(ctx context.Context, containerID, user string, cmd []string) (string, error) {
 	execCfg := container.ExecOptions{
-		User:         "root",
+		User:         user,
 		Cmd:          cmd,
 		AttachStdout: true,
 		AttachStderr: true,
@@ -14,7 +14,6 @@ type cacheVolume struct {
 	MountPath string
 }
 
-// TODO: make ability to cleanup the caches
 func (dc *DockerClient) resolveCacheVolumes(ctx context.Context, imageTag string, project *runtime.Project) ([]*cacheVolume, error) {
 	out := []*cacheVolume{}
 
@@ -5,12 +5,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	sandboxappconfig "github.com/0xa1bed0/mkenv/internal/apps/sandbox/config"
+	"github.com/0xa1bed0/mkenv/internal/audit"
 	"github.com/0xa1bed0/mkenv/internal/bricks/systems"
 	"github.com/0xa1bed0/mkenv/internal/bricksengine"
 	"github.com/0xa1bed0/mkenv/internal/dockerclient"
@@ -20,22 +24,32 @@ import (
 	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
 	"github.com/0xa1bed0/mkenv/internal/networking/shared"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
+	"github.com/0xa1bed0/mkenv/internal/state"
 )
 
 type OrchestratorExitSignal struct {
 	Err error
 }
 
+// ContainerSession is run against the started container instead of the interactive
+// entrypoint session. The container is killed once the session returns.
+type ContainerSession func(ctx context.Context, containerID string) error
+
 type ContainerOrchestrator struct {
 	rt                *runtime.Runtime
 	dockerClient      *dockerclient.DockerClient
 	controlAPI        *host.ControlListener
 	reverseProxy      *host.ReverseProxyServer
 	forwarderRegistry *host.ForwarderRegistry
+	egressProxy       *host.EgressProxy // nil unless egress mode is on
+	auditLog          *audit.Log
+	policy            guardrails.Policy
 	exitCh            chan OrchestratorExitSignal
 
 	binds []string
 
+	session ContainerSession
+
 	once sync.Once
 }
 
@@ -46,18 +60,32 @@ func NewContainerOrchestrator(rt *runtime.Runtime, binds []string, dockerClient
 	}
 
 	// Load policy for reverse proxy configuration
-	policy, err := guardrails.LoadPolicy()
+	globalPolicy, err := guardrails.LoadPolicy()
 	if err != nil {
 		return nil, fmt.Errorf("load policy: %w", err)
 	}
+	policy := globalPolicy.ForProject(rt.Project().Path())
+
+	// Every proxied connection of the run is recorded for 'mkenv audit'.
+	// The log is not closed on shutdown: connections closed by the shutdown are still recorded.
+	auditLog, err := audit.Open(hostappconfig.AuditLogPath(rt.Project().Name(), rt.RunID()), rt.RunID())
+	if err != nil {
+		return nil, err
+	}
+
+	kvStore, err := state.DefaultKVStore(rt.Ctx())
+	if err != nil {
+		return nil, err
+	}
+	portTrust := guardrails.NewPortTrust(kvStore, rt.Project().Name())
 
 	// Start reverse proxy server on random port
-	reverseProxy, err := host.StartReverseProxyServer(rt, policy)
+	reverseProxy, err := host.StartReverseProxyServer(rt, policy, portTrust, auditLog)
 	if err != nil {
 		return nil, fmt.Errorf("start reverse proxy: %w", err)
 	}
 
-	forwarderRegistry := host.NewForwarderRegistry(rt)
+	forwarderRegistry := host.NewForwarderRegistry(rt, auditLog)
 
 	return &ContainerOrchestrator{
 		rt:                rt,
@@ -65,11 +93,19 @@ func NewContainerOrchestrator(rt *runtime.Runtime, binds []string, dockerClient
 		controlAPI:        controlAPI,
 		reverseProxy:      reverseProxy,
 		forwarderRegistry: forwarderRegistry,
+		auditLog:          auditLog,
+		policy:            policy,
 		exitCh:            exitCh,
 		binds:             binds,
 	}, nil
 }
 
+// SetSession makes orchestrator run the container in the background and call session
+// instead of attaching the terminal to the container's entrypoint.
+func (co *ContainerOrchestrator) SetSession(session ContainerSession) {
+	co.session = session
+}
+
 func (co *ContainerOrchestrator) Start() error {
 	co.rt.GoNamed("ContainerOrchestrator;startEnv", func() {
 		co.startEnv()
@@ -97,16 +133,28 @@ func (co *ContainerOrchestrator) startEnv() {
 		co.controlAPI.ServerProtocol.Handle(co.onInstallRequest())
 		co.controlAPI.ServerProtocol.Handle(co.onLog())
 		co.controlAPI.ServerProtocol.Handle(co.onFetchLogs())
+		co.controlAPI.ServerProtocol.Handle(co.onSSHAgent())
+		co.controlAPI.ServerProtocol.Handle(co.onGitCredential())
 	})
 
 	containerCtx, cancelContainer := context.WithCancel(co.rt.Ctx())
 
 	co.rt.Container().SetStopContainer(cancelContainer)
 
+	capAdd, err := co.startEgressProxy(containerCtx)
+	if err != nil {
+		co.exitCh <- OrchestratorExitSignal{Err: err}
+		return
+	}
+
 	// Build environment variables including reverse proxy address
-	envs := co.getEnvVars()
+	envs, err := co.getEnvVars(containerCtx)
+	if err != nil {
+		co.exitCh <- OrchestratorExitSignal{Err: err}
+		return
+	}
 
-	containerID, containerPortRessservation, err := co.dockerClient.CreateContainer(containerCtx, co.rt.Project(), co.rt.Container().ImageTag(), envs, co.binds)
+	containerID, containerPortRessservation, err := co.dockerClient.CreateContainer(containerCtx, co.rt.Project(), co.rt.RunID(), co.rt.Container().ImageTag(), envs, co.binds, capAdd, co.egressProxy != nil)
 	if err != nil {
 		co.exitCh <- OrchestratorExitSignal{Err: err}
 		return
@@ -117,6 +165,10 @@ func (co *ContainerOrchestrator) startEnv() {
 
 	errChan := make(chan error, 1)
 	co.rt.GoNamed("RunContainer", func() {
+		if co.session != nil {
+			errChan <- co.runSession(containerCtx, containerID, containerPortRessservation.Claim)
+			return
+		}
 		err := co.dockerClient.RunContainer(containerCtx, co.rt.Project().Name(), co.rt.Project().Path(), containerID, containerPortRessservation.Claim, co.rt.Term())
 		errChan <- err
 	})
@@ -137,9 +189,68 @@ func (co *ContainerOrchestrator) startEnv() {
 	}
 }
 
+func (co *ContainerOrchestrator) runSession(ctx context.Context, containerID string, claimPort func() error) error {
+	defer func() {
+		// container may be stopped already, so remove it gracefully with the fresh context
+		if err := co.dockerClient.RemoveContainer(context.Background(), containerID); err != nil {
+			logs.Errorf("can't remove container: error: %v", err)
+		}
+	}()
+
+	var lockdownPorts []int
+	if co.egressProxy != nil {
+		// fail closed: nothing of the container user starts before only these host ports are reachable
+		lockdownPorts = []int{co.controlPort(), co.reverseProxy.Port(), co.egressProxy.Port()}
+	}
+
+	if err := co.dockerClient.StartContainer(ctx, co.rt.Project().Name(), containerID, claimPort, lockdownPorts); err != nil {
+		return err
+	}
+
+	return co.session(ctx, containerID)
+}
+
+// startEgressProxy starts the egress proxy if egress mode is on for the project and returns
+// capabilities the container needs for it.
+func (co *ContainerOrchestrator) startEgressProxy(ctx context.Context) ([]string, error) {
+	egress := co.rt.Project().EnvConfig(ctx).Egress()
+	if !egress.Enabled {
+		return nil, nil
+	}
+
+	if co.session == nil {
+		return nil, errors.New("egress mode requires the container to run under the supervisor")
+	}
+
+	egressProxy, err := host.StartEgressProxy(co.rt, egress.AllowedDomains, co.rt.Approve, co.auditLog)
+	if err != nil {
+		return nil, err
+	}
+	co.egressProxy = egressProxy
+
+	if len(egress.AllowedDomains) == 0 {
+		logs.Infof("Egress mode: the sandbox can only reach domains you approve")
+	} else {
+		logs.Infof("Egress mode: the sandbox can only reach %s and domains you approve", strings.Join(egress.AllowedDomains, ", "))
+	}
+
+	// to install the firewall, see dockerclient.StartContainer
+	return []string{"NET_ADMIN"}, nil
+}
+
+// controlPort returns the host port of the control plane.
+func (co *ContainerOrchestrator) controlPort() int {
+	_, portStr, err := net.SplitHostPort(co.controlAPI.Address)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(portStr)
+	return port
+}
+
 // getEnvVars builds the complete set of environment variables for the container,
-// including control API and reverse proxy addresses
-func (co *ContainerOrchestrator) getEnvVars() []string {
+// including control API and reverse proxy addresses and the `env` section of .mkenv
+func (co *ContainerOrchestrator) getEnvVars(ctx context.Context) ([]string, error) {
 	envs := make([]string, len(co.controlAPI.Env))
 	copy(envs, co.controlAPI.Env)
 
@@ -148,13 +259,47 @@ func (co *ContainerOrchestrator) getEnvVars() []string {
 	reverseProxyEnv := fmt.Sprintf("MKENV_REVERSE_PROXY=host.docker.internal:%d", reverseProxyPort)
 	envs = append(envs, reverseProxyEnv)
 
+	// In egress mode proxy-aware tools (package managers, curl, git) reach the internet through the host.
+	// Direct connections are rejected by the container firewall anyway.
+	if co.egressProxy != nil {
+		proxyURL := fmt.Sprintf("http://host.docker.internal:%d", co.egressProxy.Port())
+		noProxy := "localhost,127.0.0.1,::1"
+		envs = append(envs,
+			"HTTP_PROXY="+proxyURL, "HTTPS_PROXY="+proxyURL, "http_proxy="+proxyURL, "https_proxy="+proxyURL,
+			"NO_PROXY="+noProxy, "no_proxy="+noProxy,
+		)
+	}
+
 	// Pass host timezone so container timestamps match the host
 	if tz := hostTimezone(); tz != "" {
 		envs = append(envs, "TZ="+tz)
 	}
 
-	logs.Debugf("Container env vars: %v", envs)
-	return envs
+	// Forward host SSH agent through the control plane, keys stay on the host
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		envs = append(envs, "SSH_AUTH_SOCK="+sandboxappconfig.SSHAgentSocket)
+	}
+
+	// Use mkenv as git credential helper for allowed hosts only
+	if gitHosts := co.policy.GitCredentialHosts(); len(gitHosts) > 0 {
+		envs = append(envs, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(gitHosts)))
+		for i, gitHost := range gitHosts {
+			envs = append(envs,
+				fmt.Sprintf("GIT_CONFIG_KEY_%d=credential.https://%s.helper", i, gitHost),
+				fmt.Sprintf("GIT_CONFIG_VALUE_%d=!%s/mkenv sandbox git-credential", i, sandboxappconfig.UserLocalBin),
+			)
+		}
+	}
+
+	projectEnvs, err := runtime.ResolveContainerEnv(co.rt.Project().EnvConfig(ctx))
+	if err != nil {
+		return nil, err
+	}
+	envs = append(envs, projectEnvs...)
+
+	// values may contain secrets, so only names are logged
+	logs.Debugf("Container env vars: %v", envNames(envs))
+	return envs, nil
 }
 
 // hostTimezone returns the IANA timezone name of the host (e.g. "Europe/Kyiv").
@@ -211,7 +356,24 @@ func (co *ContainerOrchestrator) onPortSnapshot() (string, protocol.ControlComma
 			}
 		}
 
-		return &shared.OnSnapshotResponse{Response: responses}, nil
+		udpResponses := map[int]string{} // port => ok|error
+
+		for port := range snapshot.UDPListeners {
+			err := co.forwarderRegistry.AddUDP(port)
+			if err != nil {
+				udpResponses[port] = fmt.Sprintf("error while binding udp port %d: %v", port, err)
+				continue
+			}
+			udpResponses[port] = "ok"
+		}
+
+		for _, port := range co.forwarderRegistry.ListUDP() {
+			if _, ok := snapshot.UDPListeners[port]; !ok {
+				co.forwarderRegistry.RemoveUDP(port)
+			}
+		}
+
+		return &shared.OnSnapshotResponse{Response: responses, UDPResponse: udpResponses}, nil
 	}
 }
 
@@ -302,6 +464,47 @@ func (co *ContainerOrchestrator) onInstallRequest() (string, protocol.ControlCom
 	}
 }
 
+func (co *ContainerOrchestrator) onSSHAgent() (string, protocol.ControlCommandHandler) {
+	return "mkenv.sandbox.ssh-agent", func(ctx context.Context, req protocol.ControlSignalEnvelope) (any, error) {
+		var request shared.SSHAgentMessage
+		err := protocol.UnpackControlSignalEnvelope(req, &request)
+		if err != nil {
+			return nil, err
+		}
+
+		approve := func(ctx context.Context, text string) bool {
+			return co.rt.ApproveKind(ctx, "ssh-agent", text)
+		}
+		response, err := host.ForwardSSHAgentMessage(ctx, request.Message, approve)
+		if err != nil {
+			logs.Warnf("ssh agent request failed: %v", err)
+			response = shared.SSHAgentFailure
+		}
+
+		return &shared.SSHAgentMessage{Message: response}, nil
+	}
+}
+
+func (co *ContainerOrchestrator) onGitCredential() (string, protocol.ControlCommandHandler) {
+	return "mkenv.sandbox.git-credential", func(ctx context.Context, req protocol.ControlSignalEnvelope) (any, error) {
+		var request shared.GitCredentialRequest
+		err := protocol.UnpackControlSignalEnvelope(req, &request)
+		if err != nil {
+			return nil, err
+		}
+
+		if !co.policy.AllowGitCredential(request.Host) {
+			return nil, fmt.Errorf("git credentials for %s are not allowed by policy", request.Host)
+		}
+
+		if !co.rt.ApproveKind(ctx, "git-credential", fmt.Sprintf("Allow sandbox to use your git credentials for %s://%s?", request.Protocol, request.Host)) {
+			return nil, errors.New("git credential request is denied")
+		}
+
+		return host.FillGitCredential(ctx, request)
+	}
+}
+
 func (co *ContainerOrchestrator) onLog() (string, protocol.ControlCommandHandler) {
 	return "mkenv.sandbox.log", func(ctx context.Context, req protocol.ControlSignalEnvelope) (any, error) {
 		var entry shared.LogEntry
@@ -358,3 +561,12 @@ func (co *ContainerOrchestrator) onFetchLogs() (string, protocol.ControlCommandH
 		}, nil
 	}
 }
+
+func envNames(envs []string) []string {
+	names := make([]string, len(envs))
+	for i, env := range envs {
+		name, _, _ := strings.Cut(env, "=")
+		names[i] = name
+	}
+	return names
+}
@@ -182,19 +182,17 @@ func (p *planner) buildPlan(ctx context.Context) (*BuildPlan, error) {
 		p.bricks[p.entrypointBrick.ID()] = p.entrypointBrick
 	}
 
-	// deterministic brick order: sort brick IDs
-	ids := make([]string, 0, len(p.bricks))
-	for id := range p.bricks {
-		ids = append(ids, string(id))
+	// deterministic brick order: required bricks first, the rest sorted by ID
+	bricks := make([]bricksengine.Brick, 0, len(p.bricks))
+	for _, brick := range p.bricks {
+		bricks = append(bricks, brick)
+	}
+	ordered, err := bricksengine.OrderBricks(bricks)
+	if err != nil {
+		return nil, err
 	}
-	sort.Strings(ids)
 
-	for _, sid := range ids {
-		if sid == "" {
-			continue
-		}
-		id := bricksengine.BrickID(sid)
-		brick := p.bricks[id]
+	for _, brick := range ordered {
 		if len(brick.RootRun()) > 0 {
 			runs := brick.RootRun()
 			commands := make([]string, len(runs))
@@ -320,6 +318,7 @@ func (p *planner) processSystemCandidates(ctx context.Context) error {
 }
 
 func (p *planner) estimateBricks(ctx context.Context) error {
+	// custom bricks (definitions and plugins) are registered when the env config is resolved
 	enabledBricks := p.project.EnvConfig(ctx).EnableBricks()
 	if p.project.EnvConfig(ctx).DefaultEntrypointBrickID() != "" {
 		enabledBricks = append(enabledBricks, p.project.EnvConfig(ctx).DefaultEntrypointBrickID())
@@ -328,39 +327,13 @@ func (p *planner) estimateBricks(ctx context.Context) error {
 	forceEnabled := bricksengine.ToSet(enabledBricks)
 	forceDsiabled := bricksengine.ToSet(p.project.EnvConfig(ctx).DisableBricks())
 
-	add := func(b bricksengine.Brick, reason string) {
-		id := b.ID()
-		if forceDsiabled[id] {
-			logs.Warnf("skipping disabled brick %s (reason: %s)", id, reason)
-			return
-		}
-
-		if b.Kinds().Contains(bricksengine.BrickKindSystem) {
-			// since we should completely discard non selected systems we can't add system bricks to the main bricks map.
-			// we will add single chosen system as entrypoint candidate later when we discard the rest
-			p.systemCandidates[id] = b
-			return
-		}
-
-		if b.Kinds().Contains(bricksengine.BrickKindEntrypoint) {
-			p.entrypointCandidates[id] = b
-		}
-
-		if _, ok := p.bricks[id]; !ok {
-			p.bricks[id] = b
-		}
-	}
-
+	requests := []bricksengine.BrickRequest{}
 	for _, id := range enabledBricks {
-		if factory, ok := bricksengine.DefaultBricksRegistry.GetBrickFactory(id); ok {
-			b, err := factory(p.project.EnvConfig(ctx).BricksConfigs()[id])
-			if err != nil {
-				return err
-			}
-			add(b, "enabled by user settings")
-		} else {
-			logs.Warnf("Unknown brick '%s'. Skipping...", id)
-		}
+		requests = append(requests, bricksengine.BrickRequest{
+			ID:       id,
+			Metadata: p.project.EnvConfig(ctx).BricksConfigs()[id],
+			Reason:   "enabled by user settings",
+		})
 	}
 
 	if !p.project.EnvConfig(ctx).ShouldDisableAuto() {
@@ -384,17 +357,31 @@ func (p *planner) estimateBricks(ctx context.Context) error {
 				continue
 			}
 
-			if factory, ok := bricksengine.DefaultBricksRegistry.GetBrickFactory(id); ok {
-				b, err := factory(meta)
-				if err != nil {
-					return err
-				}
+			requests = append(requests, bricksengine.BrickRequest{ID: id, Metadata: meta, Reason: "proposed by detector"})
+		}
+	}
 
-				add(b, "proposed by detector")
-			} else {
-				logs.Warnf("Detector proposed unknown brick %s", id)
-			}
+	// one brick per id: dependencies of all bricks are merged into one graph
+	bricks, err := bricksengine.DefaultBricksRegistry.ResolveBricks(requests, forceDsiabled)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range bricks {
+		id := b.ID()
+
+		if b.Kinds().Contains(bricksengine.BrickKindSystem) {
+			// since we should completely discard non selected systems we can't add system bricks to the main bricks map.
+			// we will add single chosen system as entrypoint candidate later when we discard the rest
+			p.systemCandidates[id] = b
+			continue
 		}
+
+		if b.Kinds().Contains(bricksengine.BrickKindEntrypoint) {
+			p.entrypointCandidates[id] = b
+		}
+
+		p.bricks[id] = b
 	}
 
 	return nil
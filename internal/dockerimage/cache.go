@@ -75,6 +75,19 @@ func cacheKeyFromProject(ctx context.Context, project *runtime.Project) state.KV
 	return state.KVStoreKey(signature)
 }
 
+// cacheKeyPrefix is prepended to the keys of the image cache in the KV store.
+const cacheKeyPrefix = state.KVStoreKey("image-cache:")
+
+// IsCacheKey returns true if the KV store key belongs to the image cache. Keys written before the cache had
+// a prefix are bare sha256 hex strings.
+func IsCacheKey(key state.KVStoreKey) bool {
+	if strings.HasPrefix(string(key), string(cacheKeyPrefix)) {
+		return true
+	}
+	_, err := hex.DecodeString(string(key))
+	return len(key) == sha256.Size*2 && err == nil
+}
+
 type DockerImageCache struct {
 	kvStore *state.KVStore
 }
@@ -111,7 +124,7 @@ func (dic *DockerImageCache) get(ctx context.Context, key state.KVStoreKey) (Ima
 	}
 
 	logs.Debugf("cache.get: looking up key=%s", key)
-	entry, found, err := dic.kvStore.Get(ctx, key)
+	entry, found, err := dic.kvStore.Get(ctx, cacheKeyPrefix+key)
 	if err != nil {
 		logs.Debugf("cache.get: error during lookup key=%s: %v", key, err)
 		return "", false, key
@@ -139,7 +152,7 @@ func (dic *DockerImageCache) delete(ctx context.Context, key state.KVStoreKey) {
 	}
 
 	logs.Debugf("cache.delete: deleting key=%s", key)
-	err := dic.kvStore.Delete(ctx, key)
+	err := dic.kvStore.Delete(ctx, cacheKeyPrefix+key)
 	if err != nil {
 		logs.Warnf("Can't delete image id from cache for this project. %v Skipping...", err)
 	}
@@ -152,7 +165,7 @@ func (dic *DockerImageCache) set(ctx context.Context, key state.KVStoreKey, valu
 	}
 
 	logs.Debugf("cache.set: key=%s, value=%s", key, value)
-	err := dic.kvStore.Upsert(ctx, key, string(value))
+	err := dic.kvStore.Upsert(ctx, cacheKeyPrefix+key, string(value))
 	if err != nil {
 		logs.Warnf("Can't upsert docker image cache. %v Skipping...", err)
 	}
@@ -189,9 +202,9 @@ func (dic *DockerImageCache) StopBuilding(ctx context.Context, key state.KVStore
 	if dic.kvStore == nil {
 		return
 	}
-	entry, found, _ := dic.kvStore.Get(ctx, key)
+	entry, found, _ := dic.kvStore.Get(ctx, cacheKeyPrefix+key)
 	if found && entry.Value == expectedBuildTag {
-		err := dic.kvStore.Delete(ctx, key)
+		err := dic.kvStore.Delete(ctx, cacheKeyPrefix+key)
 		if err != nil {
 			logs.Warnf("Can't delete image build tag. Skipping... \n%v", err)
 		}
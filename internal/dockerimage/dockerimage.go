@@ -129,7 +129,8 @@ func (dib *DockerImageResolver) ResolveImageID(ctx context.Context, project *run
 
 		imageTag := composeImageTagForProject(project, runConfigCacheKey, dockerfileCacheKey)
 		logs.Debugf("building image with tag: %s", imageTag)
-		dockerImageID, err := dib.dockerClient.BuildImage(ctx, df.String(), imageTag)
+		// the label is not part of the Dockerfile, so images stay shared by projects with the same Dockerfile
+		dockerImageID, err := dib.dockerClient.BuildImage(ctx, df.String(), imageTag, map[string]string{"mkenv.project": project.Name()})
 		if err != nil {
 			dib.imageCache.StopBuilding(ctx, runConfigCacheKey, buildingTag)
 			dib.imageCache.StopBuilding(ctx, dockerfileCacheKey, buildingTag)
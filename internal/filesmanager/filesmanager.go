@@ -9,8 +9,11 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/0xa1bed0/mkenv/internal/fsops"
+	"github.com/0xa1bed0/mkenv/internal/fswalk"
 )
 
 // FileManager wraps file discovery and streaming readers rooted at a project
@@ -41,9 +44,12 @@ func (ptr *folderPtr) GetFileScanner(filepath string, bufSize uint8) (FileScanne
 }
 
 // NewFileManager builds a FileManager rooted at dir using the default OS
-// implementations.
+// implementations. The tree is walked concurrently, skipping what .gitignore and .mkenvignore files ignore,
+// and directory listings are cached for the lifetime of the FileManager.
 func NewFileManager(dir string) (FileManager, error) {
-	return NewFileManagerWithOps(dir, fsops.DefaultOps())
+	ops := fsops.DefaultOps()
+	ops.Walker = fswalk.New(fswalk.WithIgnoreFiles(fswalk.GitIgnoreFile, fswalk.MkenvIgnoreFile))
+	return NewFileManagerWithOps(dir, ops)
 }
 
 // NewFileManagerWithOps is the internal constructor that allows injecting
@@ -116,7 +122,8 @@ func (p *folderPtr) HasFilesWithExtensions(extsCSV string, ignorePaths []string)
 		return false, false
 	}
 
-	found := false
+	// the walker may call walkFn concurrently
+	var found atomic.Bool
 	sentinel := errors.New("found") // used to break WalkDir early
 
 	walkFn := func(path string, d fs.DirEntry, walkErr error) error {
@@ -137,7 +144,7 @@ func (p *folderPtr) HasFilesWithExtensions(extsCSV string, ignorePaths []string)
 
 		ext := strings.ToLower(p.ops.Path.Ext(d.Name()))
 		if _, ok := exts[ext]; ok {
-			found = true
+			found.Store(true)
 			return sentinel // stop walking early
 		}
 		return nil
@@ -146,7 +153,7 @@ func (p *folderPtr) HasFilesWithExtensions(extsCSV string, ignorePaths []string)
 	if err := p.ops.Walker.WalkDir(p.root, walkFn); err != nil && !errors.Is(err, sentinel) {
 		return false, err
 	}
-	return found, nil
+	return found.Load(), nil
 }
 
 // parseExtsCSV turns "go, ts, .tsx" into map{".go":{}, ".ts":{}, ".tsx":{}}
@@ -211,6 +218,8 @@ func (ptr *folderPtr) FindFile(filename string, ignorePaths []string) ([]string,
 		return false, false
 	}
 
+	// the walker may call walkFn concurrently
+	var mu sync.Mutex
 	var results []string
 
 	walkFn := func(path string, d fs.DirEntry, err error) error {
@@ -235,7 +244,9 @@ func (ptr *folderPtr) FindFile(filename string, ignorePaths []string) ([]string,
 				return err
 			}
 			// Normalize to forward slashes for stable output (cross-platform).
+			mu.Lock()
 			results = append(results, toSlashClean(rel))
+			mu.Unlock()
 		}
 		return nil
 	}
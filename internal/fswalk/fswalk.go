@@ -0,0 +1,300 @@
+// Package fswalk walks project trees with a bounded pool of workers.
+// Walkers honor ignore files (.gitignore syntax) and cache directory listings,
+// so walking the same tree again within a run doesn't hit the disk.
+package fswalk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Ignore files honored by project walks.
+const (
+	GitIgnoreFile   = ".gitignore"
+	MkenvIgnoreFile = ".mkenvignore"
+)
+
+// Walker walks directory trees concurrently. It is safe for concurrent use.
+// Walks of one walker share the cached listings, so one walker can serve several scans of the same tree.
+type Walker struct {
+	cfg walkConfig
+
+	mu       sync.Mutex
+	listings map[string][]fs.DirEntry
+	ignores  map[ignoreKey]*ignoreFile // nil if the folder has no ignore files
+}
+
+// walkConfig is what a walk skips and how many goroutines it uses.
+type walkConfig struct {
+	workers     int
+	ignoreFiles []string
+	skipDirs    map[string]bool
+}
+
+// ignoreKey identifies the parsed ignore files of a folder: walks may honor different ignore files.
+type ignoreKey struct {
+	dir   string
+	names string
+}
+
+// Option configures a walker, see New, or a single walk, see Walker.Walk.
+type Option func(*walkConfig)
+
+// WithWorkers sets the number of goroutines calling the walk function. Default: twice the number of CPUs, at least 4.
+func WithWorkers(n int) Option {
+	return func(c *walkConfig) {
+		if n > 0 {
+			c.workers = n
+		}
+	}
+}
+
+// WithIgnoreFiles makes the walker skip files and folders matched by the ignore files with these names
+// found in the walked tree, e.g. GitIgnoreFile.
+func WithIgnoreFiles(names ...string) Option {
+	return func(c *walkConfig) {
+		c.ignoreFiles = append(c.ignoreFiles, names...)
+	}
+}
+
+// WithSkipDirs makes the walker skip folders with these names at any depth.
+func WithSkipDirs(names ...string) Option {
+	return func(c *walkConfig) {
+		for _, name := range names {
+			c.skipDirs[name] = true
+		}
+	}
+}
+
+func New(opts ...Option) *Walker {
+	w := &Walker{
+		cfg:      walkConfig{workers: max(4, 2*goruntime.NumCPU()), skipDirs: map[string]bool{}},
+		listings: map[string][]fs.DirEntry{},
+		ignores:  map[ignoreKey]*ignoreFile{},
+	}
+	for _, opt := range opts {
+		opt(&w.cfg)
+	}
+	return w
+}
+
+// config returns the settings of a walk: the walker's ones with opts applied on top.
+func (w *Walker) config(opts []Option) *walkConfig {
+	cfg := &walkConfig{
+		workers:     w.cfg.workers,
+		ignoreFiles: slices.Clone(w.cfg.ignoreFiles),
+		skipDirs:    maps.Clone(w.cfg.skipDirs),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WalkDir implements fsops.DirWalker. See Walk.
+func (w *Walker) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return w.Walk(context.Background(), root, fn)
+}
+
+// Walk is filepath.WalkDir that calls fn from several goroutines at once, in no particular order.
+// fn must be safe for concurrent use. Ignored files and folders never reach fn.
+// As with filepath.WalkDir, returning fs.SkipDir for a folder skips its content, returning fs.SkipAll stops the walk
+// and any other error stops the walk and is returned. Symlinks are not followed.
+// opts add to the walker's settings for this walk only, e.g. folders one scan skips and another doesn't.
+func (w *Walker) Walk(ctx context.Context, root string, fn fs.WalkDirFunc, opts ...Option) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.walk(ctx, w.config(opts), root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+type walkJob struct {
+	path    string
+	entry   fs.DirEntry
+	ignores []*ignoreFile // ignore files of the folders above the entry, root first
+}
+
+type walkState struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []walkJob
+	pending int // jobs queued or in progress
+	err     error
+}
+
+func (w *Walker) walk(ctx context.Context, cfg *walkConfig, root string, rootEntry fs.DirEntry, fn fs.WalkDirFunc) error {
+	st := &walkState{queue: []walkJob{{path: root, entry: rootEntry}}, pending: 1}
+	st.cond = sync.NewCond(&st.mu)
+
+	// wake up the workers when ctx is done, so they can stop
+	stop := context.AfterFunc(ctx, func() {
+		st.mu.Lock()
+		if st.err == nil {
+			st.err = ctx.Err()
+		}
+		st.cond.Broadcast()
+		st.mu.Unlock()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for range cfg.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, ok := st.next()
+				if !ok {
+					return
+				}
+				children, err := w.visit(cfg, job, fn)
+				st.done(children, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return st.err
+}
+
+// next blocks until there is a job. ok is false when the walk is over.
+func (st *walkState) next() (job walkJob, ok bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for len(st.queue) == 0 && st.pending > 0 && st.err == nil {
+		st.cond.Wait()
+	}
+	if st.pending == 0 || st.err != nil {
+		return walkJob{}, false
+	}
+	// last in, first out: the queue stays as small as the tree is deep, not as wide
+	job = st.queue[len(st.queue)-1]
+	st.queue = st.queue[:len(st.queue)-1]
+	return job, true
+}
+
+func (st *walkState) done(children []walkJob, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err != nil && st.err == nil {
+		st.err = err
+	}
+	st.queue = append(st.queue, children...)
+	st.pending += len(children) - 1
+	if st.pending == 0 || st.err != nil {
+		st.cond.Broadcast() // the walk is over
+		return
+	}
+	// the current worker takes one of the new jobs itself
+	for range min(len(children)-1, len(st.queue)) {
+		st.cond.Signal()
+	}
+}
+
+// visit calls fn for the job's entry and, for folders, returns jobs of their content.
+func (w *Walker) visit(cfg *walkConfig, job walkJob, fn fs.WalkDirFunc) ([]walkJob, error) {
+	if err := fn(job.path, job.entry, nil); err != nil {
+		if errors.Is(err, fs.SkipDir) && job.entry.IsDir() {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !job.entry.IsDir() {
+		return nil, nil
+	}
+
+	entries, err := w.readDir(job.path)
+	if err != nil {
+		if err := fn(job.path, job.entry, err); err != nil && !errors.Is(err, fs.SkipDir) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	ignores := job.ignores
+	if dirIgnores := w.readIgnoreFiles(cfg, job.path, entries); dirIgnores != nil {
+		ignores = append(append([]*ignoreFile{}, job.ignores...), dirIgnores)
+	}
+
+	children := make([]walkJob, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && cfg.skipDirs[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(job.path, entry.Name())
+		if isIgnored(ignores, path, entry.IsDir()) {
+			continue
+		}
+		children = append(children, walkJob{path: path, entry: entry, ignores: ignores})
+	}
+	return children, nil
+}
+
+func (w *Walker) readDir(dir string) ([]fs.DirEntry, error) {
+	w.mu.Lock()
+	entries, ok := w.listings[dir]
+	w.mu.Unlock()
+	if ok {
+		return entries, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	w.listings[dir] = entries
+	w.mu.Unlock()
+	return entries, nil
+}
+
+// readIgnoreFiles parses the ignore files among the folder entries. Returns nil if there are none.
+func (w *Walker) readIgnoreFiles(cfg *walkConfig, dir string, entries []fs.DirEntry) *ignoreFile {
+	if len(cfg.ignoreFiles) == 0 {
+		return nil
+	}
+
+	key := ignoreKey{dir: dir, names: strings.Join(cfg.ignoreFiles, "/")}
+	w.mu.Lock()
+	parsed, ok := w.ignores[key]
+	w.mu.Unlock()
+	if ok {
+		return parsed
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(cfg.ignoreFiles, entry.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue // unreadable ignore files ignore nothing
+		}
+		if parsed == nil {
+			parsed = &ignoreFile{dir: dir}
+		}
+		parsed.rules = append(parsed.rules, parseIgnoreRules(data)...)
+	}
+
+	w.mu.Lock()
+	w.ignores[key] = parsed
+	w.mu.Unlock()
+	return parsed
+}
@@ -0,0 +1,234 @@
+// Tests in this file exercise concurrent walks, ignore files and walk benchmarks on a synthetic 100k-file tree.
+package fswalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func writeTree(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", rel, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+}
+
+// walkFiles returns the sorted slash separated paths of the files the walker visits.
+func walkFiles(t *testing.T, w *Walker, root string, opts ...Option) []string {
+	t.Helper()
+	var mu sync.Mutex
+	got := []string{}
+	err := w.Walk(context.Background(), root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		mu.Lock()
+		got = append(got, filepath.ToSlash(rel))
+		mu.Unlock()
+		return nil
+	}, opts...)
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestWalkHonorsIgnoreFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":              "*.log\n!keep.log\n/build/\ndocs/**/*.tmp\n# comment\n\n",
+		".mkenvignore":            "fixtures/\n",
+		"app.log":                 "",
+		"keep.log":                "",
+		"main.go":                 "",
+		"build/out.bin":           "",
+		"sub/build/src.go":        "",
+		"sub/.gitignore":          "local.txt\n",
+		"sub/local.txt":           "",
+		"local.txt":               "",
+		"docs/a/b/draft.tmp":      "",
+		"docs/readme.md":          "",
+		"testdata/fixtures/k.pem": "",
+		"node_modules/x/index.js": "",
+	})
+
+	w := New(WithIgnoreFiles(GitIgnoreFile, MkenvIgnoreFile), WithSkipDirs("node_modules"))
+	got := walkFiles(t, w, root)
+	want := []string{".gitignore", ".mkenvignore", "docs/readme.md", "keep.log", "local.txt", "main.go", "sub/.gitignore", "sub/build/src.go"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("walked %v, want %v", got, want)
+	}
+
+	// without ignore files everything but skipped folders is walked
+	got = walkFiles(t, New(WithSkipDirs("node_modules")), root)
+	if len(got) != 13 {
+		t.Fatalf("walked %d files without ignore files, want 13: %v", len(got), got)
+	}
+}
+
+func TestWalkSkipDirAndErrors(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a/1": "", "a/2": "", "b/1": "", "c": ""})
+
+	var visited atomic.Int32
+	err := New(WithWorkers(3)).Walk(context.Background(), root, func(path string, d fs.DirEntry, err error) error {
+		if d.IsDir() && d.Name() == "a" {
+			return fs.SkipDir
+		}
+		visited.Add(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	// root, b, b/1, c
+	if visited.Load() != 4 {
+		t.Fatalf("visited %d entries, want 4", visited.Load())
+	}
+
+	stop := errors.New("stop")
+	err = New().Walk(context.Background(), root, func(path string, d fs.DirEntry, err error) error {
+		if !d.IsDir() {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("Walk returned %v, want the error of the walk function", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := New().Walk(ctx, root, func(string, fs.DirEntry, error) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Walk returned %v on a canceled context", err)
+	}
+}
+
+func TestWalkCachesListings(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"a/1": "", "b/2": ""})
+
+	w := New()
+	first := walkFiles(t, w, root)
+	writeTree(t, root, map[string]string{"a/3": ""})
+	if second := walkFiles(t, w, root); !slices.Equal(first, second) {
+		t.Fatalf("second walk listed %v, want cached %v", second, first)
+	}
+	if fresh := walkFiles(t, New(), root); len(fresh) != 3 {
+		t.Fatalf("new walker listed %v, want 3 files", fresh)
+	}
+}
+
+func TestWalkOptionsApplyToOneWalk(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".mkenvignore":        "*.log\n",
+		"app.log":             "",
+		"main.go":             "",
+		"node_modules/dep.js": "",
+	})
+
+	w := New()
+	want := []string{".mkenvignore", "main.go"}
+	if got := walkFiles(t, w, root, WithSkipDirs("node_modules"), WithIgnoreFiles(MkenvIgnoreFile)); !slices.Equal(got, want) {
+		t.Fatalf("walk with options listed %v, want %v", got, want)
+	}
+	// the next walk of the same walker doesn't inherit them, but reuses the listings
+	writeTree(t, root, map[string]string{"new.go": ""})
+	want = []string{".mkenvignore", "app.log", "main.go", "node_modules/dep.js"}
+	if got := walkFiles(t, w, root); !slices.Equal(got, want) {
+		t.Fatalf("walk without options listed %v, want %v", got, want)
+	}
+}
+
+// makeBenchTree creates 100 folders with 10 subfolders of 100 small files each: 100k files.
+func makeBenchTree(b *testing.B) string {
+	b.Helper()
+	root := b.TempDir()
+	content := []byte("package main\n\nfunc main() {}\n")
+	for i := range 100 {
+		for j := range 10 {
+			dir := filepath.Join(root, fmt.Sprintf("pkg%03d", i), fmt.Sprintf("sub%02d", j))
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				b.Fatalf("mkdir: %v", err)
+			}
+			for k := range 100 {
+				if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%03d.go", k)), content, 0o644); err != nil {
+					b.Fatalf("write: %v", err)
+				}
+			}
+		}
+	}
+	return root
+}
+
+// readFile stands for the work a scanner does per file.
+func readFile(path string, d fs.DirEntry, err error) error {
+	if err != nil || d.IsDir() {
+		return err
+	}
+	_, err = os.ReadFile(path)
+	return err
+}
+
+func BenchmarkWalkDirSerial100k(b *testing.B) {
+	root := makeBenchTree(b)
+	b.ResetTimer()
+	for range b.N {
+		if err := filepath.WalkDir(root, readFile); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWalkConcurrent100k(b *testing.B) {
+	root := makeBenchTree(b)
+	b.ResetTimer()
+	for range b.N {
+		if err := New(WithIgnoreFiles(GitIgnoreFile, MkenvIgnoreFile)).Walk(context.Background(), root, readFile); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWalkCachedListing100k(b *testing.B) {
+	root := makeBenchTree(b)
+	w := New(WithIgnoreFiles(GitIgnoreFile, MkenvIgnoreFile))
+	noop := func(string, fs.DirEntry, error) error { return nil }
+	if err := w.Walk(context.Background(), root, noop); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for range b.N {
+		if err := w.Walk(context.Background(), root, noop); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
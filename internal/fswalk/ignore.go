@@ -0,0 +1,127 @@
+package fswalk
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFile holds the rules of the ignore files of a folder.
+type ignoreFile struct {
+	dir   string
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	negate  bool // "!pattern" re-includes what earlier rules ignored
+	dirOnly bool // "pattern/" matches folders only
+	re      *regexp.Regexp
+}
+
+// parseIgnoreRules parses .gitignore syntax: blank lines and # comments are skipped,
+// a leading "!" negates the pattern, a trailing "/" matches folders only, patterns with a "/" elsewhere
+// are relative to the folder of the ignore file, others match names at any depth.
+// "*" and "?" don't match "/", "**" matches any number of folders.
+func parseIgnoreRules(data []byte) []ignoreRule {
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		prefix := "^(?:.*/)?"
+		if anchored {
+			prefix = "^"
+		}
+		re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+		if err != nil {
+			continue // git skips broken patterns too
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// isIgnored applies the rules of the ignore files (root first) to path, the last matching rule wins.
+func isIgnored(ignores []*ignoreFile, path string, isDir bool) bool {
+	ignored := false
+	for _, f := range ignores {
+		rel, err := filepath.Rel(f.dir, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range f.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if rule.negate == ignored && rule.re.MatchString(rel) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,70 @@
+package guardrails
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"path"
+	"strings"
+)
+
+// ValidateDomainPattern checks an egress allowlist entry: a host name, optionally with * wildcards
+// (e.g. "*.npmjs.org"). Schemes, ports, paths and IP addresses are not allowed.
+func ValidateDomainPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty egress domain")
+	}
+	if strings.ContainsAny(pattern, "/:@ ") {
+		return fmt.Errorf("invalid egress domain %q: only host names are allowed (no scheme, port or path)", pattern)
+	}
+	if IsIPLiteral(pattern) {
+		return fmt.Errorf("invalid egress domain %q: IP addresses are not allowed, use a host name", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid egress domain %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// MatchDomain returns true if host matches any of patterns. Matching is case insensitive and
+// "*" matches any part of a name, so "*.example.com" matches "api.example.com" but not "example.com".
+func MatchDomain(patterns []string, host string) bool {
+	host = normalizeDomain(host)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(normalizeDomain(pattern), host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeDomain(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// cgnatRange is the carrier-grade NAT range (RFC 6598), used by VPNs and tailnets for internal hosts.
+var cgnatRange = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsEgressForbiddenIP returns true for addresses the sandbox must never reach through the egress proxy,
+// whatever the allowlist says: host loopback, private, CGNAT and ULA networks (the docker bridge, the host LAN,
+// VPNs; host services are reachable via the reverse proxy only), link-local (cloud metadata endpoints)
+// and non-unicast addresses. Addresses of the host interfaces are checked by the proxy itself.
+func IsEgressForbiddenIP(ip net.IP) bool {
+	if addr, ok := netip.AddrFromSlice(ip); ok && cgnatRange.Contains(addr.Unmap()) {
+		return true
+	}
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast()
+}
+
+// IsIPLiteral returns true if host is an IP address rather than a name. The egress proxy never lets the sandbox
+// reach raw addresses: a name has to be allowed or approved, an address could be the host or anything on its LAN.
+func IsIPLiteral(host string) bool {
+	_, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"))
+	return err == nil
+}
@@ -0,0 +1,27 @@
+// Tests in this file exercise egress allowlist entries.
+package guardrails
+
+import "testing"
+
+func TestEgressRefusesIPAddresses(t *testing.T) {
+	t.Parallel()
+
+	for _, host := range []string{"192.168.1.10", "10.0.0.1", "::1", "[fe80::1]", "fe80::1%eth0"} {
+		if !IsIPLiteral(host) {
+			t.Fatalf("expected %s to be an IP address", host)
+		}
+	}
+	for _, host := range []string{"github.com", "*.npmjs.org", "1.2.3.4.nip.io"} {
+		if IsIPLiteral(host) {
+			t.Fatalf("expected %s to be a host name", host)
+		}
+		if err := ValidateDomainPattern(host); err != nil {
+			t.Fatalf("unexpected error for %s: %v", host, err)
+		}
+	}
+	for _, pattern := range []string{"192.168.1.10", "::1", "https://github.com", "github.com:443"} {
+		if err := ValidateDomainPattern(pattern); err == nil {
+			t.Fatalf("expected %s to be rejected", pattern)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	"github.com/0xa1bed0/mkenv/internal/fswalk"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+)
+
+// fileID identifies a file across its hardlinks.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+func fileIDOf(info os.FileInfo) (fileID, uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, 0, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// ScanLinkEscapes walks the project at root and reports links that lead out of it into forbidden locations:
+// symlinks resolving into forbidden paths and hardlinks of files in forbidden folders of the user's home.
+// Unlike symlinks, hardlinks share the content with the forbidden file and are visible in the sandbox as is.
+// walker is shared with the secret scan, see Scanner.Scan.
+func ScanLinkEscapes(ctx context.Context, walker *fswalk.Walker, root string) ([]*SensitivityWarning, error) {
+	var mu sync.Mutex
+	warnings := []*SensitivityWarning{}
+	hardlinked := map[fileID][]string{}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// links can hide anywhere, nothing is skipped
+	err = walker.Walk(ctx, root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable paths
+		}
+
+		switch {
+		case d.Type()&os.ModeSymlink != 0:
+			target, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return nil // dangling symlinks lead nowhere
+			}
+			if isUnderResolvedPrefix(resolvedRoot, target) {
+				return nil
+			}
+			if rule, forbidden := forbiddenRuleFor(target); forbidden {
+				mu.Lock()
+				warnings = append(warnings, newSensitivityWarning(root, p, ScanRuleSymlink, 0, target,
+					fmt.Sprintf("symlink to %s, which is forbidden by %s", target, rule)))
+				mu.Unlock()
+			}
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if id, nlink, ok := fileIDOf(info); ok && nlink > 1 {
+				mu.Lock()
+				hardlinked[id] = append(hardlinked[id], p)
+				mu.Unlock()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ErrScanCanceled
+		}
+		return nil, err
+	}
+
+	if len(hardlinked) > 0 {
+		for id, target := range forbiddenHomeFiles(ctx, hardlinked) {
+			for _, p := range hardlinked[id] {
+				rule, _ := forbiddenRuleFor(target)
+				warnings = append(warnings, newSensitivityWarning(root, p, ScanRuleHardlink, 0, target,
+					fmt.Sprintf("hardlink of %s, which is forbidden by %s", target, rule)))
+			}
+		}
+	}
+
+	sortSensitivityWarnings(warnings)
+	return warnings, nil
+}
+
+// forbiddenHomeFiles returns files in forbidden locations of the user's home that are one of wanted.
+// System folders are not walked: hardlinks to files the user doesn't own are usually not allowed.
+func forbiddenHomeFiles(ctx context.Context, wanted map[fileID][]string) map[fileID]string {
+	home := mustHome()
+	skip := []string{filepath.Join(home, "Library"), hostappconfig.ConfigBasePath()}
+
+	found := map[fileID]string{}
+	for _, rule := range forbiddenRules {
+		if rule.Pattern || !isUnderResolvedPrefix(home, rule.Path) || rule.Path == home {
+			continue
+		}
+		_ = filepath.WalkDir(rule.Path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return nil // skip unreadable paths
+			}
+			if ctx.Err() != nil {
+				return ErrScanCanceled
+			}
+			for _, s := range skip {
+				if isUnderResolvedPrefix(s, p) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					// SkipDir on a file would skip the rest of its folder
+					return nil
+				}
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if id, _, ok := fileIDOf(info); ok && wanted[id] != nil {
+				logs.Debugf("%s is hardlinked into the project", p)
+				found[id] = p
+			}
+			return nil
+		})
+	}
+	return found
+}
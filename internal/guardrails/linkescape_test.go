@@ -0,0 +1,48 @@
+// Tests in this file exercise detection of links leading out of the project.
+package guardrails
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xa1bed0/mkenv/internal/fswalk"
+)
+
+func TestScanLinkEscapes(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Symlink("/etc", filepath.Join(root, "config")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := os.Symlink("main.go", filepath.Join(root, "entry.go")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	warnings, err := ScanLinkEscapes(context.Background(), fswalk.New(), root)
+	if err != nil {
+		t.Fatalf("ScanLinkEscapes: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Path != filepath.Join(root, "config") {
+		t.Fatalf("ScanLinkEscapes = %v, want only the symlink to /etc", warnings)
+	}
+}
+
+func TestIsUnderResolvedPrefix(t *testing.T) {
+	t.Parallel()
+
+	if !isUnderResolvedPrefix("/home/dev/.ssh", "/home/dev/.ssh/id_ed25519") || !isUnderResolvedPrefix("/home/dev/.ssh", "/home/dev/.ssh") {
+		t.Fatal("paths under the prefix must match")
+	}
+	if isUnderResolvedPrefix("/home/dev/.ssh", "/home/dev/.sshx") || isUnderResolvedPrefix("/home/dev/project", "/home/dev/..project") {
+		t.Fatal("sibling paths must not match")
+	}
+}
@@ -0,0 +1,121 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/0xa1bed0/mkenv/internal/logs"
+)
+
+// MaskedPath is a path inside the project the sandbox sees as an empty file or folder.
+type MaskedPath struct {
+	Rel   string // slash separated path relative to the project root
+	IsDir bool
+}
+
+// ValidateMaskPattern checks a masked_paths pattern. Patterns follow .gitignore rules:
+// a trailing "/" matches folders only, patterns with a "/" (other than a trailing one) are relative to the project root,
+// other patterns match the file or folder name at any depth. Patterns support * and ? wildcards.
+func ValidateMaskPattern(pattern string) error {
+	p := strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+	if p == "" {
+		return fmt.Errorf("masked path %q is empty", pattern)
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." || segment == "." || segment == "" {
+			return fmt.Errorf("masked path %q must stay inside the project", pattern)
+		}
+	}
+	if _, err := path.Match(p, ""); err != nil {
+		return fmt.Errorf("masked path %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// MatchMaskPattern returns true if the slash separated path rel (relative to the project root) matches the pattern.
+func MatchMaskPattern(pattern, rel string, isDir bool) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	p := strings.TrimSuffix(pattern, "/")
+	if dirOnly && !isDir {
+		return false
+	}
+
+	if strings.Contains(p, "/") {
+		matched, _ := path.Match(strings.TrimPrefix(p, "/"), rel)
+		return matched
+	}
+	matched, _ := path.Match(p, path.Base(rel))
+	return matched
+}
+
+// IsMaskedFile returns true if the file at rel (slash separated, relative to the project root) or one of its folders
+// matches any of patterns.
+func IsMaskedFile(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if MatchMaskPattern(pattern, rel, false) {
+			return true
+		}
+		for dir := path.Dir(rel); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if MatchMaskPattern(pattern, dir, true) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResolveMaskedPaths walks the project at root and returns files and folders matching any of patterns.
+// Contents of masked folders are not walked. Symlinks are never masked, they are resolved inside the sandbox.
+func ResolveMaskedPaths(ctx context.Context, root string, patterns []string) ([]MaskedPath, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	masked := []MaskedPath{}
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable paths
+		}
+		if ctx.Err() != nil {
+			return ErrScanCanceled
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, pattern := range patterns {
+			if !MatchMaskPattern(pattern, rel, d.IsDir()) {
+				continue
+			}
+			if d.Type()&os.ModeSymlink != 0 {
+				logs.Warnf("%s matches masked path %s but is a symlink, mask its target instead", rel, pattern)
+				return nil
+			}
+			if !d.IsDir() && !d.Type().IsRegular() {
+				logs.Warnf("%s matches masked path %s but is not a regular file, skipping", rel, pattern)
+				return nil
+			}
+			logs.Debugf("%s is masked by %s", rel, pattern)
+			masked = append(masked, MaskedPath{Rel: rel, IsDir: d.IsDir()})
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return masked, nil
+}
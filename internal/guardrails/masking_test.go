@@ -0,0 +1,79 @@
+// Tests in this file exercise masked_paths patterns.
+package guardrails
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchMaskPattern(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		pattern string
+		rel     string
+		isDir   bool
+		want    bool
+	}{
+		{".env*", ".env", false, true},
+		{".env*", "services/api/.env.local", false, true},
+		{".env*", "environment", false, false},
+		{"secrets/", "secrets", true, true},
+		{"secrets/", "secrets", false, false},
+		{"secrets/", "deploy/secrets", true, true},
+		{"/secrets/", "deploy/secrets", true, false},
+		{"config/*.pem", "config/server.pem", false, true},
+		{"config/*.pem", "app/config/server.pem", false, false},
+		{"/credentials.json", "credentials.json", false, true},
+	}
+	for _, c := range cases {
+		if got := MatchMaskPattern(c.pattern, c.rel, c.isDir); got != c.want {
+			t.Fatalf("MatchMaskPattern(%q, %q, %v) = %v, want %v", c.pattern, c.rel, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestValidateMaskPattern(t *testing.T) {
+	t.Parallel()
+
+	for _, pattern := range []string{".env*", "secrets/", "/config/*.pem"} {
+		if err := ValidateMaskPattern(pattern); err != nil {
+			t.Fatalf("ValidateMaskPattern(%q): %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"", "/", "../.ssh", "a/../../b", "[", "a//b"} {
+		if err := ValidateMaskPattern(pattern); err == nil {
+			t.Fatalf("ValidateMaskPattern(%q) must fail", pattern)
+		}
+	}
+}
+
+func TestResolveMaskedPaths(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	for _, f := range []string{".env", "app/.env.local", "app/main.go", "secrets/prod/key.pem"} {
+		p := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "app/main.go"), filepath.Join(root, ".env.link")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	masked, err := ResolveMaskedPaths(context.Background(), root, []string{".env*", "secrets/"})
+	if err != nil {
+		t.Fatalf("ResolveMaskedPaths: %v", err)
+	}
+	want := []MaskedPath{{Rel: ".env"}, {Rel: "app/.env.local"}, {Rel: "secrets", IsDir: true}}
+	if !slices.Equal(masked, want) {
+		t.Fatalf("ResolveMaskedPaths = %v, want %v", masked, want)
+	}
+}
@@ -111,6 +111,16 @@ func init() {
 	for _, r := range raw {
 		r.Path = filepath.Clean(r.Path)
 		forbiddenRules = append(forbiddenRules, r)
+
+		// paths are compared after resolving symlinks, so symlinked locations (e.g. ~/.ssh kept in a dotfiles repo)
+		// are forbidden where they really are too
+		if r.Pattern {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(r.Path); err == nil && resolved != r.Path {
+			r.Path = resolved
+			forbiddenRules = append(forbiddenRules, r)
+		}
 	}
 }
 
@@ -122,42 +132,52 @@ func mustHome() string {
 	return usr.HomeDir
 }
 
+// IsAbsolutelyForbidden returns true if the path, with all symlinks resolved, is a forbidden location
+// or can't be resolved.
 func IsAbsolutelyForbidden(rawPath string) bool {
 	if rawPath == "" {
 		rawPath = "."
 	}
 
-	p, err := utils.ResolveFolderStrict(rawPath)
+	p, err := utils.ResolvePathStrict(rawPath)
 	if err != nil {
 		logs.Errorf("[guardrails] can't resolve path %s. error:%v", rawPath, err)
 		return true
 	}
 
+	if rule, forbidden := forbiddenRuleFor(p); forbidden {
+		if p != filepath.Clean(rawPath) {
+			logs.Warnf("path %s (resolved to %s) is forbidden globally by %s", rawPath, p, rule)
+		} else {
+			logs.Warnf("path %s is forbidden globally by %s", p, rule)
+		}
+		return true
+	}
+
+	return false
+}
+
+// forbiddenRuleFor returns the forbidden rule the resolved path p falls under.
+func forbiddenRuleFor(p string) (string, bool) {
 	for _, rule := range forbiddenRules {
 		r := rule.Path
 
 		if rule.Exact && p == r {
-			logs.Warnf("path %s is forbidden globally path %s", p, r)
-			return true
+			return r, true
 		}
-		if rule.Prefix {
-			if IsUnderPrefix(r, p) {
-				logs.Warnf("path %s is under forbidden globally path %s", p, r)
-				return true
-			}
+		if rule.Prefix && isUnderResolvedPrefix(r, p) {
+			return r, true
 		}
 		if rule.Pattern {
 			if strings.HasSuffix(r, "*") {
 				prefix := strings.TrimSuffix(r, "*")
 				if strings.HasPrefix(p, prefix) {
-					logs.Warnf("path %s is forbidden globally path %s", p, prefix)
-					return true
+					return prefix, true
 				}
 			}
 		}
 	}
-
-	return false
+	return "", false
 }
 
 func IsUnderPrefix(base, path string) bool {
@@ -166,11 +186,13 @@ func IsUnderPrefix(base, path string) bool {
 	if err != nil {
 		return false
 	}
+	return isUnderResolvedPrefix(base, path)
+}
 
-	var rel string
-	rel, err = filepath.Rel(base, path)
+func isUnderResolvedPrefix(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
 	if err != nil {
 		return false
 	}
-	return rel == "." || !strings.HasPrefix(rel, "..")
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, "../"))
 }
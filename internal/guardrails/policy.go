@@ -1,78 +1,66 @@
 package guardrails
 
 import (
+	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
 	"github.com/0xa1bed0/mkenv/internal/bricksengine"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/state"
 )
 
+// policy is a single policy file. Unset lists (absent in the file) are nil, set but empty lists are not.
 type policy struct {
-	DisableBricks_      []bricksengine.BrickID                     `json:"disabled_bricks"`
-	EnableBricks_       []bricksengine.BrickID                     `json:"enabled_bricks"`
-	DisableAuto_        bool                                       `json:"disable_auto"`
-	BricksConfigs_      map[bricksengine.BrickID]map[string]string `json:"bricks_config"`
-	AllowedMounts_      []string                                   `json:"allowed_mount_paths"`  // if empty - allow all except forbidden globally
-	AllowedProjectRoot_ string                                     `json:"allowed_project_path"` // if empty - allow all except forbidden globally
-	IgnorePreferences_  bool                                       `json:"ignore_preferences"`
-	ReverseProxy_       *ReverseProxyPolicy                        `json:"reverse_proxy"`
+	DisableBricks_       []bricksengine.BrickID                     `json:"disabled_bricks"`
+	EnableBricks_        []bricksengine.BrickID                     `json:"enabled_bricks"`
+	DisableAuto_         bool                                       `json:"disable_auto"`
+	BricksConfigs_       map[bricksengine.BrickID]map[string]string `json:"bricks_config"`
+	AllowedMounts_       []string                                   `json:"allowed_mount_paths"`  // if empty - allow all except forbidden globally
+	AllowedProjectRoot_  string                                     `json:"allowed_project_path"` // if empty - allow all except forbidden globally
+	IgnorePreferences_   bool                                       `json:"ignore_preferences"`
+	ReverseProxy_        *ReverseProxyPolicy                        `json:"reverse_proxy"`
+	DeniedEnvHostVars_   []string                                   `json:"denied_env_passthrough"` // host env variables that can't be passed to the container. Supports * wildcards
+	GitCredentialHosts_  []string                                   `json:"git_credential_hosts"`   // hosts the sandbox can request git credentials for. if empty - none
+	Egress_              *EgressPolicy                              `json:"egress"`
+	AllowProjectVolumes_ *bool                                      `json:"allow_project_volumes"` // let .mkenv files inside the project request host volumes. if unset - no
+	SecretScan_          *ScanConfig                                `json:"secret_scan"`           // extra rules of the secret scanner
+	AllowedCustomBricks_ []string                                   `json:"allowed_custom_bricks"` // declarative and plugin bricks that can be used. Supports * wildcards. if unset - all
+	Sections_            map[string]*policy                         `json:"sections"`              // policies of projects under a path prefix, see ForProject
+	Serial_              int64                                      `json:"serial"`                // version of a signed policy, can't go back, see PolicySerials
 }
 
-// ReverseProxyPolicy controls which host ports can be accessed from the container
-type ReverseProxyPolicy struct {
-	CustomDeniedPorts  []int `json:"denied_ports"`  // Additional ports to deny beyond hardcoded list
-	CustomAllowedPorts []int `json:"allowed_ports"` // Ports to allow despite being in deny lists
-}
-
-// AllowedMounts implements Policy.
-func (p *policy) AllowedMounts() []string {
-	out := make([]string, len(p.AllowedMounts_))
-	for i, val := range p.AllowedMounts_ {
-		out[i] = val
-	}
-	return out
+// EgressPolicy controls outbound internet access of the sandbox
+type EgressPolicy struct {
+	Enforce        bool     `json:"enforce"`         // turn egress mode on for every project. Project allowlists are ignored then
+	AllowedDomains []string `json:"allowed_domains"` // domains every project can reach in egress mode. Supports * wildcards
 }
 
-// AllowedProjectRoot implements Policy.
-func (p *policy) AllowedProjectRoot() string {
-	return p.AllowedProjectRoot_
-}
-
-// BricksConfigs implements Policy.
-func (p *policy) BricksConfigs() map[bricksengine.BrickID]map[string]string {
-	out := make(map[bricksengine.BrickID]map[string]string, len(p.BricksConfigs_))
-
-	for brickId, config := range p.BricksConfigs_ {
-		out[brickId] = make(map[string]string, len(config))
-		for k, v := range config {
-			out[brickId][k] = v
-		}
-	}
-
-	return out
-}
-
-// DisableAuto implements Policy.
-func (p *policy) DisableAuto() bool {
-	return p.DisableAuto_
-}
-
-// DisableBricks implements Policy.
-func (p *policy) DisableBricks() []bricksengine.BrickID {
-	return bricksengine.CopyBrickIDs(p.DisableBricks_)
+// ReverseProxyPolicy controls which host ports can be accessed from the container
+type ReverseProxyPolicy struct {
+	CustomDeniedPorts  []int  `json:"denied_ports"`  // Additional ports to deny beyond hardcoded list
+	CustomAllowedPorts []int  `json:"allowed_ports"` // Ports to allow despite being in deny lists
+	Mode               string `json:"mode"`          // "allow" (default) or "ask": ports allowed by the lists above are asked on first use
+	Disabled           bool   `json:"disabled"`      // deny all host ports
 }
 
-// EnableBricks implements Policy.
-func (p *policy) EnableBricks() []bricksengine.BrickID {
-	return bricksengine.CopyBrickIDs(p.EnableBricks_)
-}
+const (
+	ReverseProxyModeAllow = "allow"
+	ReverseProxyModeAsk   = "ask"
+)
 
-// IgnorePreferences implements Policy.
-func (p *policy) IgnorePreferences() bool {
-	return p.IgnorePreferences_
+// BrickConfigEntry is a bricks_config value of a policy file.
+type BrickConfigEntry struct {
+	Brick  bricksengine.BrickID
+	Key    string
+	Value  string
+	Source string // "path:line", or the path if the line isn't known
 }
 
 type Policy interface {
@@ -80,55 +68,154 @@ type Policy interface {
 	EnableBricks() []bricksengine.BrickID
 	DisableAuto() bool
 	BricksConfigs() map[bricksengine.BrickID]map[string]string
-	AllowedMounts() []string
-	AllowedProjectRoot() string
+	// BricksConfigEntries returns the bricks_config values of every layer with the file and line they are set at.
+	BricksConfigEntries() []BrickConfigEntry
+	MountsRestricted() bool
+	AllowMount(path string) bool
+	AllowProjectPath(path string) bool
+	AllowedProjectRoots() []string
 	IgnorePreferences() bool
 	AllowReverseProxy(port int) bool
+	ReverseProxyAsk() bool
+	AllowEnvPassthrough(name string) bool
+	GitCredentialHosts() []string
+	AllowGitCredential(host string) bool
+	EgressEnforced() bool
+	EgressAllowedDomains() []string
+	AllowProjectVolumes() bool
+	// SecretScan returns the secret scanner configs of all layers, see NewScanner for how they combine.
+	SecretScan() []ScanConfig
+	// AllowCustomBrick returns true if the custom brick (declarative or mkenv-brick-* plugin) can be used.
+	AllowCustomBrick(id bricksengine.BrickID) bool
+	Explain() []PolicyRule
+	// ForProject returns the policy of the project at projectPath: in every layer the most specific section
+	// matching the path is applied on top of the layer's global settings.
+	ForProject(projectPath string) Policy
 }
 
-var defaultPolicy = policy{
-	DisableBricks_:      []bricksengine.BrickID{},
-	EnableBricks_:       []bricksengine.BrickID{},
-	DisableAuto_:        false,
-	BricksConfigs_:      map[bricksengine.BrickID]map[string]string{},
-	AllowedMounts_:      []string{},
-	AllowedProjectRoot_: "",
-	IgnorePreferences_:  false,
+// policySource is a policy file LoadPolicy reads.
+type policySource struct {
+	name   PolicyLayerName
+	path   string
+	signed bool
 }
 
-func ensurePolicyLocked(path string) error {
-	info, err := os.Stat(path)
+// LoadPolicy loads and merges the org, team and user policy layers. Missing layers are skipped, but once trusted keys
+// are installed the org and team layers are required: deleting a signed policy must not lift it.
+// Org and team layers must be signed with one of the trusted keys and their serial can't go back.
+func LoadPolicy() (Policy, error) {
+	sources := []policySource{
+		{PolicyLayerOrg, hostappconfig.OrgPolicyPath(), true},
+		{PolicyLayerTeam, hostappconfig.TeamPolicyPath(), true},
+		{PolicyLayerUser, hostappconfig.UserPolicyPath(), false},
+	}
+
+	keysPath := hostappconfig.PolicyTrustedKeysPath()
+	keys, err := loadTrustedKeys(keysPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // no policy → fine
-		}
-		return err
+		return nil, err
 	}
 
-	// Check permission bits only.
-	if info.Mode().Perm() != 0o444 {
-		return fmt.Errorf("policy file %s must have permissions 0444 (read-only), but has %04o", path, info.Mode().Perm())
+	var serials *PolicySerials
+	if len(keys) > 0 {
+		kvStore, err := state.DefaultKVStore(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("can't open the state store to check policy serials: %w", err)
+		}
+		serials = NewPolicySerials(kvStore)
 	}
-	return nil
+
+	return loadPolicy(context.Background(), sources, keysPath, keys, serials)
 }
 
-func LoadPolicy() (Policy, error) {
-	policyPath, _ := filepath.Abs(hostappconfig.ConfigBasePath() + "policy.json")
-	if err := ensurePolicyLocked(policyPath); err != nil {
-		return nil, err
+func loadPolicy(ctx context.Context, sources []policySource, keysPath string, keys []ed25519.PublicKey, serials *PolicySerials) (*layeredPolicy, error) {
+	lp := &layeredPolicy{}
+	for _, src := range sources {
+		layer, err := loadPolicyLayer(src.name, src.path, src.signed, keysPath, keys)
+		if err != nil {
+			return nil, err
+		}
+		if layer == nil {
+			if src.signed && len(keys) > 0 {
+				return nil, fmt.Errorf("%s policy %s is missing: signed policies are required when trusted keys are installed (%s)", src.name, src.path, keysPath)
+			}
+			continue
+		}
+		if src.signed {
+			if err := serials.Check(ctx, src.name, layer.p.Serial_); err != nil {
+				return nil, fmt.Errorf("%s policy %s: %w", src.name, src.path, err)
+			}
+		}
+		lp.layers = append(lp.layers, layer)
 	}
-	data, err := os.ReadFile(policyPath)
+	return lp, nil
+}
+
+// loadPolicyLayer reads the policy file at path. A missing file is not an error, nil is returned.
+// Signed layers are verified with keys.
+func loadPolicyLayer(name PolicyLayerName, path string, signed bool, keysPath string, keys []ed25519.PublicKey) (*policyLayer, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return &defaultPolicy, nil
+			return nil, nil
 		}
 		return nil, err
 	}
+
+	if signed {
+		if err := verifyPolicySignature(path, data, keysPath, keys); err != nil {
+			return nil, fmt.Errorf("%s policy %s: %w", name, path, err)
+		}
+	}
+
 	var p policy
 	if err := json.Unmarshal(data, &p); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s policy %s: %w", name, path, err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%s policy %s: %w", name, path, err)
+	}
+
+	logs.Debugf("loaded %s policy %s", name, path)
+	return &policyLayer{name: name, path: path, p: &p, data: data}, nil
+}
+
+func (p *policy) validate() error {
+	if p.ReverseProxy_ != nil {
+		switch p.ReverseProxy_.Mode {
+		case "", ReverseProxyModeAllow, ReverseProxyModeAsk:
+		default:
+			return fmt.Errorf("unknown reverse_proxy mode %q (expected allow or ask)", p.ReverseProxy_.Mode)
+		}
+	}
+	if p.SecretScan_ != nil {
+		if err := p.SecretScan_.Validate(); err != nil {
+			return fmt.Errorf("secret_scan: %w", err)
+		}
+	}
+	for _, pattern := range p.AllowedCustomBricks_ {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("allowed_custom_bricks: invalid pattern %q: %w", pattern, err)
+		}
+	}
+	for prefix, section := range p.Sections_ {
+		if !filepath.IsAbs(prefix) && prefix != "~" && !strings.HasPrefix(prefix, "~/") {
+			return fmt.Errorf("section %q: path prefix must be absolute or start with ~/", prefix)
+		}
+		if section == nil {
+			return fmt.Errorf("section %q is empty", prefix)
+		}
+		if section.Sections_ != nil {
+			return fmt.Errorf("section %q: sections can't be nested", prefix)
+		}
+		if section.Serial_ != 0 {
+			return fmt.Errorf("section %q: serial is only allowed at the top of the file", prefix)
+		}
+		if err := section.validate(); err != nil {
+			return fmt.Errorf("section %q: %w", prefix, err)
+		}
 	}
-	return &p, nil
+	return nil
 }
 
 // HardcodedDeniedPorts is a list of sensitive ports that are ALWAYS blocked
@@ -186,10 +273,9 @@ var HardcodedDeniedPorts = []int{
 	3269, // LDAP Global Catalog SSL
 }
 
-// AllowReverseProxy implements Policy.
-// Returns true if the port can be accessed via reverse proxy from the container.
+// allowReverseProxy returns true if the layer lets the container access the port via reverse proxy.
 // ALWAYS denies HardcodedDeniedPorts regardless of policy configuration.
-func (p *policy) AllowReverseProxy(port int) bool {
+func (p *policy) allowReverseProxy(port int) bool {
 	// CRITICAL: Hardcoded ports are ALWAYS denied, no exceptions
 	if contains(HardcodedDeniedPorts, port) {
 		return false
@@ -200,6 +286,10 @@ func (p *policy) AllowReverseProxy(port int) bool {
 		return true
 	}
 
+	if p.ReverseProxy_.Disabled {
+		return false
+	}
+
 	// Check custom allowed list (overrides custom denied)
 	if len(p.ReverseProxy_.CustomAllowedPorts) > 0 {
 		return contains(p.ReverseProxy_.CustomAllowedPorts, port)
@@ -214,6 +304,29 @@ func (p *policy) AllowReverseProxy(port int) bool {
 	return true
 }
 
+// allowEnvPassthrough returns true if the layer lets the host env variable be passed through to the container.
+func (p *policy) allowEnvPassthrough(name string) bool {
+	for _, pattern := range p.DeniedEnvHostVars_ {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+	return true
+}
+
+// allowCustomBrick returns true if the layer lets the custom brick be used.
+func (p *policy) allowCustomBrick(id bricksengine.BrickID) bool {
+	if p.AllowedCustomBricks_ == nil {
+		return true
+	}
+	for _, pattern := range p.AllowedCustomBricks_ {
+		if matched, _ := path.Match(pattern, string(id)); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // contains checks if a slice contains a specific integer
 func contains(slice []int, val int) bool {
 	for _, item := range slice {
@@ -0,0 +1,483 @@
+package guardrails
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/0xa1bed0/mkenv/internal/bricksengine"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/utils"
+)
+
+// PolicyLayerName is where a policy file comes from.
+type PolicyLayerName string
+
+const (
+	PolicyLayerOrg  PolicyLayerName = "org"
+	PolicyLayerTeam PolicyLayerName = "team"
+	PolicyLayerUser PolicyLayerName = "user"
+)
+
+type policyLayer struct {
+	name    PolicyLayerName
+	path    string
+	section string // path prefix of the section applied to p, if any
+	p       *policy
+	data    []byte // the policy file, for line numbers in errors
+}
+
+func (l *policyLayer) source() string {
+	if l.section != "" {
+		return fmt.Sprintf("%s (%s, section %s)", l.name, l.path, l.section)
+	}
+	return fmt.Sprintf("%s (%s)", l.name, l.path)
+}
+
+// layeredPolicy merges policy layers so that the stricter setting always wins:
+// denials add up, allowlists are intersected and any layer can turn a restriction on.
+// Layers are ordered from the strongest (org) to the weakest (user), the order only matters for bricks_config.
+type layeredPolicy struct {
+	layers []*policyLayer
+}
+
+// DisableBricks implements Policy.
+func (lp *layeredPolicy) DisableBricks() []bricksengine.BrickID {
+	out := []bricksengine.BrickID{}
+	for _, l := range lp.layers {
+		out = append(out, l.p.DisableBricks_...)
+	}
+	return bricksengine.UniqueSortedBricks(out)
+}
+
+// EnableBricks implements Policy. Bricks disabled by any layer are never enabled.
+func (lp *layeredPolicy) EnableBricks() []bricksengine.BrickID {
+	disabled := lp.DisableBricks()
+	out := []bricksengine.BrickID{}
+	for _, l := range lp.layers {
+		for _, brick := range l.p.EnableBricks_ {
+			if !slices.Contains(disabled, brick) {
+				out = append(out, brick)
+			}
+		}
+	}
+	return bricksengine.UniqueSortedBricks(out)
+}
+
+// DisableAuto implements Policy.
+func (lp *layeredPolicy) DisableAuto() bool {
+	for _, l := range lp.layers {
+		if l.p.DisableAuto_ {
+			return true
+		}
+	}
+	return false
+}
+
+// BricksConfigs implements Policy. A stronger layer's value wins for the same brick and key.
+func (lp *layeredPolicy) BricksConfigs() map[bricksengine.BrickID]map[string]string {
+	out := map[bricksengine.BrickID]map[string]string{}
+	for i := len(lp.layers) - 1; i >= 0; i-- {
+		for brickID, config := range lp.layers[i].p.BricksConfigs_ {
+			if out[brickID] == nil {
+				out[brickID] = map[string]string{}
+			}
+			for k, v := range config {
+				out[brickID][k] = v
+			}
+		}
+	}
+	return out
+}
+
+// BricksConfigEntries implements Policy. Entries of every layer are returned, including values a stronger layer overrides.
+func (lp *layeredPolicy) BricksConfigEntries() []BrickConfigEntry {
+	out := []BrickConfigEntry{}
+	for _, l := range lp.layers {
+		for _, brickID := range bricksengine.UniqueSortedBricks(slices.Collect(maps.Keys(l.p.BricksConfigs_))) {
+			config := l.p.BricksConfigs_[brickID]
+			for _, key := range utils.SortedKeys(config) {
+				out = append(out, BrickConfigEntry{Brick: brickID, Key: key, Value: config[key], Source: l.keySource(brickID, key)})
+			}
+		}
+	}
+	return out
+}
+
+// keySource returns "path:line" of the bricks_config key in the layer's file, the section's value if a section sets it.
+func (l *policyLayer) keySource(brickID bricksengine.BrickID, key string) string {
+	line := 0
+	if l.section != "" {
+		line = utils.JSONKeyLine(l.data, "sections", l.section, "bricks_config", string(brickID), key)
+	}
+	if line == 0 {
+		line = utils.JSONKeyLine(l.data, "bricks_config", string(brickID), key)
+	}
+	if line == 0 {
+		return l.path
+	}
+	return fmt.Sprintf("%s:%d", l.path, line)
+}
+
+// MountsRestricted implements Policy.
+// Returns true if any layer restricts mounts with allowed_mount_paths.
+func (lp *layeredPolicy) MountsRestricted() bool {
+	for _, l := range lp.layers {
+		if l.p.AllowedMounts_ != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowMount implements Policy.
+// Returns true if every layer with allowed_mount_paths has one the path is under.
+func (lp *layeredPolicy) AllowMount(path string) bool {
+	for _, l := range lp.layers {
+		if l.p.AllowedMounts_ == nil {
+			continue
+		}
+		if !slices.ContainsFunc(l.p.AllowedMounts_, func(prefix string) bool { return IsUnderPrefix(prefix, path) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowProjectPath implements Policy.
+// Returns true if the path is under allowed_project_path of every layer that has one.
+func (lp *layeredPolicy) AllowProjectPath(path string) bool {
+	for _, root := range lp.AllowedProjectRoots() {
+		if !IsUnderPrefix(root, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowedProjectRoots implements Policy.
+func (lp *layeredPolicy) AllowedProjectRoots() []string {
+	out := []string{}
+	for _, l := range lp.layers {
+		if l.p.AllowedProjectRoot_ != "" {
+			out = append(out, l.p.AllowedProjectRoot_)
+		}
+	}
+	return out
+}
+
+// IgnorePreferences implements Policy.
+func (lp *layeredPolicy) IgnorePreferences() bool {
+	for _, l := range lp.layers {
+		if l.p.IgnorePreferences_ {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowReverseProxy implements Policy.
+// Returns true if the port can be accessed via reverse proxy from the container according to every layer.
+// ALWAYS denies HardcodedDeniedPorts regardless of policy configuration.
+func (lp *layeredPolicy) AllowReverseProxy(port int) bool {
+	if contains(HardcodedDeniedPorts, port) {
+		return false
+	}
+	for _, l := range lp.layers {
+		if !l.p.allowReverseProxy(port) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReverseProxyAsk implements Policy.
+// Returns true if the user must approve a host port allowed by AllowReverseProxy the first time the sandbox dials it.
+func (lp *layeredPolicy) ReverseProxyAsk() bool {
+	for _, l := range lp.layers {
+		if l.p.ReverseProxy_ != nil && l.p.ReverseProxy_.Mode == ReverseProxyModeAsk {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowEnvPassthrough implements Policy.
+// Returns true if the host env variable can be passed through to the container.
+func (lp *layeredPolicy) AllowEnvPassthrough(name string) bool {
+	for _, l := range lp.layers {
+		if !l.p.allowEnvPassthrough(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// GitCredentialHosts implements Policy. Only hosts allowed by every layer with git_credential_hosts are returned.
+func (lp *layeredPolicy) GitCredentialHosts() []string {
+	return intersectLists(lp.layers,
+		func(p *policy) []string { return p.GitCredentialHosts_ },
+		func(list []string, host string) bool {
+			return slices.ContainsFunc(list, func(h string) bool { return strings.EqualFold(h, host) })
+		})
+}
+
+// AllowGitCredential implements Policy.
+// Returns true if the sandbox can request host git credentials for the host.
+func (lp *layeredPolicy) AllowGitCredential(host string) bool {
+	return slices.ContainsFunc(lp.GitCredentialHosts(), func(h string) bool { return strings.EqualFold(h, host) })
+}
+
+// EgressEnforced implements Policy.
+// Returns true if every project must run in egress mode.
+func (lp *layeredPolicy) EgressEnforced() bool {
+	for _, l := range lp.layers {
+		if l.p.Egress_ != nil && l.p.Egress_.Enforce {
+			return true
+		}
+	}
+	return false
+}
+
+// EgressAllowedDomains implements Policy. Only domains allowed by every layer with allowed_domains are returned.
+func (lp *layeredPolicy) EgressAllowedDomains() []string {
+	return intersectLists(lp.layers,
+		func(p *policy) []string {
+			if p.Egress_ == nil {
+				return nil
+			}
+			return p.Egress_.AllowedDomains
+		},
+		MatchDomain)
+}
+
+// ForProject implements Policy.
+func (lp *layeredPolicy) ForProject(projectPath string) Policy {
+	out := &layeredPolicy{}
+	for _, l := range lp.layers {
+		prefix, section := l.p.sectionFor(projectPath)
+		if section == nil {
+			out.layers = append(out.layers, l)
+			continue
+		}
+		logs.Debugf("%s policy section %s applies to %s", l.name, prefix, projectPath)
+		out.layers = append(out.layers, &policyLayer{name: l.name, path: l.path, section: prefix, p: l.p.withSection(section), data: l.data})
+	}
+	return out
+}
+
+// AllowProjectVolumes implements Policy.
+// Returns true if a layer allows project .mkenv files to request host volumes and no layer forbids it.
+func (lp *layeredPolicy) AllowProjectVolumes() bool {
+	allowed := false
+	for _, l := range lp.layers {
+		if l.p.AllowProjectVolumes_ == nil {
+			continue
+		}
+		if !*l.p.AllowProjectVolumes_ {
+			return false
+		}
+		allowed = true
+	}
+	return allowed
+}
+
+// SecretScan implements Policy.
+// Rules of all layers add up, so a layer can't remove rules of another one.
+func (lp *layeredPolicy) SecretScan() []ScanConfig {
+	out := []ScanConfig{}
+	for _, l := range lp.layers {
+		if l.p.SecretScan_ != nil {
+			out = append(out, *l.p.SecretScan_)
+		}
+	}
+	return out
+}
+
+// AllowCustomBrick implements Policy.
+// Returns true if every layer with allowed_custom_bricks lists the brick.
+func (lp *layeredPolicy) AllowCustomBrick(id bricksengine.BrickID) bool {
+	for _, l := range lp.layers {
+		if !l.p.allowCustomBrick(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// intersectLists returns entries of the lists picked from layers that match every other non-nil list.
+func intersectLists(layers []*policyLayer, pick func(p *policy) []string, match func(list []string, entry string) bool) []string {
+	out := []string{}
+	for i, l := range layers {
+		for _, entry := range pick(l.p) {
+			allowed := true
+			for j, other := range layers {
+				list := pick(other.p)
+				if i == j || list == nil {
+					continue
+				}
+				if !match(list, entry) {
+					allowed = false
+					break
+				}
+			}
+			if allowed && !slices.Contains(out, entry) {
+				out = append(out, entry)
+			}
+		}
+	}
+	return out
+}
+
+// PolicyRule is an effective policy setting and the layers it comes from.
+type PolicyRule struct {
+	Field   string   `json:"field"`
+	Value   any      `json:"value"`
+	Sources []string `json:"sources"`
+}
+
+// Explain implements Policy. It lists effective settings, unset ones are omitted.
+func (lp *layeredPolicy) Explain() []PolicyRule {
+	rules := []PolicyRule{}
+	add := func(field string, value any, set func(p *policy) bool) {
+		sources := []string{}
+		for _, l := range lp.layers {
+			if set(l.p) {
+				sources = append(sources, l.source())
+			}
+		}
+		if len(sources) > 0 {
+			rules = append(rules, PolicyRule{Field: field, Value: value, Sources: sources})
+		}
+	}
+
+	add("disabled_bricks", lp.DisableBricks(), func(p *policy) bool { return len(p.DisableBricks_) > 0 })
+	add("enabled_bricks", lp.EnableBricks(), func(p *policy) bool { return len(p.EnableBricks_) > 0 })
+	add("disable_auto", true, func(p *policy) bool { return p.DisableAuto_ })
+	add("bricks_config", lp.BricksConfigs(), func(p *policy) bool { return len(p.BricksConfigs_) > 0 })
+	add("allowed_mount_paths", lp.allowedMounts(), func(p *policy) bool { return p.AllowedMounts_ != nil })
+	add("allowed_project_path", lp.AllowedProjectRoots(), func(p *policy) bool { return p.AllowedProjectRoot_ != "" })
+	add("ignore_preferences", true, func(p *policy) bool { return p.IgnorePreferences_ })
+	rules = append(rules, PolicyRule{Field: "reverse_proxy.hardcoded_denied_ports", Value: HardcodedDeniedPorts, Sources: []string{"mkenv"}})
+	add("reverse_proxy.denied_ports", lp.reverseProxyDeniedPorts(), func(p *policy) bool {
+		return p.ReverseProxy_ != nil && len(p.ReverseProxy_.CustomDeniedPorts) > 0
+	})
+	add("reverse_proxy.allowed_ports", lp.reverseProxyAllowedPorts(), func(p *policy) bool {
+		return p.ReverseProxy_ != nil && len(p.ReverseProxy_.CustomAllowedPorts) > 0
+	})
+	add("reverse_proxy.disabled", true, func(p *policy) bool { return p.ReverseProxy_ != nil && p.ReverseProxy_.Disabled })
+	add("reverse_proxy.mode", ReverseProxyModeAsk, func(p *policy) bool {
+		return p.ReverseProxy_ != nil && p.ReverseProxy_.Mode == ReverseProxyModeAsk
+	})
+	add("denied_env_passthrough", lp.deniedEnvHostVars(), func(p *policy) bool { return len(p.DeniedEnvHostVars_) > 0 })
+	add("git_credential_hosts", lp.GitCredentialHosts(), func(p *policy) bool { return p.GitCredentialHosts_ != nil })
+	add("egress.enforce", true, func(p *policy) bool { return p.Egress_ != nil && p.Egress_.Enforce })
+	add("egress.allowed_domains", lp.EgressAllowedDomains(), func(p *policy) bool {
+		return p.Egress_ != nil && p.Egress_.AllowedDomains != nil
+	})
+	add("allow_project_volumes", lp.AllowProjectVolumes(), func(p *policy) bool { return p.AllowProjectVolumes_ != nil })
+	add("secret_scan", lp.SecretScan(), func(p *policy) bool { return p.SecretScan_ != nil })
+	add("allowed_custom_bricks", lp.allowedCustomBricks(), func(p *policy) bool { return p.AllowedCustomBricks_ != nil })
+	add("sections", lp.sectionPrefixes(), func(p *policy) bool { return len(p.Sections_) > 0 })
+
+	return rules
+}
+
+// allowedMounts returns the paths AllowMount allows: the deepest of nested prefixes of different layers.
+// An empty result with restricting layers means no extra mounts are allowed.
+func (lp *layeredPolicy) allowedMounts() []string {
+	var out []string
+	for _, l := range lp.layers {
+		if l.p.AllowedMounts_ == nil {
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(l.p.AllowedMounts_)
+			continue
+		}
+		next := []string{}
+		for _, a := range out {
+			for _, b := range l.p.AllowedMounts_ {
+				switch {
+				case IsUnderPrefix(b, a):
+					next = append(next, a)
+				case IsUnderPrefix(a, b):
+					next = append(next, b)
+				}
+			}
+		}
+		out = next
+	}
+	return out
+}
+
+func (lp *layeredPolicy) reverseProxyDeniedPorts() []int {
+	out := []int{}
+	for _, l := range lp.layers {
+		if l.p.ReverseProxy_ == nil {
+			continue
+		}
+		for _, port := range l.p.ReverseProxy_.CustomDeniedPorts {
+			if !contains(out, port) {
+				out = append(out, port)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func (lp *layeredPolicy) reverseProxyAllowedPorts() []int {
+	out := []int{}
+	for _, l := range lp.layers {
+		if l.p.ReverseProxy_ == nil {
+			continue
+		}
+		for _, port := range l.p.ReverseProxy_.CustomAllowedPorts {
+			if lp.AllowReverseProxy(port) && !contains(out, port) {
+				out = append(out, port)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func (lp *layeredPolicy) sectionPrefixes() []string {
+	out := []string{}
+	for _, l := range lp.layers {
+		for prefix := range l.p.Sections_ {
+			if !slices.Contains(out, prefix) {
+				out = append(out, prefix)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// allowedCustomBricks returns the allowed_custom_bricks patterns of all layers, a brick must match one of every layer.
+func (lp *layeredPolicy) allowedCustomBricks() []string {
+	out := []string{}
+	for _, l := range lp.layers {
+		for _, pattern := range l.p.AllowedCustomBricks_ {
+			if !slices.Contains(out, pattern) {
+				out = append(out, pattern)
+			}
+		}
+	}
+	return out
+}
+
+func (lp *layeredPolicy) deniedEnvHostVars() []string {
+	out := []string{}
+	for _, l := range lp.layers {
+		for _, pattern := range l.p.DeniedEnvHostVars_ {
+			if !slices.Contains(out, pattern) {
+				out = append(out, pattern)
+			}
+		}
+	}
+	return out
+}
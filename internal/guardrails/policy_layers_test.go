@@ -0,0 +1,182 @@
+// Tests in this file exercise merging of policy layers.
+package guardrails
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// mustLayers builds org, team and user layers from policy files, ROOT in files is replaced with root.
+func mustLayers(t *testing.T, root string, files ...string) *layeredPolicy {
+	t.Helper()
+
+	names := []PolicyLayerName{PolicyLayerOrg, PolicyLayerTeam, PolicyLayerUser}
+	lp := &layeredPolicy{}
+	for i, file := range files {
+		data := []byte(strings.ReplaceAll(file, "ROOT", root))
+		var p policy
+		if err := json.Unmarshal(data, &p); err != nil {
+			t.Fatalf("bad policy %d: %v", i, err)
+		}
+		lp.layers = append(lp.layers, &policyLayer{name: names[i], path: string(names[i]) + ".json", p: &p, data: data})
+	}
+	return lp
+}
+
+func TestLayeredPolicyStricterWins(t *testing.T) {
+	t.Parallel()
+
+	// AllowMount resolves paths, so they must exist.
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks: %v", err)
+	}
+	for _, dir := range []string{"home/dev/data/db", "home/dev/other", "srv/x"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+	}
+	path := func(p string) string { return filepath.Join(root, p) }
+
+	lp := mustLayers(t, root,
+		`{"disabled_bricks": ["codex"], "reverse_proxy": {"denied_ports": [5432]}, "git_credential_hosts": ["github.com", "gitlab.com"]}`,
+		`{"allowed_mount_paths": ["ROOT/home/dev"], "egress": {"allowed_domains": ["*.npmjs.org", "pypi.org"]}}`,
+		`{"enabled_bricks": ["codex", "nvim"], "allowed_mount_paths": ["ROOT/home/dev/data", "ROOT/srv"], "reverse_proxy": {"mode": "ask"}, "git_credential_hosts": ["github.com"], "egress": {"allowed_domains": ["registry.npmjs.org", "example.com"]}}`,
+	)
+
+	if got := lp.EnableBricks(); len(got) != 1 || got[0] != "nvim" {
+		t.Fatalf("EnableBricks = %v, want [nvim]", got)
+	}
+	if lp.AllowReverseProxy(5432) {
+		t.Fatal("port denied by org must stay denied")
+	}
+	if !lp.AllowReverseProxy(6379) || !lp.ReverseProxyAsk() {
+		t.Fatal("port 6379 must be allowed in ask mode")
+	}
+	if !lp.AllowMount(path("home/dev/data/db")) {
+		t.Fatal("mount allowed by team and user must be allowed")
+	}
+	if lp.AllowMount(path("home/dev/other")) || lp.AllowMount(path("srv/x")) {
+		t.Fatal("mount allowed by one layer only must be denied")
+	}
+	if got := lp.allowedMounts(); !slices.Equal(got, []string{path("home/dev/data")}) {
+		t.Fatalf("allowedMounts = %v, want [/home/dev/data]", got)
+	}
+	if got := lp.GitCredentialHosts(); !slices.Equal(got, []string{"github.com"}) {
+		t.Fatalf("GitCredentialHosts = %v, want [github.com]", got)
+	}
+	if got := lp.EgressAllowedDomains(); !slices.Equal(got, []string{"registry.npmjs.org"}) {
+		t.Fatalf("EgressAllowedDomains = %v, want [registry.npmjs.org]", got)
+	}
+}
+
+func TestLayeredPolicyWithoutLayers(t *testing.T) {
+	t.Parallel()
+
+	lp := mustLayers(t, "")
+	if lp.MountsRestricted() || !lp.AllowProjectPath("/home/dev/project") || !lp.AllowReverseProxy(5432) {
+		t.Fatal("no policy must not restrict anything")
+	}
+	if lp.AllowReverseProxy(22) {
+		t.Fatal("hardcoded ports must always be denied")
+	}
+	if len(lp.GitCredentialHosts()) != 0 {
+		t.Fatal("git credentials must not be forwarded without policy")
+	}
+}
+
+func TestLayeredPolicyExplain(t *testing.T) {
+	t.Parallel()
+
+	lp := mustLayers(t, "", `{"disable_auto": true}`, `{}`, `{"disable_auto": true, "denied_env_passthrough": ["AWS_*"]}`)
+
+	rules := map[string]PolicyRule{}
+	for _, rule := range lp.Explain() {
+		rules[rule.Field] = rule
+	}
+	if got := rules["disable_auto"].Sources; !slices.Equal(got, []string{"org (org.json)", "user (user.json)"}) {
+		t.Fatalf("disable_auto sources = %v", got)
+	}
+	if _, ok := rules["egress.enforce"]; ok {
+		t.Fatal("unset rules must not be explained")
+	}
+}
+
+func TestLayeredPolicyForProject(t *testing.T) {
+	t.Parallel()
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks: %v", err)
+	}
+	for _, dir := range []string{"work/app", "oss/lib", "oss/vendored/pkg", "misc"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+	}
+	path := func(p string) string { return filepath.Join(root, p) }
+
+	lp := mustLayers(t, root,
+		`{"reverse_proxy": {"denied_ports": [5432]}, "sections": {
+			"ROOT/oss": {"reverse_proxy": {"disabled": true}, "egress": {"enforce": true, "allowed_domains": ["github.com"]}},
+			"ROOT/oss/vendored": {"disable_auto": true}
+		}}`,
+		`{}`,
+		`{"allowed_mount_paths": [], "sections": {"ROOT/work": {"allowed_mount_paths": ["ROOT/work"]}}}`,
+	)
+
+	oss := lp.ForProject(path("oss/lib"))
+	if oss.AllowReverseProxy(6379) || !oss.EgressEnforced() {
+		t.Fatal("oss section must disable the reverse proxy and enforce egress")
+	}
+	vendored := lp.ForProject(path("oss/vendored/pkg"))
+	if !vendored.DisableAuto() || vendored.EgressEnforced() || !vendored.AllowReverseProxy(6379) || vendored.AllowReverseProxy(5432) {
+		t.Fatal("only the most specific section must be applied on top of the layer")
+	}
+
+	work := lp.ForProject(path("work/app"))
+	if !work.AllowMount(path("work/app")) || work.AllowMount(path("misc")) {
+		t.Fatal("work section must allow mounts under work only")
+	}
+	misc := lp.ForProject(path("misc"))
+	if misc.AllowMount(path("work/app")) || !misc.AllowReverseProxy(6379) {
+		t.Fatal("projects outside sections must get the global settings")
+	}
+}
+
+func TestLayeredPolicyBricksConfigEntries(t *testing.T) {
+	t.Parallel()
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "work"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	lp := mustLayers(t, root,
+		`{
+  "bricks_config": {"nodejs": {"version": "20"}},
+  "sections": {
+    "ROOT/work": {
+      "bricks_config": {"nodejs": {"verison": "22"}}
+    }
+  }
+}`,
+	)
+
+	got := []string{}
+	for _, e := range lp.ForProject(filepath.Join(root, "work")).BricksConfigEntries() {
+		got = append(got, fmt.Sprintf("%s %s.%s=%s", e.Source, e.Brick, e.Key, e.Value))
+	}
+	want := []string{"org.json:5 nodejs.verison=22", "org.json:2 nodejs.version=20"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected entries %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,101 @@
+package guardrails
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/0xa1bed0/mkenv/internal/bricksengine"
+	"github.com/0xa1bed0/mkenv/internal/utils"
+)
+
+// sectionFor returns the section of the policy that applies to the project path:
+// the one with the longest path prefix the project is under. Returns nil if no section matches.
+func (p *policy) sectionFor(projectPath string) (string, *policy) {
+	bestPrefix, bestLen := "", -1
+	var best *policy
+	for prefix, section := range p.Sections_ {
+		base := resolveSectionPrefix(prefix)
+		if base == "" || !IsUnderPrefix(base, projectPath) {
+			continue
+		}
+		if len(base) > bestLen {
+			bestPrefix, bestLen, best = prefix, len(base), section
+		}
+	}
+	return bestPrefix, best
+}
+
+// resolveSectionPrefix turns the section prefix into an absolute path comparable with resolved project paths.
+// Prefixes that don't exist (yet) are only cleaned. Returns "" if the home folder can't be resolved.
+func resolveSectionPrefix(prefix string) string {
+	if resolved, err := utils.ResolvePathStrict(prefix); err == nil {
+		return resolved
+	}
+	if prefix == "~" || strings.HasPrefix(prefix, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		prefix = filepath.Join(home, strings.TrimPrefix(prefix, "~"))
+	}
+	return filepath.Clean(prefix)
+}
+
+// withSection returns a copy of the policy with the section applied:
+// settings present in the section replace the policy's ones, bricks_config is merged key by key
+// and booleans can only be turned on.
+func (p *policy) withSection(section *policy) *policy {
+	out := *p
+	out.Sections_ = nil
+
+	if section.DisableBricks_ != nil {
+		out.DisableBricks_ = section.DisableBricks_
+	}
+	if section.EnableBricks_ != nil {
+		out.EnableBricks_ = section.EnableBricks_
+	}
+	out.DisableAuto_ = p.DisableAuto_ || section.DisableAuto_
+	if section.BricksConfigs_ != nil {
+		out.BricksConfigs_ = map[bricksengine.BrickID]map[string]string{}
+		for _, configs := range []map[bricksengine.BrickID]map[string]string{p.BricksConfigs_, section.BricksConfigs_} {
+			for brickID, config := range configs {
+				if out.BricksConfigs_[brickID] == nil {
+					out.BricksConfigs_[brickID] = map[string]string{}
+				}
+				maps.Copy(out.BricksConfigs_[brickID], config)
+			}
+		}
+	}
+	if section.AllowedMounts_ != nil {
+		out.AllowedMounts_ = section.AllowedMounts_
+	}
+	if section.AllowedProjectRoot_ != "" {
+		out.AllowedProjectRoot_ = section.AllowedProjectRoot_
+	}
+	out.IgnorePreferences_ = p.IgnorePreferences_ || section.IgnorePreferences_
+	if section.ReverseProxy_ != nil {
+		out.ReverseProxy_ = section.ReverseProxy_
+	}
+	if section.DeniedEnvHostVars_ != nil {
+		out.DeniedEnvHostVars_ = section.DeniedEnvHostVars_
+	}
+	if section.GitCredentialHosts_ != nil {
+		out.GitCredentialHosts_ = section.GitCredentialHosts_
+	}
+	if section.Egress_ != nil {
+		out.Egress_ = section.Egress_
+	}
+	if section.AllowProjectVolumes_ != nil {
+		out.AllowProjectVolumes_ = section.AllowProjectVolumes_
+	}
+	if section.SecretScan_ != nil {
+		out.SecretScan_ = section.SecretScan_
+	}
+	if section.AllowedCustomBricks_ != nil {
+		out.AllowedCustomBricks_ = section.AllowedCustomBricks_
+	}
+
+	return &out
+}
@@ -0,0 +1,70 @@
+package guardrails
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// policySignatureSuffix is appended to the policy path to get its detached signature:
+// the base64 encoded ed25519 signature of the exact policy file bytes.
+const policySignatureSuffix = ".sig"
+
+// verifyPolicySignature checks that data (the content of the policy file at path) is signed
+// with one of keys, the trusted keys read from keysPath.
+func verifyPolicySignature(path string, data []byte, keysPath string, keys []ed25519.PublicKey) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("no trusted keys to verify the signature (%s is missing or empty)", keysPath)
+	}
+
+	sigData, err := os.ReadFile(path + policySignatureSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("signature %s is missing", path+policySignatureSuffix)
+		}
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("signature %s is not a base64 encoded ed25519 signature", path+policySignatureSuffix)
+	}
+
+	for _, key := range keys {
+		if ed25519.Verify(key, data, sig) {
+			return nil
+		}
+	}
+	return errors.New("signature does not match any trusted key")
+}
+
+// loadTrustedKeys reads base64 encoded ed25519 public keys, one per line. Empty lines and # comments are skipped.
+// A missing file has no keys.
+func loadTrustedKeys(path string) ([]ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	keys := []ed25519.PublicKey{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.Fields(line)[0])
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s:%d: not a base64 encoded ed25519 public key", path, lineNo)
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return keys, scanner.Err()
+}
@@ -0,0 +1,118 @@
+// Tests in this file exercise loading signed org and team policies.
+package guardrails
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type signedPolicyFixture struct {
+	dir     string
+	priv    ed25519.PrivateKey
+	keys    []ed25519.PublicKey
+	sources []policySource
+	serials *PolicySerials
+}
+
+func newSignedPolicyFixture(t *testing.T) *signedPolicyFixture {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	dir := t.TempDir()
+
+	return &signedPolicyFixture{
+		dir:  dir,
+		priv: priv,
+		keys: []ed25519.PublicKey{pub},
+		sources: []policySource{
+			{PolicyLayerOrg, filepath.Join(dir, "policy.json"), true},
+			{PolicyLayerTeam, filepath.Join(dir, "team-policy.json"), true},
+			{PolicyLayerUser, filepath.Join(dir, "user-policy.json"), false},
+		},
+		serials: NewPolicySerials(newTestKVStore(t)),
+	}
+}
+
+// write writes a policy file of the i-th source and signs it.
+func (f *signedPolicyFixture) write(t *testing.T, i int, content string) {
+	t.Helper()
+	path := f.sources[i].path
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(f.priv, []byte(content)))
+	if err := os.WriteFile(path+policySignatureSuffix, []byte(sig+"\n"), 0o644); err != nil {
+		t.Fatalf("write signature: %v", err)
+	}
+}
+
+func (f *signedPolicyFixture) load(keys []ed25519.PublicKey) (*layeredPolicy, error) {
+	return loadPolicy(context.Background(), f.sources, filepath.Join(f.dir, "trusted-keys"), keys, f.serials)
+}
+
+func TestLoadPolicyRequiresSignedLayers(t *testing.T) {
+	t.Parallel()
+
+	f := newSignedPolicyFixture(t)
+	f.write(t, 0, `{"serial": 1, "disabled_bricks": ["codex"]}`)
+
+	if _, err := f.load(f.keys); err == nil || !strings.Contains(err.Error(), "team policy") || !strings.Contains(err.Error(), "is missing") {
+		t.Fatalf("expected a missing team policy to fail, got %v", err)
+	}
+
+	f.write(t, 1, `{"serial": 1}`)
+	lp, err := f.load(f.keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lp.layers) != 2 {
+		t.Fatalf("expected org and team layers, got %d", len(lp.layers))
+	}
+
+	// a policy modified without the key
+	if err := os.WriteFile(f.sources[1].path, []byte(`{"serial": 2, "ignore_preferences": true}`), 0o644); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	if _, err := f.load(f.keys); err == nil || !strings.Contains(err.Error(), "signature does not match") {
+		t.Fatalf("expected a modified team policy to fail, got %v", err)
+	}
+
+	// without trusted keys signed layers are optional, but present ones still can't be verified
+	if _, err := f.load(nil); err == nil || !strings.Contains(err.Error(), "no trusted keys") {
+		t.Fatalf("expected signed policies without trusted keys to fail, got %v", err)
+	}
+}
+
+func TestLoadPolicyRefusesRolledBackSerial(t *testing.T) {
+	t.Parallel()
+
+	f := newSignedPolicyFixture(t)
+	f.write(t, 0, `{"serial": 3}`)
+	f.write(t, 1, `{"serial": 7}`)
+	if _, err := f.load(f.keys); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f.write(t, 1, `{"serial": 6, "disabled_bricks": []}`)
+	if _, err := f.load(f.keys); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected an older team policy to fail, got %v", err)
+	}
+
+	f.write(t, 1, `{"serial": 8}`)
+	if _, err := f.load(f.keys); err != nil {
+		t.Fatalf("expected a newer team policy to load, got %v", err)
+	}
+	f.write(t, 1, `{"serial": 7}`)
+	if _, err := f.load(f.keys); err == nil {
+		t.Fatalf("expected serial 7 to fail once serial 8 was seen")
+	}
+}
@@ -0,0 +1,48 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/0xa1bed0/mkenv/internal/state"
+)
+
+// PolicySerials remembers the newest serial of every signed policy layer, so an older policy that is still
+// validly signed can't be put back. Serials live in the state KV store.
+type PolicySerials struct {
+	kvStore *state.KVStore
+}
+
+func NewPolicySerials(kvStore *state.KVStore) *PolicySerials {
+	return &PolicySerials{kvStore: kvStore}
+}
+
+func (ps *PolicySerials) deriveKey(layer PolicyLayerName) state.KVStoreKey {
+	return state.KVStoreKey("policy-serial:" + string(layer))
+}
+
+// Check returns an error if serial is older than the newest serial seen for layer, and remembers it otherwise.
+func (ps *PolicySerials) Check(ctx context.Context, layer PolicyLayerName, serial int64) error {
+	if ps == nil || ps.kvStore == nil {
+		return nil
+	}
+	key := ps.deriveKey(layer)
+	entry, found, err := ps.kvStore.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if found {
+		seen, err := strconv.ParseInt(entry.Value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad policy serial %q in the state store", entry.Value)
+		}
+		if serial < seen {
+			return fmt.Errorf("serial %d is older than serial %d seen before, refusing a rolled back policy", serial, seen)
+		}
+		if serial == seen {
+			return nil
+		}
+	}
+	return ps.kvStore.Upsert(ctx, key, strconv.FormatInt(serial, 10))
+}
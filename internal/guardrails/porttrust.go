@@ -0,0 +1,97 @@
+package guardrails
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/state"
+)
+
+// PortDecision is a remembered answer to "can the sandbox reach this host port?".
+type PortDecision string
+
+const (
+	PortAllow PortDecision = "allow"
+	PortDeny  PortDecision = "deny"
+)
+
+// PortTrustEntry is a remembered decision for a host port of a project.
+type PortTrustEntry struct {
+	Port      int
+	Decision  PortDecision
+	CreatedAt time.Time
+}
+
+// PortTrust remembers which host ports the sandbox of a project can reach through the reverse proxy
+// when the reverse proxy policy is in "ask" mode. Decisions live in the state KV store.
+type PortTrust struct {
+	kvStore     *state.KVStore
+	projectName string
+}
+
+func NewPortTrust(kvStore *state.KVStore, projectName string) *PortTrust {
+	return &PortTrust{kvStore: kvStore, projectName: projectName}
+}
+
+func (pt *PortTrust) prefix() state.KVStoreKey {
+	return state.KVStoreKey("port-trust:" + pt.projectName + ":")
+}
+
+func (pt *PortTrust) deriveKey(port int) state.KVStoreKey {
+	return pt.prefix() + state.KVStoreKey(strconv.Itoa(port))
+}
+
+// Get returns the remembered decision for port. found == false means the user has to be asked.
+func (pt *PortTrust) Get(ctx context.Context, port int) (decision PortDecision, found bool, err error) {
+	if pt.kvStore == nil {
+		return "", false, nil
+	}
+	entry, found, err := pt.kvStore.Get(ctx, pt.deriveKey(port))
+	if err != nil || !found {
+		return "", false, err
+	}
+	return PortDecision(entry.Value), true, nil
+}
+
+// Set remembers decision for port.
+func (pt *PortTrust) Set(ctx context.Context, port int, decision PortDecision) error {
+	if pt.kvStore == nil {
+		return fmt.Errorf("no state store to remember the decision for port %d", port)
+	}
+	return pt.kvStore.Upsert(ctx, pt.deriveKey(port), string(decision))
+}
+
+// Revoke forgets the decision for port, so the user is asked again next time.
+func (pt *PortTrust) Revoke(ctx context.Context, port int) error {
+	if pt.kvStore == nil {
+		return nil
+	}
+	return pt.kvStore.Delete(ctx, pt.deriveKey(port))
+}
+
+// List returns all remembered decisions of the project ordered by port.
+func (pt *PortTrust) List(ctx context.Context) ([]PortTrustEntry, error) {
+	if pt.kvStore == nil {
+		return []PortTrustEntry{}, nil
+	}
+	entries, err := pt.kvStore.ListPrefix(ctx, pt.prefix())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]PortTrustEntry, 0, len(entries))
+	for _, entry := range entries {
+		port, err := strconv.Atoi(strings.TrimPrefix(string(entry.Key), string(pt.prefix())))
+		if err != nil {
+			continue
+		}
+		out = append(out, PortTrustEntry{Port: port, Decision: PortDecision(entry.Value), CreatedAt: entry.CreatedAt})
+	}
+	// keys are ordered as strings, ports must be ordered as numbers
+	sort.Slice(out, func(i, j int) bool { return out[i].Port < out[j].Port })
+	return out, nil
+}
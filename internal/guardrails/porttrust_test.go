@@ -0,0 +1,88 @@
+// Tests in this file exercise remembered host port decisions of the reverse proxy.
+package guardrails
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xa1bed0/mkenv/internal/state"
+)
+
+// newTestKVStore returns a KV store backed by a database in a temp folder.
+func newTestKVStore(t *testing.T) *state.KVStore {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	db, err := state.Open(ctx, state.Config{Path: filepath.Join(t.TempDir(), "state.db")})
+	if err != nil {
+		t.Fatalf("state.Open: %v", err)
+	}
+	kvStore, err := state.NewKVStore(ctx, db)
+	if err != nil {
+		t.Fatalf("NewKVStore: %v", err)
+	}
+	return kvStore
+}
+
+func TestPortTrust(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	kvStore := newTestKVStore(t)
+	pt := NewPortTrust(kvStore, "app")
+	// a project whose name starts like another one must not see its decisions
+	other := NewPortTrust(kvStore, "app-2")
+
+	if _, found, err := pt.Get(ctx, 8080); err != nil || found {
+		t.Fatalf("expected no decision, got found=%v err=%v", found, err)
+	}
+	for port, decision := range map[int]PortDecision{8080: PortAllow, 443: PortDeny, 10000: PortAllow} {
+		if err := pt.Set(ctx, port, decision); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if err := other.Set(ctx, 5432, PortAllow); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := pt.Set(ctx, 8080, PortDeny); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if decision, found, err := pt.Get(ctx, 8080); err != nil || !found || decision != PortDeny {
+		t.Fatalf("Get(8080) = %q %v %v, want the last decision", decision, found, err)
+	}
+
+	entries, err := pt.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := []PortTrustEntry{{Port: 443, Decision: PortDeny}, {Port: 8080, Decision: PortDeny}, {Port: 10000, Decision: PortAllow}}
+	if len(entries) != len(want) {
+		t.Fatalf("List = %v, want %v", entries, want)
+	}
+	for i, entry := range entries {
+		if entry.Port != want[i].Port || entry.Decision != want[i].Decision {
+			t.Fatalf("List = %v, want %v", entries, want)
+		}
+	}
+
+	if err := pt.Revoke(ctx, 8080); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, found, err := pt.Get(ctx, 8080); err != nil || found {
+		t.Fatalf("expected the decision to be revoked, got found=%v err=%v", found, err)
+	}
+	if _, found, _ := other.Get(ctx, 5432); !found {
+		t.Fatalf("expected decisions of other projects to stay")
+	}
+
+	// without a state store nothing is remembered and the user is always asked
+	noStore := NewPortTrust(nil, "app")
+	if _, found, err := noStore.Get(ctx, 8080); err != nil || found {
+		t.Fatalf("expected no decision without a store, got found=%v err=%v", found, err)
+	}
+	if err := noStore.Set(ctx, 8080, PortAllow); err == nil {
+		t.Fatalf("expected Set to fail without a store")
+	}
+}
@@ -3,14 +3,22 @@ package guardrails
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"unicode"
 	"unicode/utf8"
 
+	"github.com/0xa1bed0/mkenv/internal/fswalk"
 	"github.com/0xa1bed0/mkenv/internal/logs"
 )
 
@@ -32,64 +40,64 @@ var suspiciousFilenames = []string{
 	".ftpconfig",
 }
 
-var suspiciousContentRegexps = []*regexp.Regexp{
+var builtinContentRules = []contentRule{
 	// --- Private keys / SSH ---
-	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`),
-	regexp.MustCompile(`ssh-(rsa|ed25519|dss) `),
+	{ID: "private-key", Regexp: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{ID: "ssh-key", Regexp: regexp.MustCompile(`ssh-(rsa|ed25519|dss) `)},
 
 	// --- GitHub / GitLab ---
-	regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36}`),
-	regexp.MustCompile(`glpat-[A-Za-z0-9\-]{20,}`),
+	{ID: "github-token", Regexp: regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36}`)},
+	{ID: "gitlab-token", Regexp: regexp.MustCompile(`glpat-[A-Za-z0-9\-]{20,}`)},
 
 	// --- Stripe ---
-	regexp.MustCompile(`sk_live_[0-9A-Za-z]{24}`),
-	regexp.MustCompile(`rk_live_[0-9A-Za-z]{24}`),
+	{ID: "stripe-secret-key", Regexp: regexp.MustCompile(`sk_live_[0-9A-Za-z]{24}`)},
+	{ID: "stripe-restricted-key", Regexp: regexp.MustCompile(`rk_live_[0-9A-Za-z]{24}`)},
 
 	// --- Slack ---
-	regexp.MustCompile(`xox[baprs]-[0-9A-Za-z]{10,48}`),
+	{ID: "slack-token", Regexp: regexp.MustCompile(`xox[baprs]-[0-9A-Za-z]{10,48}`)},
 
 	// --- AWS ---
-	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),                                                // Access key id
-	regexp.MustCompile(`(?i)aws(.{0,20})?(secret|key)[^A-Za-z0-9]{0,3}[A-Za-z0-9/+]{40}`), // Secret key
+	{ID: "aws-access-key-id", Regexp: regexp.MustCompile(`AKIA[0-9A-Z]{16}`)},                                             // Access key id
+	{ID: "aws-secret-key", Regexp: regexp.MustCompile(`(?i)aws(.{0,20})?(secret|key)[^A-Za-z0-9]{0,3}[A-Za-z0-9/+]{40}`)}, // Secret key
 
 	// --- Google / Firebase / GCP ---
-	regexp.MustCompile(`AIza[0-9A-Za-z\-_]{35}`), // Google API key (used by many GCP/Gemini/Firebase products)
-	regexp.MustCompile(`(?i)\b(gcp|google)(.{0,20})?(api[_-]?key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "google-api-key", Regexp: regexp.MustCompile(`AIza[0-9A-Za-z\-_]{35}`)}, // Google API key (used by many GCP/Gemini/Firebase products)
+	{ID: "gcp-secret", Regexp: regexp.MustCompile(`(?i)\b(gcp|google)(.{0,20})?(api[_-]?key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- OpenAI / Azure OpenAI / OpenRouter ---
-	regexp.MustCompile(`\bsk-[A-Za-z0-9]{32,}\b`),   // OpenAI-style keys (prefix stable, length can vary)
-	regexp.MustCompile(`\b(or-[A-Za-z0-9]{20,})\b`), // OpenRouter keys often start with or-
-	regexp.MustCompile(`(?i)\b(openai|azure[_-]?openai|openrouter)(.{0,20})?(key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "openai-key", Regexp: regexp.MustCompile(`\bsk-[A-Za-z0-9]{32,}\b`)},       // OpenAI-style keys (prefix stable, length can vary)
+	{ID: "openrouter-key", Regexp: regexp.MustCompile(`\b(or-[A-Za-z0-9]{20,})\b`)}, // OpenRouter keys often start with or-
+	{ID: "openai-secret", Regexp: regexp.MustCompile(`(?i)\b(openai|azure[_-]?openai|openrouter)(.{0,20})?(key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- Anthropic (Claude) ---
-	regexp.MustCompile(`\bsk-ant-[A-Za-z0-9\-_]{20,}\b`),
-	regexp.MustCompile(`(?i)\b(anthropic|claude)(.{0,20})?(key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "anthropic-key", Regexp: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9\-_]{20,}\b`)},
+	{ID: "anthropic-secret", Regexp: regexp.MustCompile(`(?i)\b(anthropic|claude)(.{0,20})?(key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- Cohere ---
-	regexp.MustCompile(`(?i)\b(cohere)(.{0,20})?(api[_-]?key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "cohere-secret", Regexp: regexp.MustCompile(`(?i)\b(cohere)(.{0,20})?(api[_-]?key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- Hugging Face ---
-	regexp.MustCompile(`\bhf_[A-Za-z0-9]{30,}\b`),
-	regexp.MustCompile(`(?i)\b(huggingface|hf)(.{0,20})?(token|key|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "huggingface-token", Regexp: regexp.MustCompile(`\bhf_[A-Za-z0-9]{30,}\b`)},
+	{ID: "huggingface-secret", Regexp: regexp.MustCompile(`(?i)\b(huggingface|hf)(.{0,20})?(token|key|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- Mistral / Together / Groq / Replicate / Fireworks / Perplexity / DeepInfra / AI21 ---
-	regexp.MustCompile(`(?i)\b(mistral|together|groq|replicate|fireworks|perplexity|deepinfra|ai21)(.{0,20})?(api[_-]?key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "ai-provider-secret", Regexp: regexp.MustCompile(`(?i)\b(mistral|together|groq|replicate|fireworks|perplexity|deepinfra|ai21)(.{0,20})?(api[_-]?key|token|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- Cloudflare ---
-	regexp.MustCompile(`(?i)\b(CF_API_KEY|CF_API_TOKEN|CLOUDFLARE_API_TOKEN)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "cloudflare-token", Regexp: regexp.MustCompile(`(?i)\b(CF_API_KEY|CF_API_TOKEN|CLOUDFLARE_API_TOKEN)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- Vercel / Netlify / Render / Fly.io ---
-	regexp.MustCompile(`(?i)\b(VERCEL_TOKEN|NETLIFY_AUTH_TOKEN|RENDER_API_KEY|FLY_API_TOKEN)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "hosting-token", Regexp: regexp.MustCompile(`(?i)\b(VERCEL_TOKEN|NETLIFY_AUTH_TOKEN|RENDER_API_KEY|FLY_API_TOKEN)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- Supabase / PlanetScale / Neon / Railway ---
-	regexp.MustCompile(`(?i)\b(SUPABASE_SERVICE_ROLE_KEY|SUPABASE_ANON_KEY)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
-	regexp.MustCompile(`(?i)\b(PLANETSCALE|NEON|RAILWAY)(.{0,20})?(token|key|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`),
+	{ID: "supabase-key", Regexp: regexp.MustCompile(`(?i)\b(SUPABASE_SERVICE_ROLE_KEY|SUPABASE_ANON_KEY)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
+	{ID: "database-token", Regexp: regexp.MustCompile(`(?i)\b(PLANETSCALE|NEON|RAILWAY)(.{0,20})?(token|key|secret)\b.{0,5}['"]?[A-Za-z0-9\-_]{20,}`)},
 
 	// --- Postgres/MySQL/Mongo/Redis connection strings ---
-	regexp.MustCompile(`(?i)\b(postgres(ql)?|mysql|mongodb(\+srv)?|redis)://[^ \n'"]+`),
+	{ID: "database-url", Regexp: regexp.MustCompile(`(?i)\b(postgres(ql)?|mysql|mongodb(\+srv)?|redis)://[^ \n'"]+`)},
 
 	// --- JWT (only real 3-part JWTs) ---
-	regexp.MustCompile(`eyJ[A-Za-z0-9_-]{20,}\.[A-Za-z0-9_-]{20,}\.[A-Za-z0-9_-]{20,}`),
+	{ID: "jwt", Regexp: regexp.MustCompile(`eyJ[A-Za-z0-9_-]{20,}\.[A-Za-z0-9_-]{20,}\.[A-Za-z0-9_-]{20,}`)},
 }
 
 var ignoredDirs = map[string]bool{
@@ -112,60 +120,183 @@ var ignoredDirs = map[string]bool{
 
 const maxFileSizeForScan = 5 * 1024 * 1024 // 5 MB
 
+// Scan rule IDs of findings that don't come from content rules.
+const (
+	ScanRuleFilename    = "filename"
+	ScanRuleHighEntropy = "high-entropy"
+	ScanRuleSymlink     = "symlink-escape"
+	ScanRuleHardlink    = "hardlink-escape"
+)
+
+const (
+	defaultEntropyThreshold = 4.5 // bits per char. Random base64 is close to 6, hex can't exceed 4
+	defaultEntropyMinLength = 20
+)
+
+// entropySkippedFiles are full of hashes and encoded data, high entropy there means nothing.
+var entropySkippedFiles = []string{
+	"package-lock.json", "yarn.lock", "pnpm-lock.yaml", "go.sum", "cargo.lock", "poetry.lock",
+	"composer.lock", "gemfile.lock", "pipfile.lock", "uv.lock", "bun.lock", "flake.lock",
+	"*.min.js", "*.min.css", "*.map", "*.svg",
+}
+
+var entropyTokenRegexp = regexp.MustCompile(`[A-Za-z0-9+/=_\-]+`)
+
+type contentRule struct {
+	ID     string
+	Regexp *regexp.Regexp
+}
+
+// ScanConfig extends the built-in secret scanner rules. Policies and .mkenv files can set it.
+type ScanConfig struct {
+	Filenames       []string         `json:"filenames"`        // parts of file names that indicate secrets, case insensitive
+	ContentPatterns []ContentPattern `json:"content_patterns"` // regexps matched against every line
+	Entropy         *EntropyConfig   `json:"entropy"`          // high-entropy token detection. Only policies can change it
+}
+
+// ContentPattern is a named regexp of secrets in file content.
+type ContentPattern struct {
+	ID      string `json:"id"`
+	Pattern string `json:"pattern"`
+}
+
+// EntropyConfig tunes detection of generic high-entropy tokens.
+type EntropyConfig struct {
+	Disabled  bool    `json:"disabled"`
+	Threshold float64 `json:"threshold"`  // Shannon entropy in bits per char. Default 4.5
+	MinLength int     `json:"min_length"` // shorter tokens are ignored. Default 20
+}
+
+// Validate checks that content patterns have IDs and compile.
+func (c ScanConfig) Validate() error {
+	for _, cp := range c.ContentPatterns {
+		if cp.ID == "" {
+			return fmt.Errorf("content pattern %q has no id", cp.Pattern)
+		}
+		if _, err := regexp.Compile(cp.Pattern); err != nil {
+			return fmt.Errorf("content pattern %s: %w", cp.ID, err)
+		}
+	}
+	if c.Entropy != nil && (c.Entropy.Threshold < 0 || c.Entropy.MinLength < 0) {
+		return errors.New("entropy threshold and min_length can't be negative")
+	}
+	return nil
+}
+
 type SensitivityWarning struct {
-	Path    string
-	Reason  string
-	Content []string
+	Path        string // absolute path
+	RelPath     string // slash separated path relative to the scanned root
+	Rule        string
+	Line        int // 1-based, 0 for findings about the whole file
+	Reason      string
+	Content     []string
+	Fingerprint string // identifies the finding across scans, see FindingFingerprint
 }
 
 var ErrScanCanceled = errors.New("scan canceled")
 
-// ScanSuspiciousFiles walks a path tree and reports files that appear suspicious by name or content.
-func ScanSuspiciousFiles(ctx context.Context, root string) ([]*SensitivityWarning, error) {
-	suspicious := []*SensitivityWarning{}
-	tailBox := logs.NewTailBox("Files scanner")
-	defer tailBox.Close()
+// FindingFingerprint identifies a finding by the file path relative to the project, the rule and the hash of the line,
+// so it survives unrelated edits of the file.
+func FindingFingerprint(relPath, rule, line string) string {
+	lineSum := sha256.Sum256([]byte(line))
+	sum := sha256.Sum256([]byte(relPath + "\x00" + rule + "\x00" + hex.EncodeToString(lineSum[:])))
+	return hex.EncodeToString(sum[:16])
+}
+
+func newSensitivityWarning(root, path, rule string, lineNo int, line, reason string) *SensitivityWarning {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+	return &SensitivityWarning{
+		Path:        path,
+		RelPath:     rel,
+		Rule:        rule,
+		Line:        lineNo,
+		Reason:      reason,
+		Fingerprint: FindingFingerprint(rel, rule, line),
+	}
+}
 
-	// helper to keep ctx checks short + consistent
-	checkCtx := func() error {
-		if err := ctx.Err(); err != nil {
-			return ErrScanCanceled
+// Scanner finds files that appear to contain secrets by name or content.
+type Scanner struct {
+	filenames []string
+	content   []contentRule
+	entropy   EntropyConfig
+}
+
+// NewScanner returns a scanner with the built-in rules extended by configs.
+// Entropy detection is off only if every config that sets entropy disables it, the lowest threshold and min length win.
+func NewScanner(configs ...ScanConfig) (*Scanner, error) {
+	s := &Scanner{
+		filenames: append([]string{}, suspiciousFilenames...),
+		content:   append([]contentRule{}, builtinContentRules...),
+		entropy:   EntropyConfig{Threshold: defaultEntropyThreshold, MinLength: defaultEntropyMinLength},
+	}
+
+	thresholdSet, minLengthSet, entropySet := false, false, false
+	for _, c := range configs {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+		for _, name := range c.Filenames {
+			s.filenames = append(s.filenames, strings.ToLower(name))
+		}
+		for _, cp := range c.ContentPatterns {
+			s.content = append(s.content, contentRule{ID: cp.ID, Regexp: regexp.MustCompile(cp.Pattern)})
+		}
+		if c.Entropy == nil {
+			continue
+		}
+		s.entropy.Disabled = c.Entropy.Disabled && (s.entropy.Disabled || !entropySet)
+		entropySet = true
+		if c.Entropy.Threshold > 0 && (!thresholdSet || c.Entropy.Threshold < s.entropy.Threshold) {
+			s.entropy.Threshold, thresholdSet = c.Entropy.Threshold, true
+		}
+		if c.Entropy.MinLength > 0 && (!minLengthSet || c.Entropy.MinLength < s.entropy.MinLength) {
+			s.entropy.MinLength, minLengthSet = c.Entropy.MinLength, true
 		}
-		return nil
 	}
+	return s, nil
+}
+
+// Scan walks a path tree and reports files that appear suspicious by name and every suspicious line of other files.
+// Files are scanned concurrently. Folders in ignoredDirs are skipped. Ignore files are not honored: ignored files
+// are still mounted to the sandbox, .env files are usually in .gitignore, and a .mkenvignore of the repository
+// could hide its own secrets from the scan.
+// walker is shared with the other scans of the run (see ScanLinkEscapes), so the tree is listed once.
+func (s *Scanner) Scan(ctx context.Context, walker *fswalk.Walker, root string) ([]*SensitivityWarning, error) {
+	var mu sync.Mutex
+	suspicious := []*SensitivityWarning{}
+	report := func(warnings ...*SensitivityWarning) {
+		mu.Lock()
+		suspicious = append(suspicious, warnings...)
+		mu.Unlock()
+	}
+
+	tailBox := logs.NewTailBox("Files scanner")
+	defer tailBox.Close()
 
 	// in case ctx is already canceled
-	if err := checkCtx(); err != nil {
-		return nil, err
+	if ctx.Err() != nil {
+		return nil, ErrScanCanceled
 	}
 
-	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+	err := walker.Walk(ctx, root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return nil // skip unreadable paths
 		}
 
-		if ctxErr := checkCtx(); ctxErr != nil {
-			return ctxErr
-		}
-
 		if d.IsDir() {
-			base := filepath.Base(path)
-			if ignoredDirs[base] {
-				tailBox.Printf("Skipping %s folder", base)
-				return filepath.SkipDir
-			}
+			tailBox.Printf("Scanning %s...", path)
 			return nil
 		}
 
-		tailBox.Printf("Check filename %s if it potentially sensitive...", path)
-
 		lower := strings.ToLower(filepath.Base(path))
-		for _, name := range suspiciousFilenames {
+		for _, name := range s.filenames {
 			if strings.Contains(lower, name) {
-				suspicious = append(suspicious, &SensitivityWarning{
-					Path:   path,
-					Reason: "Filename indicates potential sensitivity",
-				})
+				report(newSensitivityWarning(root, path, ScanRuleFilename, 0, "", "Filename indicates potential sensitivity"))
 				return nil
 			}
 		}
@@ -175,52 +306,112 @@ func ScanSuspiciousFiles(ctx context.Context, root string) ([]*SensitivityWarnin
 			return nil
 		}
 
-		tailBox.Printf("Check if %s has potentially sensitive data...", path)
-
-		f, err := os.Open(path)
-		if err != nil {
-			return nil
+		report(s.scanFile(root, path, !s.entropy.Disabled && !entropySkipped(lower))...)
+		return nil
+	}, fswalk.WithSkipDirs(slices.Collect(maps.Keys(ignoredDirs))...))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ErrScanCanceled
 		}
-		defer f.Close()
+		return nil, err
+	}
 
-		scanner := bufio.NewScanner(f)
-		scanner.Buffer(make([]byte, 0, 64*1024), int(maxFileSizeForScan))
-		previousLine := ""
-		for scanner.Scan() {
-			line := scanner.Text()
-			if !utf8.ValidString(line) {
-				continue
-			}
-			for _, re := range suspiciousContentRegexps {
-				if re.MatchString(line) {
-					// Peek one line ahead (only if it exists)
-					nextLine := ""
-					if scanner.Scan() {
-						nextLine = scanner.Text()
-						if !utf8.ValidString(nextLine) {
-							nextLine = ""
-						}
-					} else if err := scanner.Err(); err != nil {
-						// ignore scan error for context, we're already flagging
-						nextLine = ""
-					}
-
-					suspicious = append(suspicious, &SensitivityWarning{
-						Path:   path,
-						Reason: fmt.Sprintf("file contains potentially sensitive data: %s", re.String()),
-						Content: []string{
-							previousLine,
-							line,
-							nextLine,
-						},
-					})
-					return nil
-				}
-			}
+	sortSensitivityWarnings(suspicious)
+	return suspicious, nil
+}
 
-			previousLine = line
+// sortSensitivityWarnings orders warnings by path and line, concurrent walks find them in random order.
+func sortSensitivityWarnings(warnings []*SensitivityWarning) {
+	slices.SortFunc(warnings, func(a, b *SensitivityWarning) int {
+		if c := strings.Compare(a.RelPath, b.RelPath); c != 0 {
+			return c
 		}
-		return nil
+		return a.Line - b.Line
 	})
-	return suspicious, err
+}
+
+// scanFile reports every line of the file that matches a content rule or, if entropy is true, has a high-entropy token.
+func (s *Scanner) scanFile(root, path string, entropy bool) []*SensitivityWarning {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	warnings := []*SensitivityWarning{}
+	var last *SensitivityWarning
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(maxFileSizeForScan))
+	previousLine := ""
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if !utf8.ValidString(line) {
+			continue
+		}
+		if last != nil {
+			// the line after the previous finding
+			last.Content = append(last.Content, line)
+			last = nil
+		}
+
+		rule, reason := s.matchLine(line, entropy)
+		if rule != "" {
+			last = newSensitivityWarning(root, path, rule, lineNo, line, reason)
+			last.Content = []string{previousLine, line}
+			warnings = append(warnings, last)
+		}
+
+		previousLine = line
+	}
+	return warnings
+}
+
+func (s *Scanner) matchLine(line string, entropy bool) (rule, reason string) {
+	for _, cr := range s.content {
+		if cr.Regexp.MatchString(line) {
+			return cr.ID, fmt.Sprintf("file contains potentially sensitive data: %s", cr.ID)
+		}
+	}
+	if !entropy {
+		return "", ""
+	}
+	for _, token := range entropyTokenRegexp.FindAllString(line, -1) {
+		if len(token) < s.entropy.MinLength || !hasLettersAndDigits(token) {
+			continue
+		}
+		if e := shannonEntropy(token); e >= s.entropy.Threshold {
+			return ScanRuleHighEntropy, fmt.Sprintf("file contains a high-entropy token (%.1f bits per char)", e)
+		}
+	}
+	return "", ""
+}
+
+func entropySkipped(lowerName string) bool {
+	for _, pattern := range entropySkippedFiles {
+		if matched, _ := filepath.Match(pattern, lowerName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per char.
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+func hasLettersAndDigits(s string) bool {
+	return strings.ContainsAny(s, "0123456789") && strings.IndexFunc(s, unicode.IsLetter) >= 0
 }
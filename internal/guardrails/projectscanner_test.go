@@ -0,0 +1,87 @@
+// Tests in this file exercise the secret scanner rules and finding fingerprints.
+package guardrails
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xa1bed0/mkenv/internal/fswalk"
+)
+
+func TestScannerRules(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"config.yaml":       "name: app\ninternal_key: corp-0123456789abcdef\n",
+		"main.go":           "package main\n\nconst key = \"q8Zr2LxN7vKp4TfW9sYb3HcJ6mDe1GuA\"\n",
+		"package-lock.json": "\"integrity\": \"q8Zr2LxN7vKp4TfW9sYb3HcJ6mDe1GuA\"\n",
+		"README.md":         "just some text with a_long_identifier_without_digits_at_all\n",
+		// the repository can't hide its own secrets from the scan
+		".mkenvignore": "config.yaml\nmain.go\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	s, err := NewScanner(ScanConfig{ContentPatterns: []ContentPattern{{ID: "corp-key", Pattern: `corp-[0-9a-f]{16}`}}})
+	if err != nil {
+		t.Fatalf("NewScanner: %v", err)
+	}
+	warnings, err := s.Scan(context.Background(), fswalk.New(), root)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, warn := range warnings {
+		got[warn.RelPath] = warn.Rule
+	}
+	want := map[string]string{"config.yaml": "corp-key", "main.go": ScanRuleHighEntropy}
+	if len(got) != len(want) {
+		t.Fatalf("findings = %v, want %v", got, want)
+	}
+	for path, rule := range want {
+		if got[path] != rule {
+			t.Fatalf("finding in %s = %q, want %q (all findings %v)", path, got[path], rule, got)
+		}
+	}
+
+	disabled, err := NewScanner(ScanConfig{Entropy: &EntropyConfig{Disabled: true}})
+	if err != nil {
+		t.Fatalf("NewScanner: %v", err)
+	}
+	warnings, err = disabled.Scan(context.Background(), fswalk.New(), root)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("expected no findings with entropy detection disabled, got %d", len(warnings))
+	}
+
+	if _, err := NewScanner(ScanConfig{ContentPatterns: []ContentPattern{{ID: "broken", Pattern: `(`}}}); err == nil {
+		t.Fatalf("expected an error for a pattern that doesn't compile")
+	}
+}
+
+func TestFindingFingerprint(t *testing.T) {
+	t.Parallel()
+
+	fp := FindingFingerprint("config/.env", "aws-secret-key", "AWS_SECRET_ACCESS_KEY=abc")
+	if fp != FindingFingerprint("config/.env", "aws-secret-key", "AWS_SECRET_ACCESS_KEY=abc") {
+		t.Fatalf("fingerprint is not stable")
+	}
+	for _, other := range []string{
+		FindingFingerprint("other/.env", "aws-secret-key", "AWS_SECRET_ACCESS_KEY=abc"),
+		FindingFingerprint("config/.env", "high-entropy", "AWS_SECRET_ACCESS_KEY=abc"),
+		FindingFingerprint("config/.env", "aws-secret-key", "AWS_SECRET_ACCESS_KEY=abd"),
+	} {
+		if other == fp {
+			t.Fatalf("fingerprint %s doesn't depend on path, rule and line", fp)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/state"
+)
+
+// ScanAllowlistEntry is a secret scanner finding the user accepted.
+type ScanAllowlistEntry struct {
+	Fingerprint string    `json:"fingerprint"`
+	Path        string    `json:"path"` // relative to the project
+	Rule        string    `json:"rule"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ScanAllowlist remembers findings of the secret scanner the user accepted for a project, so they are not reported again.
+// Findings are identified by their fingerprints (see FindingFingerprint) and live in the state KV store.
+type ScanAllowlist struct {
+	kvStore     *state.KVStore
+	projectName string
+}
+
+func NewScanAllowlist(kvStore *state.KVStore, projectName string) *ScanAllowlist {
+	return &ScanAllowlist{kvStore: kvStore, projectName: projectName}
+}
+
+func (sa *ScanAllowlist) prefix() state.KVStoreKey {
+	return state.KVStoreKey("scan-allow:" + sa.projectName + ":")
+}
+
+func (sa *ScanAllowlist) deriveKey(fingerprint string) state.KVStoreKey {
+	return sa.prefix() + state.KVStoreKey(fingerprint)
+}
+
+type scanAllowlistValue struct {
+	Path string `json:"path"`
+	Rule string `json:"rule"`
+}
+
+// Has reports whether the finding with fingerprint is accepted.
+func (sa *ScanAllowlist) Has(ctx context.Context, fingerprint string) (bool, error) {
+	if sa.kvStore == nil {
+		return false, nil
+	}
+	_, found, err := sa.kvStore.Get(ctx, sa.deriveKey(fingerprint))
+	return found, err
+}
+
+// Add accepts the findings.
+func (sa *ScanAllowlist) Add(ctx context.Context, warnings ...*SensitivityWarning) error {
+	if sa.kvStore == nil {
+		return fmt.Errorf("no state store to remember accepted findings")
+	}
+	for _, warn := range warnings {
+		value, err := json.Marshal(scanAllowlistValue{Path: warn.RelPath, Rule: warn.Rule})
+		if err != nil {
+			return err
+		}
+		if err := sa.kvStore.Upsert(ctx, sa.deriveKey(warn.Fingerprint), string(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove forgets the accepted finding, so it is reported again.
+func (sa *ScanAllowlist) Remove(ctx context.Context, fingerprint string) error {
+	if sa.kvStore == nil {
+		return nil
+	}
+	return sa.kvStore.Delete(ctx, sa.deriveKey(fingerprint))
+}
+
+// List returns all accepted findings of the project.
+func (sa *ScanAllowlist) List(ctx context.Context) ([]ScanAllowlistEntry, error) {
+	if sa.kvStore == nil {
+		return []ScanAllowlistEntry{}, nil
+	}
+	entries, err := sa.kvStore.ListPrefix(ctx, sa.prefix())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ScanAllowlistEntry, 0, len(entries))
+	for _, entry := range entries {
+		var value scanAllowlistValue
+		_ = json.Unmarshal([]byte(entry.Value), &value) // entries without details are still accepted
+		out = append(out, ScanAllowlistEntry{
+			Fingerprint: strings.TrimPrefix(string(entry.Key), string(sa.prefix())),
+			Path:        value.Path,
+			Rule:        value.Rule,
+			CreatedAt:   entry.CreatedAt,
+		})
+	}
+	return out, nil
+}
+
+// Filter splits warnings into the ones that are not accepted yet and the accepted ones.
+func (sa *ScanAllowlist) Filter(ctx context.Context, warnings []*SensitivityWarning) (pending, accepted []*SensitivityWarning, err error) {
+	pending = []*SensitivityWarning{}
+	accepted = []*SensitivityWarning{}
+	for _, warn := range warnings {
+		found, err := sa.Has(ctx, warn.Fingerprint)
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			accepted = append(accepted, warn)
+		} else {
+			pending = append(pending, warn)
+		}
+	}
+	return pending, accepted, nil
+}
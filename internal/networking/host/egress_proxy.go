@@ -0,0 +1,367 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/audit"
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+)
+
+// egressDialTimeout is how long the proxy waits for an allowed upstream to accept the connection.
+const egressDialTimeout = 10 * time.Second
+
+// EgressProxy is the HTTP/CONNECT proxy the sandbox reaches the internet through in egress mode.
+// Only allowed domains can be reached. Other domains are offered to the user for approval once per run;
+// denied attempts are logged.
+type EgressProxy struct {
+	addr    string
+	allowed []string
+	approve func(ctx context.Context, text string) bool
+	// lookupIP resolves upstream names, net.DefaultResolver unless replaced in tests
+	lookupIP func(ctx context.Context, network, host string) ([]net.IP, error)
+
+	auditLog *audit.Log
+
+	server    *http.Server
+	transport *http.Transport
+
+	mu        sync.Mutex
+	decisions map[string]bool          // domain => approved, made interactively during this run
+	pending   map[string]chan struct{} // domain => closed when the user answers
+}
+
+// StartEgressProxy starts the egress proxy on a random loopback port. Every request is recorded in auditLog.
+func StartEgressProxy(rt *runtime.Runtime, allowed []string, approve func(ctx context.Context, text string) bool, auditLog *audit.Log) (*EgressProxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("egress proxy listen: %w", err)
+	}
+
+	ep := &EgressProxy{
+		addr:      ln.Addr().String(),
+		allowed:   append([]string{}, allowed...),
+		approve:   approve,
+		lookupIP:  net.DefaultResolver.LookupIP,
+		auditLog:  auditLog,
+		decisions: map[string]bool{},
+		pending:   map[string]chan struct{}{},
+	}
+	ep.transport = &http.Transport{
+		DialContext:         ep.dial,
+		MaxIdleConnsPerHost: 8,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	ep.server = &http.Server{
+		Handler:           ep,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	rt.GoNamed("EgressProxy", func() {
+		if err := ep.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logs.Errorf("egress proxy: %v", err)
+		}
+	})
+
+	rt.OnShutdown(func(ctx context.Context) {
+		logs.Debugf("Shutdown egress proxy...")
+		ep.Stop()
+	})
+
+	logs.Debugf("egress proxy listening on %s, allowed domains: %v", ep.addr, ep.allowed)
+	return ep, nil
+}
+
+// Stop closes the proxy and all connections going through it.
+func (ep *EgressProxy) Stop() {
+	_ = ep.server.Close()
+	ep.transport.CloseIdleConnections()
+}
+
+// Port returns the port the proxy listens on.
+func (ep *EgressProxy) Port() int {
+	_, portStr, err := net.SplitHostPort(ep.addr)
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(portStr)
+	return port
+}
+
+func (ep *EgressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(r.URL.Hostname())
+	if host == "" {
+		http.Error(w, "mkenv egress proxy: only proxy requests are supported", http.StatusBadRequest)
+		return
+	}
+
+	rec := audit.Record{
+		Direction: audit.DirectionSandboxToInternet,
+		Proto:     shared.ProtoTCP,
+		Port:      egressPort(r),
+		Host:      host,
+		Remote:    r.RemoteAddr,
+		Start:     time.Now(),
+		Decision:  audit.DecisionAllow,
+	}
+	defer func() {
+		if err := ep.auditLog.Write(rec); err != nil {
+			logs.Warnf("egress: can't write audit log: %v", err)
+		}
+	}()
+
+	if !ep.allow(r.Context(), host) {
+		logs.Warnf("egress: %s %s from sandbox is denied", r.Method, r.URL.Host)
+		rec.Decision = audit.DecisionDeny
+		rec.Reason = "not in egress allowlist"
+		http.Error(w, fmt.Sprintf("mkenv egress proxy: %s is not in the egress allowlist", host), http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		ep.tunnel(w, r, &rec)
+		return
+	}
+
+	if r.URL.Scheme != "http" {
+		rec.Error = "unsupported scheme " + r.URL.Scheme
+		http.Error(w, "mkenv egress proxy: unsupported scheme "+r.URL.Scheme, http.StatusBadRequest)
+		return
+	}
+
+	logs.InfofSilent("egress: %s %s", r.Method, r.URL.Host)
+
+	body := &countingReader{r: r.Body}
+	r.Body = body
+	cw := &countingResponseWriter{ResponseWriter: w}
+	proxy := &httputil.ReverseProxy{
+		// the request URL is absolute already, it only needs hop-by-hop headers removed
+		Rewrite:   func(*httputil.ProxyRequest) {},
+		Transport: ep.transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logs.Warnf("egress: %s %s: %v", r.Method, r.URL.Host, err)
+			rec.Error = err.Error()
+			http.Error(w, "mkenv egress proxy: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(cw, r)
+
+	rec.BytesFromSandbox = body.n
+	rec.BytesToSandbox = cw.n
+}
+
+// tunnel handles CONNECT: the sandbox gets a raw TCP stream to the allowed host (usually TLS).
+func (ep *EgressProxy) tunnel(w http.ResponseWriter, r *http.Request, rec *audit.Record) {
+	ctx, cancel := context.WithTimeout(r.Context(), egressDialTimeout)
+	dialed, err := ep.dial(ctx, "tcp", r.URL.Host)
+	cancel()
+	if err != nil {
+		logs.Warnf("egress: CONNECT %s: %v", r.URL.Host, err)
+		rec.Error = err.Error()
+		http.Error(w, "mkenv egress proxy: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	upstream := audit.NewCountingConn(dialed)
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "mkenv egress proxy: hijacking is not supported", http.StatusInternalServerError)
+		return
+	}
+	clientConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		logs.Errorf("egress: hijack: %v", err)
+		rec.Error = err.Error()
+		return
+	}
+	defer clientConn.Close()
+
+	if _, err := clientConn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+
+	logs.InfofSilent("egress: CONNECT %s (start)", r.URL.Host)
+
+	// the client may have sent bytes (e.g. TLS ClientHello) together with the CONNECT request
+	client := &bufferedConn{
+		Conn: clientConn,
+		r:    rw.Reader,
+	}
+	protocol.PumpBidirectional(client, upstream)
+
+	rec.BytesFromSandbox = upstream.BytesWritten()
+	rec.BytesToSandbox = upstream.BytesRead()
+
+	logs.InfofSilent("egress: CONNECT %s (done)", r.URL.Host)
+}
+
+// egressPort returns the destination port of a proxy request.
+func egressPort(r *http.Request) int {
+	if port, err := strconv.Atoi(r.URL.Port()); err == nil {
+		return port
+	}
+	if r.URL.Scheme == "http" {
+		return 80
+	}
+	return 443
+}
+
+// countingReader counts bytes of the request body sent by the sandbox.
+type countingReader struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	return c.r.Close()
+}
+
+// countingResponseWriter counts bytes of the response body sent to the sandbox.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Flush lets the reverse proxy stream responses (e.g. server-sent events) through the wrapper.
+func (c *countingResponseWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// dial connects to an allowed upstream, refusing addresses the sandbox must never reach.
+// The check is done on the resolved addresses, so DNS pointing an allowed name to the host, the docker bridge
+// or the LAN does not help.
+func (ep *EgressProxy) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := ep.lookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout: egressDialTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ipStr, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(ipStr); ip == nil || isEgressForbidden(ip) {
+				return fmt.Errorf("egress to %s is forbidden", ipStr)
+			}
+			return nil
+		},
+	}
+
+	errs := []error{}
+	for _, ip := range ips {
+		if isEgressForbidden(ip) {
+			errs = append(errs, fmt.Errorf("egress to %s (%s) is forbidden: it is a local, private or host address", host, ip))
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("egress to %s: no addresses", host)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// isEgressForbidden returns true if the sandbox must not reach ip: see guardrails.IsEgressForbiddenIP,
+// and any address of the host interfaces, whatever network it is on.
+func isEgressForbidden(ip net.IP) bool {
+	if guardrails.IsEgressForbiddenIP(ip) {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		// fail closed
+		logs.Warnf("egress: can't list host addresses: %v", err)
+		return true
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allow returns true if the sandbox can reach host. Unknown hosts are offered for approval,
+// concurrent requests to the same host wait for a single answer. IP addresses are always refused,
+// so the sandbox can't raise prompts for the host or LAN addresses hoping for a careless yes.
+func (ep *EgressProxy) allow(ctx context.Context, host string) bool {
+	if guardrails.IsIPLiteral(host) {
+		return false
+	}
+	if guardrails.MatchDomain(ep.allowed, host) {
+		return true
+	}
+
+	for {
+		ep.mu.Lock()
+		if approved, ok := ep.decisions[host]; ok {
+			ep.mu.Unlock()
+			return approved
+		}
+		if wait, ok := ep.pending[host]; ok {
+			ep.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return false
+			}
+		}
+		done := make(chan struct{})
+		ep.pending[host] = done
+		ep.mu.Unlock()
+
+		approved := ep.approve(ctx, fmt.Sprintf("Allow sandbox to connect to %s? (not in the egress allowlist)", host))
+
+		ep.mu.Lock()
+		// a canceled request is not an answer, the next one asks again
+		if ctx.Err() == nil {
+			ep.decisions[host] = approved
+		}
+		delete(ep.pending, host)
+		ep.mu.Unlock()
+		close(done)
+
+		return approved
+	}
+}
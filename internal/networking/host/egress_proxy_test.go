@@ -0,0 +1,66 @@
+// Tests in this file exercise which upstreams the egress proxy lets the sandbox reach.
+package host
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestEgressProxy returns a proxy allowing allowed domains, which resolves every name to ips.
+func newTestEgressProxy(allowed []string, ips ...string) *EgressProxy {
+	return &EgressProxy{
+		allowed: allowed,
+		approve: func(context.Context, string) bool { return true },
+		lookupIP: func(context.Context, string, string) ([]net.IP, error) {
+			out := []net.IP{}
+			for _, ip := range ips {
+				out = append(out, net.ParseIP(ip))
+			}
+			return out, nil
+		},
+		decisions: map[string]bool{},
+		pending:   map[string]chan struct{}{},
+	}
+}
+
+func TestEgressProxyRefusesAllowedNamesResolvingToLocalAddresses(t *testing.T) {
+	t.Parallel()
+
+	for _, ip := range []string{"172.17.0.1", "10.1.2.3", "192.168.1.1", "100.100.100.100", "fd00::1", "127.0.0.1", "169.254.169.254"} {
+		ep := newTestEgressProxy([]string{"registry.example.com"}, ip)
+
+		req := httptest.NewRequest(http.MethodConnect, "http://registry.example.com:443", nil)
+		req.URL.Host = "registry.example.com:443"
+		w := httptest.NewRecorder()
+		ep.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "forbidden") {
+			t.Fatalf("%s: expected the connection to be refused, got %d %q", ip, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestEgressProxyRefusesHostAddresses(t *testing.T) {
+	t.Parallel()
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Fatalf("InterfaceAddrs: %v", err)
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if !isEgressForbidden(ipNet.IP) {
+			t.Fatalf("expected host address %s to be forbidden", ipNet.IP)
+		}
+	}
+	if isEgressForbidden(net.ParseIP("1.1.1.1")) {
+		t.Fatalf("expected a public address to be allowed")
+	}
+}
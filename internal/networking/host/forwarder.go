@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	"github.com/0xa1bed0/mkenv/internal/audit"
 	"github.com/0xa1bed0/mkenv/internal/logs"
 	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
 	"github.com/0xa1bed0/mkenv/internal/networking/transport"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
 )
@@ -16,9 +19,12 @@ import (
 // Forwarder forwards localhost:TargetPort -> localhost:ContainerProxyPort
 // the localhost:ContainerProxyPort is the proxy exposed by the container
 // The proxy inside the container then proxies to the real in-container server
+// Every client connection is a stream of Tunnel, shared by all forwarders of the container.
 type Forwarder struct {
 	TargetPort         int
 	ContainerProxyPort int
+	Tunnel             *transport.MuxDialer
+	AuditLog           *audit.Log
 
 	srv  *transport.Server
 	once sync.Once
@@ -50,17 +56,33 @@ func (f *Forwarder) Stop() {
 	})
 }
 
-func (f *Forwarder) handleConn(clientConn net.Conn) {
+func (f *Forwarder) handleConn(conn net.Conn) {
+	clientConn := audit.NewCountingConn(conn)
 	defer clientConn.Close()
 
+	rec := audit.Record{
+		Direction: audit.DirectionHostToSandbox,
+		Proto:     shared.ProtoTCP,
+		Port:      f.TargetPort,
+		Remote:    conn.RemoteAddr().String(),
+		Start:     time.Now(),
+		Decision:  audit.DecisionAllow,
+	}
+	defer func() {
+		rec.BytesToSandbox = clientConn.BytesRead()
+		rec.BytesFromSandbox = clientConn.BytesWritten()
+		if err := f.AuditLog.Write(rec); err != nil {
+			logs.Warnf("forwarder: can't write audit log: %v", err)
+		}
+	}()
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// TODO: make 5 (attempts) configurable
-	// TODO: make 0 (delayBetweenAttempts) configurable
-	backendConn, err := transport.DialTCP(ctx, fmt.Sprintf("127.0.0.1:%d", f.ContainerProxyPort), 5, 0)
+	backendConn, err := f.Tunnel.Dial(ctx)
 	if err != nil {
 		logs.Errorf("forwarder: DialProxy failed for host port %d: %v", f.TargetPort, err)
+		rec.Error = err.Error()
 		return
 	}
 	defer backendConn.Close()
@@ -69,22 +91,32 @@ func (f *Forwarder) handleConn(clientConn net.Conn) {
 	// because when something exposes 3000 port in the container (npm run dev) - the same 3000 port must be opened on host
 	if err := protocol.WriteProxyHeader(backendConn, f.TargetPort); err != nil {
 		logs.Errorf("forwarder: header write failed on %d: %v", f.TargetPort, err)
+		rec.Error = err.Error()
 		return
 	}
 
 	protocol.PumpBidirectional(clientConn, backendConn)
 }
 
+// containerTunnelSize is how many connections to the container proxy all forwarded connections share.
+const containerTunnelSize = 2
+
 type ForwarderRegistry struct {
-	runtime    *runtime.Runtime
-	mu         sync.Mutex
-	forwarders map[int]*Forwarder // key = host port
+	runtime       *runtime.Runtime
+	mu            sync.Mutex
+	forwarders    map[int]*Forwarder    // key = host port
+	udpForwarders map[int]*UDPForwarder // key = host port
+	tunnel        *transport.MuxDialer
+	auditLog      *audit.Log
 }
 
-func NewForwarderRegistry(rt *runtime.Runtime) *ForwarderRegistry {
+// NewForwarderRegistry creates a registry whose forwarders record every connection in auditLog.
+func NewForwarderRegistry(rt *runtime.Runtime, auditLog *audit.Log) *ForwarderRegistry {
 	fr := &ForwarderRegistry{
-		forwarders: make(map[int]*Forwarder),
-		runtime:    rt,
+		forwarders:    make(map[int]*Forwarder),
+		udpForwarders: make(map[int]*UDPForwarder),
+		runtime:       rt,
+		auditLog:      auditLog,
 	}
 
 	rt.OnShutdown(func(ctx context.Context) {
@@ -121,6 +153,8 @@ func (r *ForwarderRegistry) Add(targetPort int) error {
 	f := &Forwarder{
 		TargetPort:         targetPort,
 		ContainerProxyPort: r.runtime.Container().Port(),
+		Tunnel:             r.tunnelLocked(),
+		AuditLog:           r.auditLog,
 	}
 
 	r.forwarders[targetPort] = f
@@ -162,4 +196,67 @@ func (r *ForwarderRegistry) StopAll() {
 		f.Stop()
 		delete(r.forwarders, port)
 	}
+	for port, f := range r.udpForwarders {
+		logs.Debugf("Stop forwarding to udp %d", port)
+		f.Stop()
+		delete(r.udpForwarders, port)
+	}
+	if r.tunnel != nil {
+		_ = r.tunnel.Close()
+		r.tunnel = nil
+	}
+}
+
+// tunnelLocked returns the multiplexed connection to the container proxy, creating it on first use.
+func (r *ForwarderRegistry) tunnelLocked() *transport.MuxDialer {
+	if r.tunnel == nil {
+		// TODO: make 5 (attempts) configurable
+		addr := fmt.Sprintf("127.0.0.1:%d", r.runtime.Container().Port())
+		r.tunnel = transport.NewMuxDialer(addr, containerTunnelSize, 5, 0)
+	}
+	return r.tunnel
+}
+
+func (r *ForwarderRegistry) AddUDP(targetPort int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.udpForwarders[targetPort]; ok {
+		return nil
+	}
+
+	f := &UDPForwarder{
+		TargetPort:         targetPort,
+		ContainerProxyPort: r.runtime.Container().Port(),
+		Tunnel:             r.tunnelLocked(),
+		AuditLog:           r.auditLog,
+	}
+
+	if err := f.Start(r.runtime); err != nil {
+		return err
+	}
+
+	r.udpForwarders[targetPort] = f
+
+	return nil
+}
+
+func (r *ForwarderRegistry) ListUDP() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]int, 0, len(r.udpForwarders))
+	for port := range r.udpForwarders {
+		out = append(out, port)
+	}
+	return out
+}
+
+func (r *ForwarderRegistry) RemoveUDP(targetPort int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.udpForwarders[targetPort]; ok {
+		logs.Debugf("Stop forwarding to udp %d", targetPort)
+		f.Stop()
+		delete(r.udpForwarders, targetPort)
+	}
 }
@@ -0,0 +1,70 @@
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+)
+
+// FillGitCredential asks host git (and the credential helpers configured there) for the credential
+// of req.Host. The caller is responsible for checking the host is allowed and the user approved it.
+func FillGitCredential(ctx context.Context, req shared.GitCredentialRequest) (*shared.GitCredentialResponse, error) {
+	if req.Protocol != "https" {
+		return nil, fmt.Errorf("git credentials are only supplied over https, got %q", req.Protocol)
+	}
+	if req.Host == "" || strings.ContainsAny(req.Host+req.Path+req.Username, "\n\x00") {
+		return nil, errors.New("invalid git credential request")
+	}
+
+	var input strings.Builder
+	input.WriteString("protocol=" + req.Protocol + "\n")
+	input.WriteString("host=" + req.Host + "\n")
+	if req.Path != "" {
+		input.WriteString("path=" + req.Path + "\n")
+	}
+	if req.Username != "" {
+		input.WriteString("username=" + req.Username + "\n")
+	}
+	input.WriteString("\n")
+
+	cmd := exec.CommandContext(ctx, "git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(input.String())
+	// never prompt: the host process may have no terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git credential fill: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	resp := &shared.GitCredentialResponse{}
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "username":
+			resp.Username = value
+		case "password":
+			resp.Password = value
+		}
+	}
+
+	if resp.Password == "" {
+		return nil, fmt.Errorf("no git credential for %s on the host", req.Host)
+	}
+
+	return resp, nil
+}
@@ -7,10 +7,14 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"time"
 
+	"github.com/0xa1bed0/mkenv/internal/audit"
 	"github.com/0xa1bed0/mkenv/internal/guardrails"
 	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/mux"
 	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
 	"github.com/0xa1bed0/mkenv/internal/networking/transport"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
 )
@@ -25,25 +29,64 @@ func (c *bufferedConn) Read(p []byte) (int, error) {
 	return c.r.Read(p)
 }
 
+// CloseWrite half-closes the wrapped connection if it supports it.
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
 // ReverseProxyServer listens on a random host port and proxies container requests
 // to host services. This enables containers to access host services (e.g., postgres)
 // via localhost from inside the container.
+//
+// In "ask" mode of the policy, the user picks whether the sandbox can reach a host port the first time it is dialed.
+// Project-wide answers are remembered in trust, the others until the server stops.
 type ReverseProxyServer struct {
-	addr   string
-	policy guardrails.Policy
-	srv    *transport.Server
-	once   sync.Once
+	rt       *runtime.Runtime
+	addr     string
+	policy   guardrails.Policy
+	trust    *guardrails.PortTrust
+	auditLog *audit.Log
+	srv      *transport.Server
+	once     sync.Once
+
+	mu        sync.Mutex
+	decisions map[int]bool          // port => allowed, answered during this run
+	pending   map[int]chan struct{} // port => closed when the user answers
+}
+
+// Answers to the "ask" mode prompt.
+const (
+	portChoiceOnce        = "once"
+	portChoiceProject     = "project"
+	portChoiceDeny        = "deny"
+	portChoiceDenyProject = "deny-project"
+)
+
+var portChoices = []runtime.Choice{
+	{ID: portChoiceOnce, Label: "Allow once (until mkenv stops)", Key: "o"},
+	{ID: portChoiceProject, Label: "Allow for this project", Key: "p"},
+	{ID: portChoiceDeny, Label: "Deny once (until mkenv stops)", Key: "d"},
+	{ID: portChoiceDenyProject, Label: "Deny for this project", Key: "n"},
 }
 
 // StartReverseProxyServer creates and starts a reverse proxy server on a random port.
 // The container will dial this port when it wants to access host services.
-func StartReverseProxyServer(rt *runtime.Runtime, policy guardrails.Policy) (*ReverseProxyServer, error) {
+// Every connection is recorded in auditLog.
+func StartReverseProxyServer(rt *runtime.Runtime, policy guardrails.Policy, trust *guardrails.PortTrust, auditLog *audit.Log) (*ReverseProxyServer, error) {
 	rps := &ReverseProxyServer{
-		policy: policy,
+		rt:        rt,
+		policy:    policy,
+		trust:     trust,
+		auditLog:  auditLog,
+		decisions: map[int]bool{},
+		pending:   map[int]chan struct{}{},
 	}
 
 	server, err := transport.ServeTCP(rt, "127.0.0.1:0", func(ctx context.Context, conn net.Conn) {
-		rps.handleConn(conn)
+		rps.handleConn(rt, conn)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("reverse proxy serve: %w", err)
@@ -83,33 +126,85 @@ func (rps *ReverseProxyServer) Port() int {
 	return port
 }
 
-// handleConn processes a single reverse proxy connection from the container
-func (rps *ReverseProxyServer) handleConn(clientConn net.Conn) {
+// handleConn processes a single reverse proxy connection from the container.
+// The connection is either multiplexed (many streams, each with its own header) or carries a single header.
+func (rps *ReverseProxyServer) handleConn(rt *runtime.Runtime, clientConn net.Conn) {
 	defer clientConn.Close()
 
 	remote := clientConn.RemoteAddr().String()
 	r := bufio.NewReader(clientConn)
 
+	isMux, err := mux.IsPreamble(r)
+	if err != nil {
+		logs.Errorf("reverse proxy: bad header from %s: %v", remote, err)
+		return
+	}
+	if !isMux {
+		rps.proxy(remote, r, clientConn)
+		return
+	}
+
+	session := mux.Server(clientConn, r)
+	defer session.Close()
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			return
+		}
+		rt.GoNamed(fmt.Sprintf("ReverseProxy;%s;stream %d", remote, stream.ID()), func() {
+			defer stream.Close()
+			rps.proxy(remote, bufio.NewReader(stream), stream)
+		})
+	}
+}
+
+// proxy reads the proxy header from r and pumps clientConn to the requested host port if policy allows it.
+func (rps *ReverseProxyServer) proxy(remote string, r *bufio.Reader, clientConn net.Conn) {
 	// Read the proxy header: "PORT 5432\n"
-	port, err := protocol.ReadProxyHeader(r)
+	proto, port, origin, err := protocol.ReadProxyHeaderWithOrigin(r)
 	if err != nil {
 		logs.Errorf("reverse proxy: bad header from %s: %v", remote, err)
 		return
 	}
+	if proto != shared.ProtoTCP {
+		logs.Warnf("reverse proxy: %s is not supported (port %d from %s)", proto, port, remote)
+		return
+	}
+
+	rec := audit.Record{
+		Direction: audit.DirectionSandboxToHost,
+		Proto:     proto,
+		Port:      port,
+		Remote:    remote,
+		Start:     time.Now(),
+		Decision:  audit.DecisionAllow,
+		PID:       origin.PID,
+		Cmd:       origin.Cmd,
+	}
+	defer func() {
+		if err := rps.auditLog.Write(rec); err != nil {
+			logs.Warnf("reverse proxy: can't write audit log: %v", err)
+		}
+	}()
 
 	// CRITICAL: Check policy - this enforces hardcoded denials + custom policy
-	if !rps.policy.AllowReverseProxy(port) {
-		logs.Warnf("reverse proxy: port %d denied by policy (from %s)", port, remote)
+	allowed, reason := rps.allow(port, origin)
+	rec.Reason = reason
+	if !allowed {
+		logs.Warnf("reverse proxy: port %d denied by %s (from %s)", port, reason, remote)
+		rec.Decision = audit.DecisionDeny
 		return
 	}
 
 	// Dial the host service
 	targetAddr := fmt.Sprintf("localhost:%d", port)
-	backendConn, err := net.Dial("tcp", targetAddr)
+	dialed, err := net.Dial("tcp", targetAddr)
 	if err != nil {
 		logs.Errorf("reverse proxy: can't dial %s for %s: %v", targetAddr, remote, err)
+		rec.Error = err.Error()
 		return
 	}
+	backendConn := audit.NewCountingConn(dialed)
 	defer backendConn.Close()
 
 	logs.InfofSilent("reverse proxy: container -> host:%d (start)", port)
@@ -122,5 +217,77 @@ func (rps *ReverseProxyServer) handleConn(clientConn net.Conn) {
 
 	protocol.PumpBidirectional(client, backendConn)
 
+	rec.BytesFromSandbox = backendConn.BytesWritten()
+	rec.BytesToSandbox = backendConn.BytesRead()
+
 	logs.InfofSilent("reverse proxy: container -> host:%d (done)", port)
 }
+
+// allow returns true if the sandbox can reach the host port, and what decided it.
+// In "ask" mode unknown ports are offered to the user, concurrent connections to the same port wait for a single answer.
+func (rps *ReverseProxyServer) allow(port int, origin protocol.Origin) (bool, string) {
+	if !rps.policy.AllowReverseProxy(port) {
+		return false, "policy"
+	}
+	if !rps.policy.ReverseProxyAsk() {
+		return true, "policy"
+	}
+
+	ctx := rps.rt.Ctx()
+
+	decision, found, err := rps.trust.Get(ctx, port)
+	if err != nil {
+		logs.Warnf("reverse proxy: can't read trusted ports: %v", err)
+	}
+	if found {
+		return decision == guardrails.PortAllow, "project trust"
+	}
+
+	for {
+		rps.mu.Lock()
+		if approved, ok := rps.decisions[port]; ok {
+			rps.mu.Unlock()
+			return approved, "user"
+		}
+		if wait, ok := rps.pending[port]; ok {
+			rps.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return false, "user"
+			}
+		}
+		done := make(chan struct{})
+		rps.pending[port] = done
+		rps.mu.Unlock()
+
+		who := "The sandbox"
+		if origin.PID != 0 {
+			who = fmt.Sprintf("The sandbox process %q (pid %d, reported by the sandbox)", origin.Cmd, origin.PID)
+		}
+		choice := rps.rt.Choose(ctx, fmt.Sprintf("%s wants to connect to host port %d.", who, port), portChoices)
+
+		approved := choice == portChoiceOnce || choice == portChoiceProject
+		if choice == portChoiceProject || choice == portChoiceDenyProject {
+			decision := guardrails.PortDeny
+			if approved {
+				decision = guardrails.PortAllow
+			}
+			if err := rps.trust.Set(ctx, port, decision); err != nil {
+				logs.Warnf("reverse proxy: can't remember port %d: %v", port, err)
+			}
+		}
+
+		rps.mu.Lock()
+		// no answer (nobody attached, timeout) is not a decision, the next connection asks again
+		if choice != "" {
+			rps.decisions[port] = approved
+		}
+		delete(rps.pending, port)
+		rps.mu.Unlock()
+		close(done)
+
+		return approved, "user"
+	}
+}
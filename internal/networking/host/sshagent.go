@@ -0,0 +1,103 @@
+package host
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+)
+
+// SSH agent protocol message types we care about (draft-miller-ssh-agent).
+const (
+	sshAgentcRequestIdentities = 11
+	sshAgentcSignRequest       = 13
+)
+
+// sshAgentDialTimeout is how long we wait for the host agent to accept the connection.
+const sshAgentDialTimeout = 5 * time.Second
+
+// ForwardSSHAgentMessage forwards a single SSH agent request from the sandbox to the host agent
+// ($SSH_AUTH_SOCK). Only listing identities and signing are allowed, every signature must be
+// approved. Keys never leave the host agent: the sandbox gets public keys and signatures only.
+func ForwardSSHAgentMessage(ctx context.Context, msg []byte, approve func(ctx context.Context, text string) bool) ([]byte, error) {
+	if len(msg) == 0 {
+		return nil, errors.New("empty ssh agent message")
+	}
+
+	switch msg[0] {
+	case sshAgentcRequestIdentities:
+		// public keys only, no approval needed
+	case sshAgentcSignRequest:
+		keyType, fingerprint, err := parseSignRequestKey(msg)
+		if err != nil {
+			return nil, err
+		}
+		if !approve(ctx, fmt.Sprintf("Allow sandbox to sign with SSH key %s %s?", keyType, fingerprint)) {
+			return shared.SSHAgentFailure, nil
+		}
+	default:
+		// adding/removing keys, locking the agent, extensions, etc. are not for the sandbox
+		return shared.SSHAgentFailure, nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH_AUTH_SOCK is not set on the host")
+	}
+
+	dialer := net.Dialer{Timeout: sshAgentDialTimeout}
+	conn, err := dialer.DialContext(ctx, "unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("dial host ssh agent: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if err := shared.WriteSSHAgentMessage(conn, msg); err != nil {
+		return nil, err
+	}
+
+	return shared.ReadSSHAgentMessage(conn)
+}
+
+// parseSignRequestKey returns type and SHA256 fingerprint of the key a sign request is for.
+//
+//	byte   SSH_AGENTC_SIGN_REQUEST
+//	string key blob
+//	string data
+//	uint32 flags
+func parseSignRequestKey(msg []byte) (keyType, fingerprint string, err error) {
+	blob, _, ok := readSSHString(msg[1:])
+	if !ok {
+		return "", "", errors.New("malformed ssh agent sign request")
+	}
+
+	typ, _, ok := readSSHString(blob)
+	if !ok {
+		return "", "", errors.New("malformed ssh public key")
+	}
+
+	sum := sha256.Sum256(blob)
+	return string(typ), "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+func readSSHString(b []byte) (value, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(n) {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
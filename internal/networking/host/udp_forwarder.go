@@ -0,0 +1,261 @@
+package host
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/audit"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+	"github.com/0xa1bed0/mkenv/internal/networking/transport"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+)
+
+// udpSessionIdleTimeout is how long a UDP session lives without traffic in either direction.
+const udpSessionIdleTimeout = 2 * time.Minute
+
+// UDPForwarder forwards datagrams sent to localhost:TargetPort to the same port in the container.
+// UDP has no connections, so every client address gets its own session: a Tunnel stream to the
+// container proxy (localhost:ContainerProxyPort) carrying framed datagrams both ways.
+// Sessions are closed after udpSessionIdleTimeout without traffic.
+type UDPForwarder struct {
+	TargetPort         int
+	ContainerProxyPort int
+	Tunnel             *transport.MuxDialer
+	AuditLog           *audit.Log
+
+	conn   *net.UDPConn
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	sessions map[string]*udpSession // key = client address
+
+	once sync.Once
+}
+
+type udpSession struct {
+	clientAddr *net.UDPAddr
+	stream     net.Conn
+	start      time.Time
+
+	mu               sync.Mutex
+	lastActive       time.Time
+	bytesToSandbox   int64
+	bytesFromSandbox int64
+}
+
+// touch marks the session active after toSandbox/fromSandbox bytes went through it.
+func (s *udpSession) touch(toSandbox, fromSandbox int) {
+	s.mu.Lock()
+	s.lastActive = time.Now()
+	s.bytesToSandbox += int64(toSandbox)
+	s.bytesFromSandbox += int64(fromSandbox)
+	s.mu.Unlock()
+}
+
+func (s *udpSession) idleSince() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastActive
+}
+
+func (f *UDPForwarder) Start(rt *runtime.Runtime) error {
+	addr := fmt.Sprintf("0.0.0.0:%d", f.TargetPort)
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+
+	f.conn = conn
+	f.sessions = map[string]*udpSession{}
+	f.ctx, f.cancel = context.WithCancel(rt.Ctx())
+
+	rt.GoNamed(fmt.Sprintf("UDPForwarder;%d;read loop", f.TargetPort), func() {
+		f.readLoop(rt)
+	})
+	rt.GoNamed(fmt.Sprintf("UDPForwarder;%d;expire sessions", f.TargetPort), func() {
+		f.expireLoop()
+	})
+
+	logs.Debugf("udp forwarder listening on %s -> container:%d (via proxy at %d)", addr, f.TargetPort, f.ContainerProxyPort)
+	return nil
+}
+
+func (f *UDPForwarder) Stop() {
+	f.once.Do(func() {
+		logs.Debugf("Stopping udp forwarder %d", f.TargetPort)
+		if f.cancel != nil {
+			f.cancel()
+		}
+		if f.conn != nil {
+			_ = f.conn.Close()
+		}
+
+		f.mu.Lock()
+		closed := make([]*udpSession, 0, len(f.sessions))
+		for key, session := range f.sessions {
+			_ = session.stream.Close()
+			delete(f.sessions, key)
+			closed = append(closed, session)
+		}
+		f.mu.Unlock()
+
+		for _, session := range closed {
+			f.audit(session)
+		}
+	})
+}
+
+func (f *UDPForwarder) readLoop(rt *runtime.Runtime) {
+	buf := make([]byte, protocol.MaxDatagramSize)
+	for {
+		n, clientAddr, err := f.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || f.ctx.Err() != nil {
+				return
+			}
+			logs.Debugf("udp forwarder %d: read: %v", f.TargetPort, err)
+			continue
+		}
+
+		session, err := f.session(rt, clientAddr)
+		if err != nil {
+			logs.Errorf("udp forwarder: can't open session for %s on %d: %v", clientAddr, f.TargetPort, err)
+			continue
+		}
+
+		session.touch(n, 0)
+		if err := protocol.WriteDatagram(session.stream, buf[:n]); err != nil {
+			logs.Debugf("udp forwarder %d: write to container: %v", f.TargetPort, err)
+			f.closeSession(clientAddr.String())
+		}
+	}
+}
+
+// session returns the session of the client, opening a new one if needed.
+func (f *UDPForwarder) session(rt *runtime.Runtime, clientAddr *net.UDPAddr) (*udpSession, error) {
+	key := clientAddr.String()
+
+	f.mu.Lock()
+	session, ok := f.sessions[key]
+	f.mu.Unlock()
+	if ok {
+		return session, nil
+	}
+
+	stream, err := f.Tunnel.Dial(f.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := protocol.WriteUDPProxyHeader(stream, f.TargetPort); err != nil {
+		stream.Close()
+		return nil, err
+	}
+
+	session = &udpSession{
+		clientAddr: clientAddr,
+		stream:     stream,
+		start:      time.Now(),
+		lastActive: time.Now(),
+	}
+
+	f.mu.Lock()
+	f.sessions[key] = session
+	f.mu.Unlock()
+
+	logs.Debugf("udp forwarder %d: session for %s opened", f.TargetPort, key)
+
+	rt.GoNamed(fmt.Sprintf("UDPForwarder;%d;session %s", f.TargetPort, key), func() {
+		f.pumpReplies(session)
+		f.closeSession(key)
+	})
+
+	return session, nil
+}
+
+// pumpReplies sends datagrams coming from the container back to the client.
+func (f *UDPForwarder) pumpReplies(session *udpSession) {
+	r := bufio.NewReader(session.stream)
+	buf := make([]byte, protocol.MaxDatagramSize)
+	for {
+		n, err := protocol.ReadDatagram(r, buf)
+		if err != nil {
+			return
+		}
+		session.touch(0, n)
+		if _, err := f.conn.WriteToUDP(buf[:n], session.clientAddr); err != nil {
+			logs.Debugf("udp forwarder %d: write to %s: %v", f.TargetPort, session.clientAddr, err)
+			return
+		}
+	}
+}
+
+func (f *UDPForwarder) closeSession(key string) {
+	f.mu.Lock()
+	session, ok := f.sessions[key]
+	delete(f.sessions, key)
+	f.mu.Unlock()
+
+	if ok {
+		_ = session.stream.Close()
+		logs.Debugf("udp forwarder %d: session for %s closed", f.TargetPort, key)
+		f.audit(session)
+	}
+}
+
+func (f *UDPForwarder) audit(session *udpSession) {
+	session.mu.Lock()
+	rec := audit.Record{
+		Direction:        audit.DirectionHostToSandbox,
+		Proto:            shared.ProtoUDP,
+		Port:             f.TargetPort,
+		Remote:           session.clientAddr.String(),
+		Start:            session.start,
+		BytesToSandbox:   session.bytesToSandbox,
+		BytesFromSandbox: session.bytesFromSandbox,
+		Decision:         audit.DecisionAllow,
+	}
+	session.mu.Unlock()
+
+	if err := f.AuditLog.Write(rec); err != nil {
+		logs.Warnf("udp forwarder: can't write audit log: %v", err)
+	}
+}
+
+func (f *UDPForwarder) expireLoop() {
+	ticker := time.NewTicker(udpSessionIdleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.ctx.Done():
+			return
+		case <-ticker.C:
+			expired := []string{}
+			f.mu.Lock()
+			for key, session := range f.sessions {
+				if time.Since(session.idleSince()) > udpSessionIdleTimeout {
+					expired = append(expired, key)
+				}
+			}
+			f.mu.Unlock()
+
+			for _, key := range expired {
+				f.closeSession(key)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package mux
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// preamble is sent once by the client before any frame, so servers that also accept
+// legacy "PORT <port>\n" connections can tell the two apart by the first line.
+const preamble = "MUX 1\n"
+
+// Frame layout (big endian), the same shape yamux uses:
+//
+//	uint8  version
+//	uint8  type
+//	uint16 flags
+//	uint32 stream id
+//	uint32 length (payload size for data frames, window delta for window updates)
+const (
+	frameVersion    uint8 = 0
+	frameHeaderSize       = 12
+
+	typeData         uint8 = 0
+	typeWindowUpdate uint8 = 1
+	typeGoAway       uint8 = 2
+
+	flagSYN uint16 = 1 << 0 // opens the stream
+	flagFIN uint16 = 1 << 1 // sender will not write anymore
+	flagRST uint16 = 1 << 2 // stream is aborted
+)
+
+const (
+	// initialWindow is how many bytes a peer may send on a stream before it gets a window update.
+	initialWindow = 256 * 1024
+	// maxFramePayload splits large writes so a single stream can't hog the connection.
+	maxFramePayload = 16 * 1024
+)
+
+type frameHeader [frameHeaderSize]byte
+
+func newFrameHeader(typ uint8, flags uint16, streamID, length uint32) frameHeader {
+	var h frameHeader
+	h[0] = frameVersion
+	h[1] = typ
+	binary.BigEndian.PutUint16(h[2:4], flags)
+	binary.BigEndian.PutUint32(h[4:8], streamID)
+	binary.BigEndian.PutUint32(h[8:12], length)
+	return h
+}
+
+func (h frameHeader) version() uint8    { return h[0] }
+func (h frameHeader) typ() uint8        { return h[1] }
+func (h frameHeader) flags() uint16     { return binary.BigEndian.Uint16(h[2:4]) }
+func (h frameHeader) streamID() uint32  { return binary.BigEndian.Uint32(h[4:8]) }
+func (h frameHeader) length() uint32    { return binary.BigEndian.Uint32(h[8:12]) }
+func (h frameHeader) has(f uint16) bool { return h.flags()&f != 0 }
+
+func readFrameHeader(r io.Reader) (frameHeader, error) {
+	var h frameHeader
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return h, err
+	}
+	if h.version() != frameVersion {
+		return h, fmt.Errorf("mux: unsupported frame version %d", h.version())
+	}
+	return h, nil
+}
+
+// WritePreamble marks w as a multiplexed connection. Client does it, there is no need to call it directly.
+func WritePreamble(w io.Writer) error {
+	_, err := io.WriteString(w, preamble)
+	return err
+}
+
+// IsPreamble reports whether r starts with the mux preamble, consuming it if so.
+// Otherwise nothing is consumed and r can be read as a regular connection.
+func IsPreamble(r *bufio.Reader) (bool, error) {
+	// the shortest legacy header ("UDP 1\n") is as long as the preamble, so this never blocks on valid input
+	head, err := r.Peek(len(preamble))
+	if err != nil {
+		return false, err
+	}
+	if string(head) != preamble {
+		return false, nil
+	}
+	_, _ = r.Discard(len(preamble))
+	return true, nil
+}
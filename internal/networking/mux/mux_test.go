@@ -0,0 +1,420 @@
+package mux
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newSessionPair returns a client and a server session connected over loopback TCP.
+func newSessionPair(tb testing.TB) (*Session, *Session) {
+	tb.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	serverCh := make(chan *Session, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			serverCh <- nil
+			return
+		}
+		r := bufio.NewReader(conn)
+		if ok, err := IsPreamble(r); !ok || err != nil {
+			conn.Close()
+			serverCh <- nil
+			return
+		}
+		serverCh <- Server(conn, r)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatalf("dial: %v", err)
+	}
+	client, err := Client(conn)
+	if err != nil {
+		tb.Fatalf("client: %v", err)
+	}
+	server := <-serverCh
+	if server == nil {
+		tb.Fatal("server session was not established")
+	}
+
+	tb.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// serveEcho echoes every stream accepted by session until it is closed.
+func serveEcho(session *Session) {
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer stream.Close()
+			_, _ = io.Copy(stream, stream)
+			_ = stream.CloseWrite()
+		}()
+	}
+}
+
+func TestStreamEcho(t *testing.T) {
+	t.Parallel()
+
+	client, server := newSessionPair(t)
+	go serveEcho(server)
+
+	stream, err := client.Open()
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer stream.Close()
+
+	if _, err := stream.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite returned error: %v", err)
+	}
+
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("ReadAll returned error: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("echo = %q, want %q", got, "hello")
+	}
+}
+
+func TestLargeTransferRespectsWindow(t *testing.T) {
+	t.Parallel()
+
+	client, server := newSessionPair(t)
+	go serveEcho(server)
+
+	// several windows worth of data, so the transfer only completes if window updates flow
+	payload := make([]byte, 8*initialWindow+123)
+	if _, err := rand.Read(payload); err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+
+	stream, err := client.Open()
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer stream.Close()
+
+	go func() {
+		_, _ = stream.Write(payload)
+		_ = stream.CloseWrite()
+	}()
+
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("ReadAll returned error: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("echoed %d bytes, want the same %d bytes", len(got), len(payload))
+	}
+}
+
+func TestManyConcurrentStreams(t *testing.T) {
+	t.Parallel()
+
+	client, server := newSessionPair(t)
+	go serveEcho(server)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stream, err := client.Open()
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer stream.Close()
+
+			msg := bytes.Repeat([]byte{byte(i)}, 10*1024)
+			if _, err := stream.Write(msg); err != nil {
+				errs <- err
+				return
+			}
+			_ = stream.CloseWrite()
+
+			got, err := io.ReadAll(stream)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(got, msg) {
+				errs <- errors.New("stream got another stream's data")
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamsAreIndependent(t *testing.T) {
+	t.Parallel()
+
+	client, server := newSessionPair(t)
+
+	// nobody reads the first stream on the server side: its window fills up, the second stream must still work
+	stalled, err := client.Open()
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer stalled.Close()
+	go func() {
+		_, _ = stalled.Write(make([]byte, 4*initialWindow))
+	}()
+	if _, err := server.Accept(); err != nil {
+		t.Fatalf("Accept returned error: %v", err)
+	}
+
+	go serveEcho(server)
+
+	stream, err := client.Open()
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := stream.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		t.Fatalf("ReadFull returned error: %v", err)
+	}
+}
+
+func TestCloseResetsPeer(t *testing.T) {
+	t.Parallel()
+
+	client, server := newSessionPair(t)
+
+	stream, err := client.Open()
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	accepted, err := server.Accept()
+	if err != nil {
+		t.Fatalf("Accept returned error: %v", err)
+	}
+
+	if err := accepted.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	_ = stream.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := stream.Read(make([]byte, 1)); !errors.Is(err, ErrStreamReset) {
+		t.Fatalf("Read error = %v, want %v", err, ErrStreamReset)
+	}
+}
+
+func TestSessionCloseUnblocksStreams(t *testing.T) {
+	t.Parallel()
+
+	client, server := newSessionPair(t)
+
+	stream, err := client.Open()
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := stream.Read(make([]byte, 1))
+		readErr <- err
+	}()
+
+	server.Close()
+
+	select {
+	case err := <-readErr:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Read error = %v, want net.ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read is still blocked after the session was closed")
+	}
+}
+
+func TestReadDeadline(t *testing.T) {
+	t.Parallel()
+
+	client, _ := newSessionPair(t)
+
+	stream, err := client.Open()
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer stream.Close()
+
+	_ = stream.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	var netErr net.Error
+	if _, err := stream.Read(make([]byte, 1)); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("Read error = %v, want a timeout", err)
+	}
+}
+
+func TestIsPreambleLeavesLegacyHeader(t *testing.T) {
+	t.Parallel()
+
+	for _, header := range []string{"PORT 3000\n", "UDP 5\n"} {
+		r := bufio.NewReader(bytes.NewBufferString(header))
+		ok, err := IsPreamble(r)
+		if err != nil || ok {
+			t.Fatalf("IsPreamble(%q) = %v, %v; want false, nil", header, ok, err)
+		}
+		rest, _ := io.ReadAll(r)
+		if string(rest) != header {
+			t.Fatalf("IsPreamble consumed %q, left %q", header, rest)
+		}
+	}
+}
+
+// Benchmarks compare a TCP connection per forwarded connection (what forwarders did before)
+// with a stream per forwarded connection over one persistent session.
+//
+//	go test ./internal/networking/mux -bench . -benchmem
+
+const benchMessageSize = 1024
+
+func startTCPEcho(b *testing.B) string {
+	b.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatalf("listen: %v", err)
+	}
+	b.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func roundTrip(conn net.Conn, msg, buf []byte) error {
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+	_, err := io.ReadFull(conn, buf)
+	return err
+}
+
+func BenchmarkConnPerRequestTCP(b *testing.B) {
+	addr := startTCPEcho(b)
+	msg := make([]byte, benchMessageSize)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		buf := make([]byte, benchMessageSize)
+		for pb.Next() {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				b.Errorf("dial: %v", err)
+				return
+			}
+			err = roundTrip(conn, msg, buf)
+			conn.Close()
+			if err != nil {
+				b.Errorf("round trip: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkConnPerRequestMux(b *testing.B) {
+	client, server := newSessionPair(b)
+	go serveEcho(server)
+	msg := make([]byte, benchMessageSize)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		buf := make([]byte, benchMessageSize)
+		for pb.Next() {
+			stream, err := client.Open()
+			if err != nil {
+				b.Errorf("open: %v", err)
+				return
+			}
+			err = roundTrip(stream, msg, buf)
+			stream.Close()
+			if err != nil {
+				b.Errorf("round trip: %v", err)
+				return
+			}
+		}
+	})
+}
+
+func benchmarkThroughput(b *testing.B, conn net.Conn) {
+	chunk := make([]byte, 32*1024)
+	buf := make([]byte, len(chunk))
+
+	b.SetBytes(int64(len(chunk)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := roundTrip(conn, chunk, buf); err != nil {
+			b.Fatalf("round trip: %v", err)
+		}
+	}
+}
+
+func BenchmarkThroughputTCP(b *testing.B) {
+	conn, err := net.Dial("tcp", startTCPEcho(b))
+	if err != nil {
+		b.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	benchmarkThroughput(b, conn)
+}
+
+func BenchmarkThroughputMux(b *testing.B) {
+	client, server := newSessionPair(b)
+	go serveEcho(server)
+
+	stream, err := client.Open()
+	if err != nil {
+		b.Fatalf("open: %v", err)
+	}
+	defer stream.Close()
+	benchmarkThroughput(b, stream)
+}
@@ -0,0 +1,282 @@
+// Package mux carries many logical streams over a single connection.
+//
+// It is a small yamux-style protocol: every stream is a sequence of frames tagged with the stream
+// id, and each direction of a stream has its own flow control window, so a slow reader on one
+// stream never blocks the others. Forwarders use it to avoid dialing a new TCP connection
+// for every forwarded client connection.
+package mux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+var (
+	// ErrSessionClosed is returned by streams of a session whose underlying connection is gone.
+	ErrSessionClosed = fmt.Errorf("mux: session closed: %w", net.ErrClosed)
+	// ErrStreamClosed is returned when using a stream after Close (or writing after CloseWrite).
+	ErrStreamClosed = fmt.Errorf("mux: stream closed: %w", net.ErrClosed)
+	// ErrStreamReset is returned when the other side aborted the stream.
+	ErrStreamReset = errors.New("mux: stream reset by peer")
+)
+
+// acceptBacklog is how many opened streams may wait for Accept before new ones are reset.
+const acceptBacklog = 256
+
+// Session is one end of a multiplexed connection.
+type Session struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	streams map[uint32]*Stream
+	nextID  uint32
+	err     error
+
+	acceptCh  chan *Stream
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Client starts a session on conn as the dialing side. It writes the preamble, so conn must be fresh.
+func Client(conn net.Conn) (*Session, error) {
+	if err := WritePreamble(conn); err != nil {
+		return nil, err
+	}
+	return newSession(conn, bufio.NewReader(conn), 1), nil
+}
+
+// Server starts a session on conn as the accepting side. r must read from conn and have the
+// preamble already consumed (see IsPreamble).
+func Server(conn net.Conn, r *bufio.Reader) *Session {
+	return newSession(conn, r, 2)
+}
+
+func newSession(conn net.Conn, r *bufio.Reader, firstID uint32) *Session {
+	s := &Session{
+		conn:     conn,
+		reader:   r,
+		streams:  map[uint32]*Stream{},
+		nextID:   firstID,
+		acceptCh: make(chan *Stream, acceptBacklog),
+		done:     make(chan struct{}),
+	}
+	go s.recvLoop()
+	return s
+}
+
+// Open opens a new stream. It does not wait for the other side to accept it.
+func (s *Session) Open() (*Stream, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil, s.err
+	}
+	id := s.nextID
+	// client streams are odd, server streams are even, so ids never collide
+	s.nextID += 2
+	stream := newStream(s, id)
+	s.streams[id] = stream
+	s.mu.Unlock()
+
+	if err := s.writeFrame(typeWindowUpdate, flagSYN, id, 0, nil); err != nil {
+		s.removeStream(id)
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Accept waits for the next stream opened by the other side.
+func (s *Session) Accept() (*Stream, error) {
+	select {
+	case stream := <-s.acceptCh:
+		return stream, nil
+	case <-s.done:
+		return nil, s.closeErr()
+	}
+}
+
+// NumStreams returns the number of streams currently open.
+func (s *Session) NumStreams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams)
+}
+
+// Done is closed when the session is closed.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// IsClosed reports whether the session is closed.
+func (s *Session) IsClosed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close tells the other side the session is going away and closes the connection with all its streams.
+func (s *Session) Close() error {
+	if s.IsClosed() {
+		return nil
+	}
+	_ = s.writeFrame(typeGoAway, 0, 0, 0, nil)
+	s.closeWithErr(ErrSessionClosed)
+	return nil
+}
+
+func (s *Session) closeWithErr(err error) {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		streams := s.streams
+		s.streams = map[uint32]*Stream{}
+		s.mu.Unlock()
+
+		close(s.done)
+		_ = s.conn.Close()
+
+		for _, stream := range streams {
+			stream.notifyRead()
+			stream.notifyWrite()
+		}
+	})
+}
+
+func (s *Session) closeErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		return ErrSessionClosed
+	}
+	return s.err
+}
+
+func (s *Session) removeStream(id uint32) {
+	s.mu.Lock()
+	delete(s.streams, id)
+	s.mu.Unlock()
+}
+
+// writeFrame writes a frame atomically. Frames of different streams are interleaved frame by frame.
+func (s *Session) writeFrame(typ uint8, flags uint16, id, length uint32, payload []byte) error {
+	header := newFrameHeader(typ, flags, id, length)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if s.IsClosed() {
+		return s.closeErr()
+	}
+
+	bufs := net.Buffers{header[:]}
+	if len(payload) > 0 {
+		bufs = append(bufs, payload)
+	}
+	if _, err := bufs.WriteTo(s.conn); err != nil {
+		s.closeWithErr(fmt.Errorf("mux: write: %w", err))
+		return s.closeErr()
+	}
+	return nil
+}
+
+func (s *Session) recvLoop() {
+	for {
+		header, err := readFrameHeader(s.reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				s.closeWithErr(ErrSessionClosed)
+			} else {
+				s.closeWithErr(fmt.Errorf("mux: read: %w", err))
+			}
+			return
+		}
+
+		switch header.typ() {
+		case typeData, typeWindowUpdate:
+			err = s.handleStreamFrame(header)
+		case typeGoAway:
+			s.closeWithErr(ErrSessionClosed)
+			return
+		default:
+			err = fmt.Errorf("mux: unknown frame type %d", header.typ())
+		}
+
+		if err != nil {
+			s.closeWithErr(err)
+			return
+		}
+	}
+}
+
+func (s *Session) handleStreamFrame(header frameHeader) error {
+	id := header.streamID()
+
+	if header.has(flagSYN) {
+		if err := s.acceptStream(id); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	stream := s.streams[id]
+	s.mu.Unlock()
+
+	if header.typ() == typeData {
+		length := header.length()
+		if stream == nil {
+			// the stream is already closed locally, drop whatever was in flight
+			_, err := s.reader.Discard(int(length))
+			return err
+		}
+		if err := stream.receive(s.reader, length); err != nil {
+			return err
+		}
+	} else if stream != nil && header.length() > 0 {
+		stream.grantSendWindow(header.length())
+	}
+
+	if stream == nil {
+		return nil
+	}
+	if header.has(flagRST) {
+		stream.remoteReset()
+		s.removeStream(id)
+	} else if header.has(flagFIN) {
+		stream.remoteClose()
+	}
+	return nil
+}
+
+func (s *Session) acceptStream(id uint32) error {
+	s.mu.Lock()
+	if id%2 == s.nextID%2 {
+		s.mu.Unlock()
+		return fmt.Errorf("mux: peer opened stream %d with our id parity", id)
+	}
+	if _, ok := s.streams[id]; ok {
+		s.mu.Unlock()
+		return fmt.Errorf("mux: duplicate stream %d", id)
+	}
+	stream := newStream(s, id)
+	s.streams[id] = stream
+	s.mu.Unlock()
+
+	select {
+	case s.acceptCh <- stream:
+	default:
+		// nobody accepts fast enough, refuse the stream instead of stalling the whole session
+		s.removeStream(id)
+		go s.writeFrame(typeWindowUpdate, flagRST, id, 0, nil)
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package mux
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stream is a logical connection inside a Session. It implements net.Conn.
+type Stream struct {
+	id      uint32
+	session *Session
+
+	mu sync.Mutex
+
+	recvBuf    bytes.Buffer
+	recvWindow uint32 // how much more the peer may send before we grant more
+	consumed   uint32 // read by the user since the last window update we sent
+	sendWindow uint32 // how much more we may send before the peer grants more
+
+	localFIN  bool // we won't write anymore
+	remoteFIN bool // peer won't write anymore
+	reset     bool // peer aborted the stream
+	closed    bool // Close was called
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	readReady  chan struct{}
+	writeReady chan struct{}
+}
+
+func newStream(session *Session, id uint32) *Stream {
+	return &Stream{
+		id:         id,
+		session:    session,
+		recvWindow: initialWindow,
+		sendWindow: initialWindow,
+		readReady:  make(chan struct{}, 1),
+		writeReady: make(chan struct{}, 1),
+	}
+}
+
+// ID returns the stream id, unique within the session.
+func (st *Stream) ID() uint32 {
+	return st.id
+}
+
+func (st *Stream) Read(p []byte) (int, error) {
+	for {
+		st.mu.Lock()
+		if st.recvBuf.Len() > 0 {
+			n, _ := st.recvBuf.Read(p)
+			update := st.consumeLocked(uint32(n))
+			st.mu.Unlock()
+
+			if update > 0 {
+				// best effort: if the session is gone the next read reports it
+				_ = st.session.writeFrame(typeWindowUpdate, 0, st.id, update, nil)
+			}
+			return n, nil
+		}
+
+		switch {
+		case st.reset:
+			st.mu.Unlock()
+			return 0, ErrStreamReset
+		case st.remoteFIN:
+			st.mu.Unlock()
+			return 0, io.EOF
+		case st.closed:
+			st.mu.Unlock()
+			return 0, ErrStreamClosed
+		}
+		deadline := st.readDeadline
+		st.mu.Unlock()
+
+		if err := st.wait(st.readReady, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// consumeLocked accounts n bytes read by the user and returns the window to grant back to the peer, if any.
+// Updates are batched to half a window so small reads don't produce a frame each.
+func (st *Stream) consumeLocked(n uint32) uint32 {
+	st.consumed += n
+	if st.consumed < initialWindow/2 || st.remoteFIN || st.closed {
+		return 0
+	}
+	update := st.consumed
+	st.consumed = 0
+	st.recvWindow += update
+	return update
+}
+
+func (st *Stream) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		st.mu.Lock()
+		switch {
+		case st.reset:
+			st.mu.Unlock()
+			return written, ErrStreamReset
+		case st.localFIN || st.closed:
+			st.mu.Unlock()
+			return written, ErrStreamClosed
+		}
+
+		if st.sendWindow == 0 {
+			deadline := st.writeDeadline
+			st.mu.Unlock()
+			if err := st.wait(st.writeReady, deadline); err != nil {
+				return written, err
+			}
+			continue
+		}
+
+		n := min(uint32(len(p)-written), st.sendWindow, maxFramePayload)
+		st.sendWindow -= n
+		st.mu.Unlock()
+
+		if err := st.session.writeFrame(typeData, 0, st.id, n, p[written:written+int(n)]); err != nil {
+			return written, err
+		}
+		written += int(n)
+	}
+	return written, nil
+}
+
+// CloseWrite tells the peer we are done writing. Reading keeps working until the peer closes too.
+func (st *Stream) CloseWrite() error {
+	st.mu.Lock()
+	if st.localFIN || st.reset {
+		st.mu.Unlock()
+		return nil
+	}
+	st.localFIN = true
+	st.mu.Unlock()
+	st.notifyWrite()
+
+	return st.session.writeFrame(typeData, flagFIN, st.id, 0, nil)
+}
+
+// Close closes both directions. If the peer is still writing, the stream is reset so it stops.
+func (st *Stream) Close() error {
+	st.mu.Lock()
+	if st.closed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.closed = true
+	flags := uint16(0)
+	if !st.localFIN {
+		flags |= flagFIN
+	}
+	if !st.remoteFIN && !st.reset {
+		flags |= flagRST
+	}
+	st.localFIN = true
+	st.mu.Unlock()
+
+	st.notifyRead()
+	st.notifyWrite()
+	st.session.removeStream(st.id)
+
+	if flags == 0 {
+		return nil
+	}
+	if err := st.session.writeFrame(typeData, flags, st.id, 0, nil); err != nil && !st.session.IsClosed() {
+		return err
+	}
+	return nil
+}
+
+func (st *Stream) LocalAddr() net.Addr {
+	return st.session.conn.LocalAddr()
+}
+
+func (st *Stream) RemoteAddr() net.Addr {
+	return st.session.conn.RemoteAddr()
+}
+
+func (st *Stream) SetDeadline(t time.Time) error {
+	_ = st.SetReadDeadline(t)
+	return st.SetWriteDeadline(t)
+}
+
+func (st *Stream) SetReadDeadline(t time.Time) error {
+	st.mu.Lock()
+	st.readDeadline = t
+	st.mu.Unlock()
+	st.notifyRead()
+	return nil
+}
+
+func (st *Stream) SetWriteDeadline(t time.Time) error {
+	st.mu.Lock()
+	st.writeDeadline = t
+	st.mu.Unlock()
+	st.notifyWrite()
+	return nil
+}
+
+// receive reads a data frame payload of the stream from r. Called by the session read loop only.
+func (st *Stream) receive(r *bufio.Reader, length uint32) error {
+	if length == 0 {
+		return nil
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if length > st.recvWindow {
+		return fmt.Errorf("mux: stream %d received %d bytes over its window of %d", st.id, length, st.recvWindow)
+	}
+	st.recvWindow -= length
+
+	if st.closed {
+		// nobody will read it
+		_, err := r.Discard(int(length))
+		return err
+	}
+
+	if _, err := io.CopyN(&st.recvBuf, r, int64(length)); err != nil {
+		return err
+	}
+	st.notifyRead()
+	return nil
+}
+
+func (st *Stream) grantSendWindow(delta uint32) {
+	st.mu.Lock()
+	st.sendWindow += delta
+	st.mu.Unlock()
+	st.notifyWrite()
+}
+
+func (st *Stream) remoteClose() {
+	st.mu.Lock()
+	st.remoteFIN = true
+	done := st.closed
+	st.mu.Unlock()
+	st.notifyRead()
+
+	if done {
+		st.session.removeStream(st.id)
+	}
+}
+
+func (st *Stream) remoteReset() {
+	st.mu.Lock()
+	st.reset = true
+	st.mu.Unlock()
+	st.notifyRead()
+	st.notifyWrite()
+}
+
+func (st *Stream) notifyRead() {
+	select {
+	case st.readReady <- struct{}{}:
+	default:
+	}
+}
+
+func (st *Stream) notifyWrite() {
+	select {
+	case st.writeReady <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until ready is signalled, the session is closed or the deadline passes.
+func (st *Stream) wait(ready <-chan struct{}, deadline time.Time) error {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-st.session.done:
+		// let the caller drain what is already buffered before failing
+		select {
+		case <-ready:
+			return nil
+		default:
+		}
+		return st.session.closeErr()
+	case <-timeout:
+		return os.ErrDeadlineExceeded
+	}
+}
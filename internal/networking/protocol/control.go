@@ -87,6 +87,9 @@ type ControlConn struct {
 
 	// read-loop error
 	readErr atomic.Value // error
+
+	// done is closed once the connection is closed
+	done chan struct{}
 }
 
 // NewControlConn wraps a net.Conn and starts the read loop.
@@ -97,6 +100,7 @@ func NewControlConn(raw net.Conn) *ControlConn {
 		bw:      bufio.NewWriter(raw),
 		pending: make(map[string]chan ControlSignalEnvelope),
 		subs:    make(map[string][]chan ControlSignalEnvelope),
+		done:    make(chan struct{}),
 	}
 	go c.readLoop()
 	return c
@@ -107,6 +111,7 @@ func (c *ControlConn) Close() error {
 		return nil
 	}
 	_ = c.raw.Close()
+	close(c.done)
 
 	c.muPending.Lock()
 	for id, ch := range c.pending {
@@ -117,6 +122,11 @@ func (c *ControlConn) Close() error {
 	return nil
 }
 
+// Done returns a channel that is closed when the connection is closed by either side.
+func (c *ControlConn) Done() <-chan struct{} {
+	return c.done
+}
+
 func (c *ControlConn) Err() error {
 	v := c.readErr.Load()
 	if v == nil {
@@ -284,6 +294,15 @@ func (c *ControlConn) recvOne() (ControlSignalEnvelope, error) {
 	return env, nil
 }
 
+type ctxKeyControlConn struct{}
+
+// ControlConnFromContext returns the connection the request being handled came from.
+// It is available in ControlCommandHandler context only.
+func ControlConnFromContext(ctx context.Context) *ControlConn {
+	c, _ := ctx.Value(ctxKeyControlConn{}).(*ControlConn)
+	return c
+}
+
 // ControlCommandHandler is a control command handler
 // TODO: think of generic struct or interface
 type ControlCommandHandler func(ctx context.Context, req ControlSignalEnvelope) (any, error)
@@ -353,6 +372,13 @@ func (s *ControlServerProtocol) Serve() error {
 		s.agents[conn] = struct{}{}
 		s.muAgents.Unlock()
 
+		go func() {
+			<-conn.Done()
+			s.muAgents.Lock()
+			delete(s.agents, conn)
+			s.muAgents.Unlock()
+		}()
+
 		conn.OnMessage(func(env ControlSignalEnvelope) {
 			s.rt.GoNamed("ControlServer:DispatchEnvelope", func() {
 				s.dispatch(conn, env)
@@ -380,6 +406,7 @@ func (s *ControlServerProtocol) dispatch(c *ControlConn, env ControlSignalEnvelo
 	// TODO: make configurable
 	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Minute)
 	defer cancel()
+	ctx = context.WithValue(ctx, ctxKeyControlConn{}, c)
 
 	response, err := h(ctx, env)
 	if err != nil {
@@ -389,6 +416,7 @@ func (s *ControlServerProtocol) dispatch(c *ControlConn, env ControlSignalEnvelo
 			OK:   false,
 			Err:  err.Error(),
 		})
+		return
 	}
 
 	responseEnvelope, err := PackControlSignalEnvelope(env.ID, env.Type+".resp", response)
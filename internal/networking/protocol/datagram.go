@@ -0,0 +1,41 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MaxDatagramSize is the largest UDP payload we relay.
+const MaxDatagramSize = 65535
+
+// WriteDatagram writes a single datagram to a UDP session stream: 2 bytes length + payload.
+func WriteDatagram(w io.Writer, p []byte) error {
+	if len(p) > MaxDatagramSize {
+		return fmt.Errorf("datagram too large: %d", len(p))
+	}
+
+	buf := make([]byte, 2+len(p))
+	binary.BigEndian.PutUint16(buf, uint16(len(p)))
+	copy(buf[2:], p)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadDatagram reads a single datagram from a UDP session stream into buf
+// which must be at least MaxDatagramSize long.
+func ReadDatagram(r io.Reader, buf []byte) (int, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, err
+	}
+
+	n := int(binary.BigEndian.Uint16(hdr[:]))
+	if n > len(buf) {
+		return 0, fmt.Errorf("datagram too large: %d", n)
+	}
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
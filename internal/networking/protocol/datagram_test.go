@@ -0,0 +1,69 @@
+// Tests in this file exercise framing of UDP datagrams on a session stream.
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestDatagramFraming(t *testing.T) {
+	t.Parallel()
+
+	datagrams := [][]byte{
+		[]byte("hello"),
+		{},
+		bytes.Repeat([]byte{0xff}, 300),
+		bytes.Repeat([]byte{'x'}, MaxDatagramSize),
+	}
+
+	var stream bytes.Buffer
+	for _, d := range datagrams {
+		if err := WriteDatagram(&stream, d); err != nil {
+			t.Fatalf("WriteDatagram(%d bytes): %v", len(d), err)
+		}
+	}
+	// the length prefix is big endian
+	if got := stream.Bytes()[:7]; !bytes.Equal(got, []byte{0, 5, 'h', 'e', 'l', 'l', 'o'}) {
+		t.Fatalf("unexpected frame %v", got)
+	}
+
+	buf := make([]byte, MaxDatagramSize)
+	for _, want := range datagrams {
+		n, err := ReadDatagram(&stream, buf)
+		if err != nil {
+			t.Fatalf("ReadDatagram: %v", err)
+		}
+		if !bytes.Equal(buf[:n], want) {
+			t.Fatalf("ReadDatagram = %d bytes, want %d", n, len(want))
+		}
+	}
+	if _, err := ReadDatagram(&stream, buf); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestDatagramErrors(t *testing.T) {
+	t.Parallel()
+
+	var stream bytes.Buffer
+	if err := WriteDatagram(&stream, make([]byte, MaxDatagramSize+1)); err == nil {
+		t.Fatalf("expected an oversized datagram to fail")
+	}
+	if stream.Len() != 0 {
+		t.Fatalf("expected nothing to be written, got %d bytes", stream.Len())
+	}
+
+	// a frame larger than the buffer
+	if _, err := ReadDatagram(bytes.NewReader([]byte{0, 10, 1, 2}), make([]byte, 4)); err == nil {
+		t.Fatalf("expected a datagram larger than the buffer to fail")
+	}
+	// a stream cut in the middle of a frame
+	if _, err := ReadDatagram(bytes.NewReader([]byte{0, 10, 1, 2}), make([]byte, 16)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := ReadDatagram(bytes.NewReader([]byte{0}), make([]byte, 16)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -12,9 +12,11 @@ import (
 	"sync"
 
 	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+	"github.com/0xa1bed0/mkenv/internal/utils"
 )
 
-// WriteProxyHeader writes "PORT <port>\n" to w.
+// WriteProxyHeader writes "PORT <port>\n" to w. The stream is a TCP connection to the port.
 func WriteProxyHeader(w io.Writer, port int) error {
 	if port <= 0 || port > 65535 {
 		return fmt.Errorf("invalid port %d", port)
@@ -23,24 +25,90 @@ func WriteProxyHeader(w io.Writer, port int) error {
 	return err
 }
 
-// ReadProxyHeader reads "PORT <port>\n" from r and returns the parsed port.
-func ReadProxyHeader(r *bufio.Reader) (int, error) {
+// WriteUDPProxyHeader writes "UDP <port>\n" to w. The stream is a UDP session with the port:
+// datagrams are framed with WriteDatagram/ReadDatagram in both directions.
+func WriteUDPProxyHeader(w io.Writer, port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+	_, err := fmt.Fprintf(w, "UDP %d\n", port)
+	return err
+}
+
+// Origin is the sandbox process that opened a connection to the host.
+type Origin struct {
+	PID int
+	Cmd string
+}
+
+// WriteProxyHeaderWithOrigin writes "PORT <port> PID <pid> CMD <cmd>\n" to w.
+// It is WriteProxyHeader with the process that opened the connection, for the audit log.
+func WriteProxyHeaderWithOrigin(w io.Writer, port int, origin Origin) error {
+	if origin.PID <= 0 {
+		return WriteProxyHeader(w, port)
+	}
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+	// the header is space separated, cmd is informational only
+	cmd := strings.Join(strings.Fields(origin.Cmd), "_")
+	if cmd == "" {
+		cmd = "-"
+	}
+	_, err := fmt.Fprintf(w, "PORT %d PID %d CMD %s\n", port, origin.PID, cmd)
+	return err
+}
+
+// ReadProxyHeader reads "PORT <port>\n" or "UDP <port>\n" from r and returns the parsed protocol and port.
+func ReadProxyHeader(r *bufio.Reader) (shared.Proto, int, error) {
+	proto, port, _, err := ReadProxyHeaderWithOrigin(r)
+	return proto, port, err
+}
+
+// ReadProxyHeaderWithOrigin is ReadProxyHeader that also returns the origin process if the header has it.
+func ReadProxyHeaderWithOrigin(r *bufio.Reader) (shared.Proto, int, Origin, error) {
 	line, err := r.ReadString('\n')
 	if err != nil {
-		return 0, fmt.Errorf("read proxy header: %w", err)
+		return "", 0, Origin{}, fmt.Errorf("read proxy header: %w", err)
 	}
 
 	fields := strings.Fields(strings.TrimSpace(line))
-	if len(fields) != 2 || strings.ToUpper(fields[0]) != "PORT" {
-		return 0, fmt.Errorf("invalid proxy header %q", line)
+	if len(fields) != 2 && len(fields) != 6 {
+		return "", 0, Origin{}, fmt.Errorf("invalid proxy header %q", line)
+	}
+
+	var proto shared.Proto
+	switch strings.ToUpper(fields[0]) {
+	case "PORT":
+		proto = shared.ProtoTCP
+	case "UDP":
+		proto = shared.ProtoUDP
+	default:
+		return "", 0, Origin{}, fmt.Errorf("invalid proxy header %q", line)
 	}
 
 	port, err := strconv.Atoi(fields[1])
 	if err != nil || port <= 0 || port > 65535 {
-		return 0, fmt.Errorf("invalid port %q", fields[1])
+		return "", 0, Origin{}, fmt.Errorf("invalid port %q", fields[1])
+	}
+
+	var origin Origin
+	if len(fields) == 6 {
+		if strings.ToUpper(fields[2]) != "PID" || strings.ToUpper(fields[4]) != "CMD" {
+			return "", 0, Origin{}, fmt.Errorf("invalid proxy header %q", line)
+		}
+		pid, err := strconv.Atoi(fields[3])
+		if err != nil || pid <= 0 {
+			return "", 0, Origin{}, fmt.Errorf("invalid pid %q", fields[3])
+		}
+		origin.PID = pid
+		if fields[5] != "-" {
+			// the sandbox controls the header, the command ends up in prompts and the audit log
+			origin.Cmd = utils.StripControlChars(fields[5])
+		}
 	}
 
-	return port, nil
+	return proto, port, origin, nil
 }
 
 // PumpBidirectional copies bytes both ways between a and b until both sides close.
@@ -77,8 +145,9 @@ func copyOneWay(dst, src net.Conn) {
 
 	// Important: half-close write side, not full close, so the
 	// other direction can still use the connection for a bit.
-	if tcp, ok := dst.(*net.TCPConn); ok {
-		_ = tcp.CloseWrite()
+	// (both *net.TCPConn and mux streams support it)
+	if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
 	} else {
 		_ = dst.Close()
 	}
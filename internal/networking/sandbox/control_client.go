@@ -147,6 +147,54 @@ func (c *ControlClient) Install(ctx context.Context, pkgName string) (*shared.On
 	return &response, nil
 }
 
+// SSHAgent forwards a single SSH agent request to the host agent and returns the reply.
+func (c *ControlClient) SSHAgent(ctx context.Context, msg []byte) ([]byte, error) {
+	req, err := protocol.PackControlSignalEnvelope(protocol.NewID(), "mkenv.sandbox.ssh-agent", &shared.SSHAgentMessage{Message: msg})
+	if err != nil {
+		return nil, err
+	}
+
+	responseEnvelope, err := c.conn.Call(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if responseEnvelope.Err != "" {
+		return nil, errors.New(responseEnvelope.Err)
+	}
+
+	var response shared.SSHAgentMessage
+	err = protocol.UnpackControlSignalEnvelope(responseEnvelope, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Message, nil
+}
+
+// GitCredential asks the host to supply git credential for the request.
+func (c *ControlClient) GitCredential(ctx context.Context, request shared.GitCredentialRequest) (*shared.GitCredentialResponse, error) {
+	req, err := protocol.PackControlSignalEnvelope(protocol.NewID(), "mkenv.sandbox.git-credential", &request)
+	if err != nil {
+		return nil, err
+	}
+
+	responseEnvelope, err := c.conn.Call(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if responseEnvelope.Err != "" {
+		return nil, errors.New(responseEnvelope.Err)
+	}
+
+	var response shared.GitCredentialResponse
+	err = protocol.UnpackControlSignalEnvelope(responseEnvelope, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
 func (c *ControlClient) SendLog(line string) error {
 	req, _ := protocol.PackControlSignalEnvelope(protocol.NewID(), "mkenv.sandbox.log", &shared.LogEntry{Line: line})
 	return c.conn.Send(req)
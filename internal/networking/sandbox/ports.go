@@ -10,14 +10,19 @@ import (
 	"strings"
 
 	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
 	"github.com/0xa1bed0/mkenv/internal/networking/shared"
 )
 
 // CollectSnapshot returns a best-effort view of all listening TCP/UDP
 // sockets inside the container. It only works on Linux (/proc).
+// dockerEmbeddedDNS is the address of Docker's embedded DNS server in user-defined networks.
+const dockerEmbeddedDNS = "127.0.0.11"
+
 func CollectSnapshot() (shared.Snapshot, error) {
 	snap := shared.Snapshot{
-		Listeners: map[int]shared.Listener{},
+		Listeners:    map[int]shared.Listener{},
+		UDPListeners: map[int]shared.Listener{},
 	}
 
 	// 1) Parse /proc/net/{tcp,tcp6,udp,udp6} into inode->Listener (without PID/Cmd).
@@ -56,6 +61,10 @@ func CollectSnapshot() (shared.Snapshot, error) {
 		if l.PID == selfPID {
 			continue
 		}
+		if l.Proto == shared.ProtoUDP {
+			snap.UDPListeners[l.Port] = *l
+			continue
+		}
 		snap.Listeners[l.Port] = *l
 	}
 
@@ -99,12 +108,21 @@ func addProtoFromProc(path string, proto shared.Proto, inodeMap map[uint64]*shar
 		if proto == shared.ProtoTCP && state != "0A" {
 			continue
 		}
+		// UDP: we only care about bound but not connected sockets (07, remote 0), connected ones are clients.
+		if proto == shared.ProtoUDP && (state != "07" || !strings.HasSuffix(fields[2], ":0000")) {
+			continue
+		}
 
 		ip, port, err := parseProcAddress(localAddr)
 		if err != nil {
 			continue
 		}
 
+		// Docker embedded DNS lives in the container network namespace but is not a container service
+		if proto == shared.ProtoUDP && ip == dockerEmbeddedDNS {
+			continue
+		}
+
 		uid, _ := strconv.Atoi(uidStr)
 		inode, err := strconv.ParseUint(inodeStr, 10, 64)
 		if err != nil {
@@ -169,6 +187,67 @@ func parseProcAddress(s string) (string, int, error) {
 	}
 }
 
+// ConnOrigin returns the sandbox process that opened conn, a TCP connection accepted on loopback.
+// Best effort: the zero Origin is returned if the process can't be found.
+func ConnOrigin(conn net.Conn) protocol.Origin {
+	local, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return protocol.Origin{}
+	}
+	remote, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return protocol.Origin{}
+	}
+
+	// the client end of the connection is local to the client and remote to us
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		inode, ok := findConnInode(path, remote.Port, local.Port)
+		if !ok {
+			continue
+		}
+		inodeMap := map[uint64]*shared.Listener{inode: {}}
+		mapInodesToPIDs(inodeMap)
+		return protocol.Origin{PID: inodeMap[inode].PID, Cmd: inodeMap[inode].Cmd}
+	}
+	return protocol.Origin{}
+}
+
+// findConnInode returns the inode of the established socket with the given local and remote ports.
+func findConnInode(path string, localPort, remotePort int) (uint64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// Skip header
+	if !scanner.Scan() {
+		return 0, false
+	}
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != "01" {
+			continue
+		}
+		_, lport, err := parseProcAddress(fields[1])
+		if err != nil || lport != localPort {
+			continue
+		}
+		_, rport, err := parseProcAddress(fields[2])
+		if err != nil || rport != remotePort {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil || inode == 0 {
+			continue
+		}
+		return inode, true
+	}
+	return 0, false
+}
+
 // mapInodesToPIDs walks /proc/<pid>/fd and resolves socket:[inode] symlinks
 // to associate inodes with processes and command names.
 func mapInodesToPIDs(inodeMap map[uint64]*shared.Listener) {
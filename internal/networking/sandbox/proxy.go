@@ -3,12 +3,17 @@ package sandbox
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"syscall"
+	"time"
 
 	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
 	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/mux"
 	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
 	"github.com/0xa1bed0/mkenv/internal/networking/transport"
 	"github.com/0xa1bed0/mkenv/internal/runtime"
 )
@@ -53,6 +58,14 @@ func (c *bufferedConn) Read(p []byte) (int, error) {
 	return c.r.Read(p)
 }
 
+// CloseWrite half-closes the wrapped connection if it supports it.
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
 func (p *ProxyServer) handleConn(ctx context.Context, clientConn net.Conn) {
 	logs.Infof("proxy handles connection")
 	defer clientConn.Close()
@@ -60,17 +73,55 @@ func (p *ProxyServer) handleConn(ctx context.Context, clientConn net.Conn) {
 	remote := clientConn.RemoteAddr().String()
 	r := bufio.NewReader(clientConn)
 
-	port, err := protocol.ReadProxyHeader(r)
+	isMux, err := mux.IsPreamble(r)
 	if err != nil {
 		logs.Errorf("proxy: bad header from %s: %v", remote, err)
 		return
 	}
+	if isMux {
+		p.serveMux(remote, mux.Server(clientConn, r))
+		return
+	}
+
+	p.proxy(remote, r, clientConn)
+}
+
+// serveMux proxies every stream of a multiplexed connection from the host as if it was a connection of its own.
+func (p *ProxyServer) serveMux(remote string, session *mux.Session) {
+	defer session.Close()
+	logs.Infof("proxy: %s multiplexed connection (start)", remote)
+
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			break
+		}
+		p.rt.GoNamed(fmt.Sprintf("Proxy;%s;stream %d", remote, stream.ID()), func() {
+			defer stream.Close()
+			p.proxy(remote, bufio.NewReader(stream), stream)
+		})
+	}
+
+	logs.Infof("proxy: %s multiplexed connection (done)", remote)
+}
+
+// proxy reads the proxy header from r and pumps clientConn to the requested port.
+func (p *ProxyServer) proxy(remote string, r *bufio.Reader, clientConn net.Conn) {
+	proto, port, err := protocol.ReadProxyHeader(r)
+	if err != nil {
+		logs.Errorf("proxy: bad header from %s: %v", remote, err)
+		return
+	}
+
+	if proto == shared.ProtoUDP {
+		p.relayUDP(remote, r, clientConn, port)
+		return
+	}
 
 	targetAddr := fmt.Sprintf("localhost:%d", port)
 	backendConn, err := net.Dial("tcp", targetAddr)
 	if err != nil {
 		logs.Errorf("proxy: dial backend %s for %s: %v", targetAddr, remote, err)
-		clientConn.Close()
 		return
 	}
 	defer backendConn.Close()
@@ -86,3 +137,70 @@ func (p *ProxyServer) handleConn(ctx context.Context, clientConn net.Conn) {
 
 	logs.Infof("proxy: %s -> %s (done)", remote, targetAddr)
 }
+
+const (
+	// maxUDPReadFailures consecutive read errors from a udp backend end the session
+	maxUDPReadFailures = 10
+	// udpReadRetryDelay is the backoff step between failed reads
+	udpReadRetryDelay = 50 * time.Millisecond
+)
+
+// relayUDP relays framed datagrams of a single host UDP session to localhost:port and back.
+// The session ends when the host closes the stream.
+func (p *ProxyServer) relayUDP(remote string, r *bufio.Reader, clientConn net.Conn, port int) {
+	targetAddr := fmt.Sprintf("localhost:%d", port)
+	backendConn, err := net.Dial("udp", targetAddr)
+	if err != nil {
+		logs.Errorf("proxy: dial udp backend %s for %s: %v", targetAddr, remote, err)
+		return
+	}
+	defer backendConn.Close()
+
+	logs.Infof("proxy: %s -> udp %s (start)", remote, targetAddr)
+
+	// backend -> host
+	p.rt.GoNamed("Proxy;UDPRelay", func() {
+		buf := make([]byte, protocol.MaxDatagramSize)
+		failures := 0
+		for {
+			n, err := backendConn.Read(buf)
+			if err != nil {
+				// closed when the session ends
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				// ICMP port unreachable is not fatal for udp: the backend may not be listening yet
+				if errors.Is(err, syscall.ECONNREFUSED) {
+					continue
+				}
+				failures++
+				if failures >= maxUDPReadFailures {
+					logs.Errorf("proxy: udp read from %s failed %d times in a row, closing the session: %v", targetAddr, failures, err)
+					clientConn.Close()
+					return
+				}
+				logs.Debugf("proxy: udp read from %s: %v", targetAddr, err)
+				time.Sleep(time.Duration(failures) * udpReadRetryDelay)
+				continue
+			}
+			failures = 0
+			if err := protocol.WriteDatagram(clientConn, buf[:n]); err != nil {
+				return
+			}
+		}
+	})
+
+	// host -> backend
+	buf := make([]byte, protocol.MaxDatagramSize)
+	for {
+		n, err := protocol.ReadDatagram(r, buf)
+		if err != nil {
+			break
+		}
+		if _, err := backendConn.Write(buf[:n]); err != nil {
+			logs.Debugf("proxy: udp write to %s: %v", targetAddr, err)
+		}
+	}
+
+	logs.Infof("proxy: %s -> udp %s (done)", remote, targetAddr)
+}
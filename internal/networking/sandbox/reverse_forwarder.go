@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"net"
 	"sync"
-	"time"
 
 	"github.com/0xa1bed0/mkenv/internal/logs"
 	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/networking/transport"
 )
 
 // ReverseForwarder listens on a specific port inside the container and forwards
@@ -16,9 +16,11 @@ import (
 // the actual host service on the same port.
 //
 // Example: Container app calls localhost:5432 -> ReverseForwarder -> Host Reverse Proxy -> Host Postgres
+//
+// Connections are streams of the tunnel to the reverse proxy, shared by all reverse forwarders.
 type ReverseForwarder struct {
 	port         int
-	reverseProxy string // host.docker.internal:12345
+	reverseProxy *transport.MuxDialer
 	listener     net.Listener
 	ctx          context.Context
 	cancel       context.CancelFunc
@@ -26,11 +28,11 @@ type ReverseForwarder struct {
 }
 
 // NewReverseForwarder creates a new reverse forwarder for a specific port
-func NewReverseForwarder(port int, reverseProxyAddr string) *ReverseForwarder {
+func NewReverseForwarder(port int, reverseProxy *transport.MuxDialer) *ReverseForwarder {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &ReverseForwarder{
 		port:         port,
-		reverseProxy: reverseProxyAddr,
+		reverseProxy: reverseProxy,
 		ctx:          ctx,
 		cancel:       cancel,
 	}
@@ -44,7 +46,7 @@ func (rf *ReverseForwarder) Start() error {
 	}
 	rf.listener = ln
 
-	logs.Infof("reverse forwarder listening on 127.0.0.1:%d -> %s", rf.port, rf.reverseProxy)
+	logs.Infof("reverse forwarder listening on 127.0.0.1:%d", rf.port)
 
 	go rf.acceptLoop()
 	return nil
@@ -72,16 +74,16 @@ func (rf *ReverseForwarder) acceptLoop() {
 func (rf *ReverseForwarder) handleConn(clientConn net.Conn) {
 	defer clientConn.Close()
 
-	// Dial the host's reverse proxy server
-	hostConn, err := net.DialTimeout("tcp", rf.reverseProxy, 5*time.Second)
+	// Open a stream to the host's reverse proxy server
+	hostConn, err := rf.reverseProxy.Dial(rf.ctx)
 	if err != nil {
-		logs.Errorf("reverse forwarder: can't dial %s: %v", rf.reverseProxy, err)
+		logs.Errorf("reverse forwarder: can't dial reverse proxy for port %d: %v", rf.port, err)
 		return
 	}
 	defer hostConn.Close()
 
-	// Send the port header to tell the host which port we want to access
-	if err := protocol.WriteProxyHeader(hostConn, rf.port); err != nil {
+	// Send the port header to tell the host which port we want to access and who is asking
+	if err := protocol.WriteProxyHeaderWithOrigin(hostConn, rf.port, ConnOrigin(clientConn)); err != nil {
 		logs.Errorf("reverse forwarder: can't write header for port %d: %v", rf.port, err)
 		return
 	}
@@ -0,0 +1,78 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/shared"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+)
+
+// ServeSSHAgent serves an SSH agent on socketPath which forwards every request to the host agent
+// over the control plane. It blocks until ctx is done.
+func ServeSSHAgent(ctx context.Context, rt *runtime.Runtime, client *ControlClient, socketPath string) error {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0o700); err != nil {
+		return err
+	}
+	_ = os.Remove(socketPath)
+
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen ssh agent socket: %w", err)
+	}
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+
+	rt.GoNamed("SSHAgent;Close", func() {
+		<-ctx.Done()
+		ln.Close()
+	})
+
+	logs.Infof("ssh agent forwarding listens on %s", socketPath)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		rt.GoNamed("SSHAgent;Conn", func() {
+			defer conn.Close()
+			serveSSHAgentConn(ctx, client, conn)
+		})
+	}
+}
+
+func serveSSHAgentConn(ctx context.Context, client *ControlClient, conn net.Conn) {
+	for {
+		msg, err := shared.ReadSSHAgentMessage(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logs.Debugf("ssh agent read: %v", err)
+			}
+			return
+		}
+
+		response, err := client.SSHAgent(ctx, msg)
+		if err != nil {
+			logs.Warnf("ssh agent request failed: %v", err)
+			response = shared.SSHAgentFailure
+		}
+
+		if err := shared.WriteSSHAgentMessage(conn, response); err != nil {
+			logs.Debugf("ssh agent write: %v", err)
+			return
+		}
+	}
+}
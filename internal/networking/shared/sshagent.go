@@ -0,0 +1,41 @@
+package shared
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxSSHAgentMessageSize limits agent messages, real ones are a few KB at most.
+const maxSSHAgentMessageSize = 256 * 1024
+
+// SSHAgentFailure is the SSH_AGENT_FAILURE reply for requests that can't be served.
+var SSHAgentFailure = []byte{5}
+
+// ReadSSHAgentMessage reads a single length-prefixed SSH agent message.
+func ReadSSHAgentMessage(r io.Reader) ([]byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n == 0 || n > maxSSHAgentMessageSize {
+		return nil, fmt.Errorf("invalid ssh agent message size: %d", n)
+	}
+
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// WriteSSHAgentMessage writes a single length-prefixed SSH agent message.
+func WriteSSHAgentMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(buf, uint32(len(msg)))
+	copy(buf[4:], msg)
+	_, err := w.Write(buf)
+	return err
+}
@@ -16,11 +16,13 @@ type Listener struct {
 }
 
 type Snapshot struct {
-	Listeners map[int]Listener `json:"listeners"` // port number => Listener
+	Listeners    map[int]Listener `json:"listeners"`     // tcp port number => Listener
+	UDPListeners map[int]Listener `json:"udp_listeners"` // udp port number => Listener
 }
 
 type OnSnapshotResponse struct {
-	Response map[int]string `json:"ports_allocation_status"`
+	Response    map[int]string `json:"ports_allocation_status"`
+	UDPResponse map[int]string `json:"udp_ports_allocation_status,omitempty"`
 }
 
 type OnInstallResponse struct {
@@ -52,3 +54,21 @@ type FetchLogsResponse struct {
 	Lines      []string `json:"lines"`
 	TotalLines int      `json:"total_lines"` // Total lines in the log file
 }
+
+// SSHAgentMessage is a single SSH agent protocol message (without the length prefix).
+type SSHAgentMessage struct {
+	Message []byte `json:"message"`
+}
+
+// GitCredentialRequest mirrors the git credential helper input.
+type GitCredentialRequest struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Path     string `json:"path,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+type GitCredentialResponse struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
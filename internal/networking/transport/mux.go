@@ -0,0 +1,179 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/mux"
+)
+
+// MuxDialer opens streams over a few persistent multiplexed connections to addr
+// instead of dialing a new TCP connection each time. Connections are dialed lazily
+// and redialed when they break. The other side must detect the mux preamble (see mux.IsPreamble).
+type MuxDialer struct {
+	addr     string
+	size     int
+	attempts int
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	sessions []*mux.Session
+	next     int
+	closed   bool
+	dialing  int           // slots reserved by dials in flight
+	dialed   chan struct{} // closed when a dial in flight finishes
+}
+
+// defaultMuxDialTimeout bounds dialing a connection when the dialer has no timeout of its own.
+const defaultMuxDialTimeout = 10 * time.Second
+
+// NewMuxDialer returns a dialer spreading streams over up to size connections to addr.
+// Every connection is dialed with up to attempts retries, giving up after timeout (0 means defaultMuxDialTimeout).
+func NewMuxDialer(addr string, size, attempts int, timeout time.Duration) *MuxDialer {
+	if size <= 0 {
+		size = 1
+	}
+	return &MuxDialer{
+		addr:     addr,
+		size:     size,
+		attempts: attempts,
+		timeout:  timeout,
+	}
+}
+
+// Dial opens a new stream. The stream is a net.Conn, closing it does not close the underlying connection.
+func (d *MuxDialer) Dial(ctx context.Context) (net.Conn, error) {
+	session, err := d.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := session.Open()
+	if err == nil {
+		return stream, nil
+	}
+
+	// the connection broke since we last used it; a fresh one gets a single retry
+	logs.Debugf("mux dialer: open stream to %s: %v. Reconnecting...", d.addr, err)
+	session, err = d.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return session.Open()
+}
+
+// Close closes all connections and the streams on them.
+func (d *MuxDialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	for _, session := range d.sessions {
+		_ = session.Close()
+	}
+	d.sessions = nil
+	return nil
+}
+
+// session returns the next live session round robin, dialing a new one while the pool is not full.
+// The lock is not held while dialing, so a slow or unreachable addr doesn't block streams on live sessions.
+func (d *MuxDialer) session(ctx context.Context) (*mux.Session, error) {
+	d.mu.Lock()
+	for {
+		if d.closed {
+			d.mu.Unlock()
+			return nil, net.ErrClosed
+		}
+
+		live := d.sessions[:0]
+		for _, session := range d.sessions {
+			if !session.IsClosed() {
+				live = append(live, session)
+			}
+		}
+		d.sessions = live
+
+		if len(d.sessions)+d.dialing < d.size {
+			break
+		}
+		if len(d.sessions) > 0 {
+			session := d.pickLocked()
+			d.mu.Unlock()
+			return session, nil
+		}
+
+		// every slot is being dialed already; wait for one of them
+		dialed := d.dialed
+		d.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-dialed:
+		}
+		d.mu.Lock()
+	}
+
+	// reserve the slot
+	d.dialing++
+	if d.dialed == nil {
+		d.dialed = make(chan struct{})
+	}
+	d.mu.Unlock()
+
+	session, err := d.dial(ctx)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dialing--
+	close(d.dialed)
+	d.dialed = nil
+	if d.dialing > 0 {
+		d.dialed = make(chan struct{})
+	}
+
+	if err != nil {
+		// better a busy connection than none
+		if len(d.sessions) > 0 {
+			logs.Debugf("mux dialer: dial %s: %v", d.addr, err)
+			return d.pickLocked(), nil
+		}
+		return nil, err
+	}
+	if d.closed {
+		_ = session.Close()
+		return nil, net.ErrClosed
+	}
+	d.sessions = append(d.sessions, session)
+	return session, nil
+}
+
+func (d *MuxDialer) pickLocked() *mux.Session {
+	d.next = (d.next + 1) % len(d.sessions)
+	return d.sessions[d.next]
+}
+
+func (d *MuxDialer) dial(ctx context.Context) (*mux.Session, error) {
+	timeout := d.timeout
+	if timeout <= 0 {
+		timeout = defaultMuxDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := DialTCP(ctx, d.addr, d.attempts, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := mux.Client(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	logs.Debugf("mux dialer: connected to %s", d.addr)
+	return session, nil
+}
@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// ListenUnix listens on the unix socket at path. A stale socket file left by a dead process is removed,
+// but if another process is still serving on it an error is returned.
+func ListenUnix(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		conn, dialErr := net.DialTimeout("unix", path, 200*time.Millisecond)
+		if dialErr == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket %s: %w", path, err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the owner may talk to the socket
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// DialUnix dials the unix socket at path once.
+func DialUnix(ctx context.Context, path string) (net.Conn, error) {
+	if path == "" {
+		return nil, errors.New("empty socket path")
+	}
+	dialer := &net.Dialer{}
+	return dialer.DialContext(ctx, "unix", path)
+}
@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/0xa1bed0/mkenv/internal/bricksengine"
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/utils"
+)
+
+// brickConfigFlagSource names bricks_config set on the command line in errors.
+const brickConfigFlagSource = "--brick-config"
+
+// brickConfigSource is a file (or the command line) that sets bricks_config values.
+type brickConfigSource struct {
+	name    string
+	data    []byte // JSON of the file, nil for the command line
+	configs map[bricksengine.BrickID]map[string]string
+}
+
+// RegisterCustomBricks registers declarative bricks and the mkenv-brick-* plugins on PATH the policy allows,
+// so they can be enabled, detected and validated like built-in bricks.
+func RegisterCustomBricks(ctx context.Context, registry *bricksengine.BricksRegistry, policy guardrails.Policy, defs []bricksengine.BrickDefinition) error {
+	for _, def := range defs {
+		if !policy.AllowCustomBrick(def.ID) {
+			logs.Warnf("Ignoring brick %s defined in %s: custom brick is not allowed by policy (allowed_custom_bricks)", def.ID, def.Source)
+			continue
+		}
+		if err := registry.RegisterDefinition(def); err != nil {
+			return err
+		}
+	}
+
+	plugins, err := bricksengine.DiscoverPlugins(ctx, os.Getenv("PATH"))
+	if err != nil {
+		return err
+	}
+	for _, plugin := range plugins {
+		if !policy.AllowCustomBrick(plugin.ID()) {
+			logs.Warnf("Ignoring brick plugin %s: custom brick is not allowed by policy (allowed_custom_bricks)", plugin.Path())
+			continue
+		}
+		if err := registry.RegisterPlugin(plugin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBricksConfig checks bricks_config of .mkenv files, the command line and the policy against
+// the config schemas of the bricks. Errors point at the file and line of the value.
+// Values of unknown bricks are only warned about: the brick may be a plugin that isn't installed here.
+func validateBricksConfig(registry *bricksengine.BricksRegistry, sources []brickConfigSource, policy guardrails.Policy) error {
+	errs := []error{}
+	check := func(source string, brickID bricksengine.BrickID, key, value string) {
+		err := registry.ValidateBrickConfig(brickID, key, value)
+		if errors.Is(err, bricksengine.ErrUnknownBrick) {
+			logs.Warnf("%s: bricks_config.%s: unknown brick, its config is ignored", source, brickID)
+			return
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: bricks_config.%s.%s: %w", source, brickID, key, err))
+		}
+	}
+
+	for _, src := range sources {
+		for _, brickID := range bricksengine.UniqueSortedBricks(slices.Collect(maps.Keys(src.configs))) {
+			config := src.configs[brickID]
+			for _, key := range utils.SortedKeys(config) {
+				source := src.name
+				if line := utils.JSONKeyLine(src.data, "bricks_config", string(brickID), key); line > 0 {
+					source = fmt.Sprintf("%s:%d", src.name, line)
+				}
+				check(source, brickID, key, config[key])
+			}
+		}
+	}
+	for _, entry := range policy.BricksConfigEntries() {
+		check(entry.Source, entry.Brick, entry.Key, entry.Value)
+	}
+	return errors.Join(errs...)
+}
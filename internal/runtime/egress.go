@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+)
+
+// egressFirewallPackage is installed into the image in egress mode, the sandbox uses it to drop direct traffic.
+const egressFirewallPackage = "iptables"
+
+// EgressConfig is the `egress` section of .mkenv. In egress mode the sandbox can reach the internet
+// only through the host egress proxy, which allows the listed domains (and the ones approved interactively):
+//
+//	"egress": {
+//	  "enabled": true,
+//	  "allowed_domains": ["registry.npmjs.org", "*.github.com"]
+//	}
+type EgressConfig struct {
+	Enabled        bool     `json:"enabled"`
+	AllowedDomains []string `json:"allowed_domains"`
+}
+
+func (eg EgressConfig) copy() EgressConfig {
+	return EgressConfig{
+		Enabled:        eg.Enabled,
+		AllowedDomains: append([]string{}, eg.AllowedDomains...),
+	}
+}
+
+func (eg *EgressConfig) merge(src EgressConfig, from string) {
+	if src.Enabled && !eg.Enabled {
+		eg.Enabled = true
+		logs.Debugf("egress mode is enabled by %s", from)
+	}
+	for _, domain := range src.AllowedDomains {
+		if !slices.Contains(eg.AllowedDomains, domain) {
+			eg.AllowedDomains = append(eg.AllowedDomains, domain)
+			logs.Debugf("egress to %s is allowed by %s", domain, from)
+		}
+	}
+}
+
+// applyEgressPolicy validates the project allowlist and combines it with the policy one.
+// When policy enforces egress mode it is the only source of allowed domains.
+func applyEgressPolicy(rc *envConfig, policy guardrails.Policy) error {
+	for _, domain := range rc.Egress_.AllowedDomains {
+		if err := guardrails.ValidateDomainPattern(domain); err != nil {
+			return err
+		}
+	}
+
+	if policy.EgressEnforced() {
+		if len(rc.Egress_.AllowedDomains) > 0 {
+			logs.Warnf("egress mode is enforced by policy: allowed_domains of .mkenv are ignored, only domains allowed by policy are reachable")
+		}
+		rc.Egress_ = EgressConfig{Enabled: true}
+		logs.Debugf("egress mode is enforced by policy")
+	}
+
+	if !rc.Egress_.Enabled {
+		return nil
+	}
+
+	for _, domain := range policy.EgressAllowedDomains() {
+		if err := guardrails.ValidateDomainPattern(domain); err != nil {
+			return fmt.Errorf("policy: %w", err)
+		}
+		if !slices.Contains(rc.Egress_.AllowedDomains, domain) {
+			rc.Egress_.AllowedDomains = append(rc.Egress_.AllowedDomains, domain)
+		}
+	}
+
+	if !slices.Contains(rc.ExtraPkgs_, egressFirewallPackage) {
+		rc.ExtraPkgs_ = append(rc.ExtraPkgs_, egressFirewallPackage)
+	}
+
+	return nil
+}
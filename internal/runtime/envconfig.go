@@ -5,13 +5,14 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
 	"github.com/0xa1bed0/mkenv/internal/bricksengine"
 	"github.com/0xa1bed0/mkenv/internal/guardrails"
 	"github.com/0xa1bed0/mkenv/internal/logs"
@@ -27,6 +28,11 @@ type EnvConfig interface {
 	ShouldDisableAuto() bool
 	Volumes() []string
 	ExtraPkgs() []string
+	Env() map[string]EnvVar
+	Egress() EgressConfig
+	MaskedPaths() []string
+	SecretScan() guardrails.ScanConfig
+	Bricks() []bricksengine.BrickDefinition
 
 	FilePath() string           // path to .mkenv file that correspond to this env config
 	Signature() (string, error) // return signature of the object
@@ -42,6 +48,11 @@ type envConfig struct {
 	ShouldDisableAuto_        bool                                       `json:"disable_auto"`
 	Volumes_                  []string                                   `json:"volumes"`
 	ExtraPkgs_                []string                                   `json:"extra_pkgs"`
+	Env_                      map[string]EnvVar                          `json:"env"`
+	Egress_                   EgressConfig                               `json:"egress"`
+	MaskedPaths_              []string                                   `json:"masked_paths"`
+	SecretScan_               guardrails.ScanConfig                      `json:"secret_scan"`
+	Bricks_                   []bricksengine.BrickDefinition             `json:"bricks"`
 }
 
 func (ec envConfig) Copy() *envConfig {
@@ -67,6 +78,14 @@ func (ec envConfig) Copy() *envConfig {
 	for _, pkg := range ec.ExtraPkgs_ {
 		newEncConfig.ExtraPkgs_ = append(newEncConfig.ExtraPkgs_, pkg)
 	}
+	newEncConfig.Env_ = maps.Clone(ec.Env_)
+	newEncConfig.Egress_ = ec.Egress_.copy()
+	newEncConfig.MaskedPaths_ = append([]string{}, ec.MaskedPaths_...)
+	newEncConfig.SecretScan_ = guardrails.ScanConfig{
+		Filenames:       append([]string{}, ec.SecretScan_.Filenames...),
+		ContentPatterns: append([]guardrails.ContentPattern{}, ec.SecretScan_.ContentPatterns...),
+	}
+	newEncConfig.Bricks_ = append([]bricksengine.BrickDefinition{}, ec.Bricks_...)
 	return newEncConfig
 }
 
@@ -78,6 +97,14 @@ func (ec *envConfig) Signature() (string, error) {
 	// should not be included to sugnature because signature is a part of docker image cache key.
 	// TODO: move this whole signature function to the state package. it should not be here
 	ecCopy.Volumes_ = []string{}
+	// env is injected on container start, so changing it must not trigger image rebuild
+	ecCopy.Env_ = map[string]EnvVar{}
+	// the proxy allowlist is applied on the host. The firewall package egress mode needs is in ExtraPkgs_
+	ecCopy.Egress_ = EgressConfig{}
+	// masks are bind mounts, applied on container start
+	ecCopy.MaskedPaths_ = []string{}
+	// the scanner runs on the host before the container starts
+	ecCopy.SecretScan_ = guardrails.ScanConfig{}
 
 	data, err := json.Marshal(ecCopy)
 	if err != nil {
@@ -166,6 +193,10 @@ func buildDefaultEnvConfig() *envConfig {
 		ShouldDisableAuto_:        false,
 		Volumes_:                  []string{},
 		ExtraPkgs_:                []string{},
+		Env_:                      map[string]EnvVar{},
+		Egress_:                   EgressConfig{AllowedDomains: []string{}},
+		MaskedPaths_:              []string{},
+		Bricks_:                   []bricksengine.BrickDefinition{},
 	}
 }
 
@@ -194,7 +225,7 @@ func (ec *envConfig) Merge(src EnvConfig) {
 
 	srcConfigs := src.BricksConfigs()
 	for brick, cfg := range srcConfigs {
-		existing := srcConfigs[brick]
+		existing := ec.BricksConfigs_[brick]
 		if existing == nil {
 			existing = make(map[string]string)
 			ec.BricksConfigs_[brick] = existing
@@ -226,6 +257,39 @@ func (ec *envConfig) Merge(src EnvConfig) {
 	for _, pkg := range src.ExtraPkgs() {
 		logs.Debugf("Extra package %s requested by %s", pkg, src.FilePath())
 	}
+
+	for name, ev := range src.Env() {
+		ec.Env_[name] = ev
+		logs.Debugf("env variable %s is set by %s", name, src.FilePath())
+	}
+
+	ec.Egress_.merge(src.Egress(), src.FilePath())
+
+	for _, pattern := range src.MaskedPaths() {
+		if !slices.Contains(ec.MaskedPaths_, pattern) {
+			ec.MaskedPaths_ = append(ec.MaskedPaths_, pattern)
+			logs.Debugf("path %s is masked by %s", pattern, src.FilePath())
+		}
+	}
+
+	srcScan := src.SecretScan()
+	ec.SecretScan_.Filenames = append(ec.SecretScan_.Filenames, srcScan.Filenames...)
+	ec.SecretScan_.ContentPatterns = append(ec.SecretScan_.ContentPatterns, srcScan.ContentPatterns...)
+	for _, cp := range srcScan.ContentPatterns {
+		logs.Debugf("secret scanner rule %s is added by %s", cp.ID, src.FilePath())
+	}
+
+	// a brick defined closer to the project replaces the one with the same id
+	for _, def := range src.Bricks() {
+		i := slices.IndexFunc(ec.Bricks_, func(d bricksengine.BrickDefinition) bool { return d.ID == def.ID })
+		if i >= 0 {
+			logs.Debugf("brick %s defined in %s is replaced by %s", def.ID, ec.Bricks_[i].Source, def.Source)
+			ec.Bricks_[i] = def
+			continue
+		}
+		ec.Bricks_ = append(ec.Bricks_, def)
+		logs.Debugf("brick %s is defined by %s", def.ID, def.Source)
+	}
 }
 
 func (ec *envConfig) FilePath() string {
@@ -277,6 +341,31 @@ func (ec *envConfig) ExtraPkgs() []string {
 	return out
 }
 
+func (ec *envConfig) MaskedPaths() []string {
+	out := []string{}
+	out = append(out, ec.MaskedPaths_...)
+	return out
+}
+
+func (ec *envConfig) SecretScan() guardrails.ScanConfig {
+	return guardrails.ScanConfig{
+		Filenames:       append([]string{}, ec.SecretScan_.Filenames...),
+		ContentPatterns: append([]guardrails.ContentPattern{}, ec.SecretScan_.ContentPatterns...),
+	}
+}
+
+func (ec *envConfig) Bricks() []bricksengine.BrickDefinition {
+	return append([]bricksengine.BrickDefinition{}, ec.Bricks_...)
+}
+
+func (ec *envConfig) Env() map[string]EnvVar {
+	return maps.Clone(ec.Env_)
+}
+
+func (ec *envConfig) Egress() EgressConfig {
+	return ec.Egress_.copy()
+}
+
 func ensureProjectPathIsSafe(ctx context.Context, policy guardrails.Policy, project *Project) error {
 	projectPath := project.Path()
 
@@ -284,39 +373,8 @@ func ensureProjectPathIsSafe(ctx context.Context, policy guardrails.Policy, proj
 		return fmt.Errorf("project path %s is not allowed by mkenv", projectPath)
 	}
 
-	if len(policy.AllowedProjectRoot()) > 0 && !guardrails.IsUnderPrefix(policy.AllowedProjectRoot(), projectPath) {
-		return fmt.Errorf("project path %s is rejected by policy. Path is not under allowed projects root %s", projectPath, policy.AllowedProjectRoot())
-	}
-
-	if !project.Known() {
-		logs.Infof("Project %s is not known. Scanning files to ensure it's safeness...", projectPath)
-		warnings, err := guardrails.ScanSuspiciousFiles(ctx, projectPath)
-		if err != nil {
-			return err
-		}
-
-		if len(warnings) > 0 {
-			text := "It looks like the project folder contain potentially sensitive files. If you continue they will be mounted to the sandbox: \n\n"
-			for _, warn := range warnings {
-				text += "\n\t" + "- " + warn.Path + " - " + warn.Reason + "\n"
-				if len(warn.Content) > 0 {
-					text += "\n"
-				}
-				for _, line := range warn.Content {
-					text += "\t\t" + line + "\n"
-				}
-				text += "\n"
-			}
-			text += "\n"
-			text += "It looks like the project folder contain potentially sensitive files. If you continue they will be mounted to the sandbox. Please scroll up and review all of them before confirming\n"
-			ok, err := logs.PromptConfirm(text)
-			if err != nil {
-				return err
-			}
-			if !ok {
-				return errors.New("user prompt failed")
-			}
-		}
+	if !policy.AllowProjectPath(projectPath) {
+		return fmt.Errorf("project path %s is rejected by policy. Path is not under allowed projects roots %s", projectPath, strings.Join(policy.AllowedProjectRoots(), ", "))
 	}
 
 	return nil
@@ -359,11 +417,8 @@ func resolvePreferencesChain(projectPath string) ([]string, error) {
 	return files, nil
 }
 
-func loadPreferencesFile(path string) (*envConfig, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+// parsePreferencesFile parses data read from the .mkenv file at path.
+func parsePreferencesFile(path string, data []byte) (*envConfig, error) {
 	var p envConfig
 	if err := json.Unmarshal(data, &p); err != nil {
 		return nil, fmt.Errorf("failed to parse preferences %s: %w", path, err)
@@ -371,6 +426,35 @@ func loadPreferencesFile(path string) (*envConfig, error) {
 	if p.BricksConfigs_ == nil {
 		p.BricksConfigs_ = make(map[bricksengine.BrickID]map[string]string)
 	}
+	if p.Env_ == nil {
+		p.Env_ = make(map[string]EnvVar)
+	}
+	// from_file is relative to the .mkenv file it is declared in
+	for name, ev := range p.Env_ {
+		if ev.FromFile != "" && !filepath.IsAbs(ev.FromFile) && !strings.HasPrefix(ev.FromFile, "~") {
+			ev.FromFile = filepath.Join(filepath.Dir(path), ev.FromFile)
+			p.Env_[name] = ev
+		}
+	}
+	for _, pattern := range p.MaskedPaths_ {
+		if err := guardrails.ValidateMaskPattern(pattern); err != nil {
+			return nil, fmt.Errorf("failed to parse preferences %s: %w", path, err)
+		}
+	}
+	if err := p.SecretScan_.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to parse preferences %s: secret_scan: %w", path, err)
+	}
+	if p.SecretScan_.Entropy != nil {
+		// .mkenv files can add rules but not weaken the scanner
+		logs.Warnf("Ignoring secret_scan.entropy in %s: only policies can tune entropy detection", path)
+		p.SecretScan_.Entropy = nil
+	}
+	for i := range p.Bricks_ {
+		p.Bricks_[i].Source = path
+		if err := p.Bricks_[i].Validate(); err != nil {
+			return nil, fmt.Errorf("failed to parse preferences %s: bricks: %w", path, err)
+		}
+	}
 	p.name = path
 	return &p, nil
 }
@@ -395,7 +479,58 @@ func applyPolicy(rc *envConfig, policy guardrails.Policy) error {
 		logs.Debugf("environment auto-estimation disabled by policy")
 	}
 
-	if len(policy.AllowedMounts()) > 0 {
+	// policy bricks_config wins over the user's one
+	for brick, cfg := range policy.BricksConfigs() {
+		if rc.BricksConfigs_[brick] == nil {
+			rc.BricksConfigs_[brick] = map[string]string{}
+		}
+		for k, v := range cfg {
+			rc.BricksConfigs_[brick][k] = v
+			logs.Debugf("Brick %s configuration key %v is set to value %s by policy", brick, k, v)
+		}
+	}
+
+	customBricks := []bricksengine.BrickDefinition{}
+	for _, def := range rc.Bricks_ {
+		if !policy.AllowCustomBrick(def.ID) {
+			logs.Warnf("Ignoring brick %s defined in %s: custom brick is not allowed by policy (allowed_custom_bricks)", def.ID, def.Source)
+			continue
+		}
+		customBricks = append(customBricks, def)
+	}
+	rc.Bricks_ = customBricks
+
+	envErrors := []error{}
+	for name, ev := range rc.Env_ {
+		if err := ev.validate(name); err != nil {
+			envErrors = append(envErrors, err)
+			continue
+		}
+		if ev.FromHost != "" && !policy.AllowEnvPassthrough(ev.FromHost) {
+			envErrors = append(envErrors, fmt.Errorf("passthrough of host env variable %s (requested as %s) is not allowed by policy", ev.FromHost, name))
+		}
+	}
+	if len(envErrors) > 0 {
+		return fmt.Errorf("%v", envErrors)
+	}
+
+	if err := applyEgressPolicy(rc, policy); err != nil {
+		return err
+	}
+
+	// volume paths are resolved through symlinks, a volume can't reach a forbidden location via a symlink
+	mountErrors := []error{}
+	for _, vol := range rc.Volumes_ {
+		source := strings.Split(vol, ":")[0]
+		if guardrails.IsAbsolutelyForbidden(source) {
+			mountErrors = append(mountErrors, fmt.Errorf("volume %s is not allowed by mkenv: %s resolves to a forbidden location", vol, source))
+		}
+	}
+	if len(mountErrors) > 0 {
+		return fmt.Errorf("%v", mountErrors)
+	}
+
+	if policy.MountsRestricted() {
 		errors := []error{}
 		for brick, cfg := range rc.BricksConfigs_ {
 			for k, v := range cfg {
@@ -410,18 +545,7 @@ func applyPolicy(rc *envConfig, policy guardrails.Policy) error {
 					continue
 				}
 				for _, p := range brickMounts {
-					allowed := false
-					if guardrails.IsAbsolutelyForbidden(p) {
-						allowed = false
-						break
-					}
-					for _, allowedMount := range policy.AllowedMounts() {
-						if guardrails.IsUnderPrefix(allowedMount, p) {
-							allowed = true
-							break
-						}
-					}
-					if !allowed {
+					if guardrails.IsAbsolutelyForbidden(p) || !policy.AllowMount(p) {
 						errors = append(errors, fmt.Errorf("mount path request from %s does not allowed by policy. (path %s is not under any allowed paths)", brick, p))
 					}
 				}
@@ -429,18 +553,7 @@ func applyPolicy(rc *envConfig, policy guardrails.Policy) error {
 		}
 		for _, vol := range rc.Volumes_ {
 			binds := strings.Split(vol, ":")
-			allowed := false
-			if guardrails.IsAbsolutelyForbidden(binds[0]) {
-				allowed = false
-				break
-			}
-			for _, allowedMount := range policy.AllowedMounts() {
-				if guardrails.IsUnderPrefix(allowedMount, binds[0]) {
-					allowed = true
-					break
-				}
-			}
-			if !allowed {
+			if !policy.AllowMount(binds[0]) {
 				errors = append(errors, fmt.Errorf("mount path request from EnvConfig does not allowed by policy. (path %s is not under any allowed paths)", binds[0]))
 			}
 		}
@@ -452,6 +565,19 @@ func applyPolicy(rc *envConfig, policy guardrails.Policy) error {
 	return nil
 }
 
+// loadUserBricks returns the bricks of the user's bricks folder as an env config.
+func loadUserBricks() (*envConfig, error) {
+	dir := hostappconfig.UserBricksPath()
+	defs, err := bricksengine.LoadBrickDefinitions(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user bricks: %w", err)
+	}
+	cfg := buildDefaultEnvConfig()
+	cfg.name = dir
+	cfg.Bricks_ = defs
+	return cfg, nil
+}
+
 func (p *Project) SetEnvConfigOverride(ec EnvConfig) {
 	// TODO: should we check if the env config already resolved?
 	// it won't take effect if env config resolved already
@@ -459,10 +585,11 @@ func (p *Project) SetEnvConfigOverride(ec EnvConfig) {
 }
 
 func (p *Project) resolveEnvConfig(ctx context.Context) error {
-	policy, err := guardrails.LoadPolicy()
+	globalPolicy, err := guardrails.LoadPolicy()
 	if err != nil {
 		return err
 	}
+	policy := globalPolicy.ForProject(p.Path())
 
 	err = ensureProjectPathIsSafe(ctx, policy, p)
 	if err != nil {
@@ -475,20 +602,48 @@ func (p *Project) resolveEnvConfig(ctx context.Context) error {
 	}
 
 	envCfg := buildDefaultEnvConfig()
-	// TODO: restrict volumes config in project .mkenv -
-	// there is absolutely zero legit reasons to let project deside which folders to mount other than project folder
-	// which is automatically mounted
+
+	userBricks, err := loadUserBricks()
+	if err != nil {
+		return err
+	}
+	envCfg.Merge(userBricks)
+
+	configSources := []brickConfigSource{}
 	for _, prefPath := range prefsChain {
-		pref, errLoadPrefs := loadPreferencesFile(prefPath)
+		data, errRead := os.ReadFile(prefPath)
+		if errRead != nil {
+			return errRead
+		}
+
+		// .mkenv files come with cloned repositories, the user approves every new or changed file.
+		if err := p.ensureMkenvFileTrusted(ctx, prefPath, data); err != nil {
+			return err
+		}
+
+		pref, errLoadPrefs := parsePreferencesFile(prefPath, data)
 		if errLoadPrefs != nil {
 			return errLoadPrefs
 		}
 
+		// files inside the project belong to the repository, only files above it are the user's own.
+		if len(pref.Volumes_) > 0 && guardrails.IsUnderPrefix(p.Path(), filepath.Dir(prefPath)) && !policy.AllowProjectVolumes() {
+			logs.Warnf("Ignoring volumes %s requested by %s: project .mkenv files can't mount host folders unless the policy sets allow_project_volumes", strings.Join(pref.Volumes_, ", "), prefPath)
+			pref.Volumes_ = nil
+		}
+
 		envCfg.Merge(pref)
+		configSources = append(configSources, brickConfigSource{name: prefPath, data: data, configs: pref.BricksConfigs_})
 	}
 
 	if p.envConfigOverride != nil {
 		envCfg.Merge(p.envConfigOverride)
+		configSources = append(configSources, brickConfigSource{name: brickConfigFlagSource, configs: p.envConfigOverride.BricksConfigs()})
+	}
+
+	// runs on every start: accepted findings are remembered, so only new ones are asked
+	if err := p.ensureNoPendingSecrets(ctx, policy, envCfg); err != nil {
+		return err
 	}
 
 	err = applyPolicy(envCfg, policy)
@@ -496,6 +651,14 @@ func (p *Project) resolveEnvConfig(ctx context.Context) error {
 		return err
 	}
 
+	// custom bricks are registered before bricks_config is checked against the schemas of all bricks
+	if err := RegisterCustomBricks(ctx, bricksengine.DefaultBricksRegistry, policy, envCfg.Bricks_); err != nil {
+		return err
+	}
+	if err := validateBricksConfig(bricksengine.DefaultBricksRegistry, configSources, policy); err != nil {
+		return fmt.Errorf("invalid bricks_config:\n%w", err)
+	}
+
 	if !p.Known() {
 		ok, err := logs.PromptConfirm("You run this project for the first time. Continue?")
 		if err != nil {
@@ -0,0 +1,151 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+)
+
+// reservedEnvPrefix is used by mkenv internal variables (control plane, reverse proxy). Users can't override them.
+const reservedEnvPrefix = "MKENV_"
+
+var envVarNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvVar describes a value of a single environment variable from the `env` section of .mkenv.
+// Exactly one source must be set:
+//
+//	"env": {
+//	  "APP_ENV": "dev",                                // literal (shorthand for {"value": "dev"})
+//	  "LOG_LEVEL": {"value": "debug"},                 // literal
+//	  "GITHUB_TOKEN": {"from_host": "GITHUB_TOKEN"},   // passthrough of the host variable
+//	  "NPM_TOKEN": {"from_file": "~/.config/npm/token"} // content of the host file
+//	}
+//
+// Values are resolved on the host when the container starts, so they never land in image layers.
+type EnvVar struct {
+	Value    string `json:"value,omitempty"`
+	FromHost string `json:"from_host,omitempty"`
+	FromFile string `json:"from_file,omitempty"`
+}
+
+func (ev *EnvVar) UnmarshalJSON(data []byte) error {
+	var literal string
+	if err := json.Unmarshal(data, &literal); err == nil {
+		*ev = EnvVar{Value: literal}
+		return nil
+	}
+
+	type envVarAlias EnvVar
+	var alias envVarAlias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	*ev = EnvVar(alias)
+	return nil
+}
+
+func (ev EnvVar) validate(name string) error {
+	if !envVarNameRe.MatchString(name) {
+		return fmt.Errorf("invalid env variable name %q", name)
+	}
+	if strings.HasPrefix(strings.ToUpper(name), reservedEnvPrefix) {
+		return fmt.Errorf("env variable %s is reserved by mkenv", name)
+	}
+
+	sources := 0
+	if ev.Value != "" {
+		sources++
+	}
+	if ev.FromHost != "" {
+		sources++
+	}
+	if ev.FromFile != "" {
+		sources++
+	}
+	if sources > 1 {
+		return fmt.Errorf("env variable %s must have only one of value, from_host or from_file", name)
+	}
+
+	return nil
+}
+
+// resolve returns the value of the variable. ok is false if the host variable is not set.
+func (ev EnvVar) resolve(name string) (value string, ok bool, err error) {
+	switch {
+	case ev.FromHost != "":
+		value, ok = os.LookupEnv(ev.FromHost)
+		return value, ok, nil
+	case ev.FromFile != "":
+		path, err := expandHome(ev.FromFile)
+		if err != nil {
+			return "", false, err
+		}
+		if guardrails.IsAbsolutelyForbidden(path) {
+			return "", false, fmt.Errorf("env variable %s: reading %s is not allowed by mkenv", name, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("env variable %s: %w", name, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	default:
+		return ev.Value, true, nil
+	}
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// ResolveContainerEnv resolves the `env` section of the env config into KEY=VALUE pairs
+// to be passed to the container on start. Passthrough of unset host variables is skipped.
+func ResolveContainerEnv(ec EnvConfig) ([]string, error) {
+	vars := ec.Env()
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	envs := make([]string, 0, len(names))
+	errs := []error{}
+	for _, name := range names {
+		ev := vars[name]
+		if err := ev.validate(name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		value, ok, err := ev.resolve(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			logs.Debugf("host env variable %s is not set. Skipping %s", ev.FromHost, name)
+			continue
+		}
+		envs = append(envs, name+"="+value)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return envs, nil
+}
@@ -0,0 +1,169 @@
+// Tests in this file exercise the `env` section of .mkenv: parsing, validation and policy checks.
+package runtime
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/0xa1bed0/mkenv/internal/bricksengine"
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+)
+
+func TestParseEnvSection(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app", ".mkenv")
+	data := []byte(`{"env": {
+		"APP_ENV": "dev",
+		"LOG_LEVEL": {"value": "debug"},
+		"GITHUB_TOKEN": {"from_host": "GH_TOKEN"},
+		"REL_TOKEN": {"from_file": "secrets/token"},
+		"UP_TOKEN": {"from_file": "../token"},
+		"ABS_TOKEN": {"from_file": "/etc/mkenv/token"},
+		"HOME_TOKEN": {"from_file": "~/.config/npm/token"}
+	}}`)
+
+	cfg, err := parsePreferencesFile(path, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want EnvVar
+	}{
+		{"APP_ENV", EnvVar{Value: "dev"}},
+		{"LOG_LEVEL", EnvVar{Value: "debug"}},
+		{"GITHUB_TOKEN", EnvVar{FromHost: "GH_TOKEN"}},
+		// relative from_file is resolved against the folder of the .mkenv file
+		{"REL_TOKEN", EnvVar{FromFile: filepath.Join(filepath.Dir(path), "secrets", "token")}},
+		{"UP_TOKEN", EnvVar{FromFile: filepath.Join(filepath.Dir(filepath.Dir(path)), "token")}},
+		{"ABS_TOKEN", EnvVar{FromFile: "/etc/mkenv/token"}},
+		// home is expanded when the value is resolved
+		{"HOME_TOKEN", EnvVar{FromFile: "~/.config/npm/token"}},
+	}
+	if len(cfg.Env_) != len(tests) {
+		t.Fatalf("expected %d env variables, got %v", len(tests), cfg.Env_)
+	}
+	for _, tt := range tests {
+		if got := cfg.Env_[tt.name]; got != tt.want {
+			t.Fatalf("%s = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := parsePreferencesFile(path, []byte(`{"env": {"APP_ENV": 42}}`)); err == nil {
+		t.Fatalf("expected a non-string value to fail")
+	}
+}
+
+func TestEnvVarValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ev      EnvVar
+		wantErr string
+	}{
+		{"APP_ENV", EnvVar{Value: "dev"}, ""},
+		{"_private", EnvVar{FromHost: "HOME"}, ""},
+		{"EMPTY", EnvVar{}, ""},
+		{"MKENV_CONTROL", EnvVar{Value: "x"}, "reserved by mkenv"},
+		{"mkenv_reverse_proxy", EnvVar{Value: "x"}, "reserved by mkenv"},
+		{"MKENVIRONMENT", EnvVar{Value: "x"}, ""},
+		{"1ST", EnvVar{Value: "x"}, "invalid env variable name"},
+		{"APP-ENV", EnvVar{Value: "x"}, "invalid env variable name"},
+		{"TOKEN", EnvVar{Value: "x", FromHost: "TOKEN"}, "only one of"},
+		{"TOKEN", EnvVar{FromHost: "TOKEN", FromFile: "token"}, "only one of"},
+	}
+	for _, tt := range tests {
+		err := tt.ev.validate(tt.name)
+		if tt.wantErr == "" && err != nil {
+			t.Fatalf("%s %+v: unexpected error: %v", tt.name, tt.ev, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("%s %+v: expected error containing %q, got %v", tt.name, tt.ev, tt.wantErr, err)
+		}
+	}
+}
+
+func TestResolveContainerEnv(t *testing.T) {
+	t.Setenv("MKENV_TEST_HOST_VAR", "from-host")
+
+	cfg := buildDefaultEnvConfig()
+	cfg.Env_ = map[string]EnvVar{
+		"APP_ENV":  {Value: "dev"},
+		"HOST_VAR": {FromHost: "MKENV_TEST_HOST_VAR"},
+		"UNSET":    {FromHost: "MKENV_TEST_UNSET_VAR"},
+	}
+	envs, err := ResolveContainerEnv(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"APP_ENV=dev", "HOST_VAR=from-host"}; !slices.Equal(envs, want) {
+		t.Fatalf("ResolveContainerEnv = %v, want %v", envs, want)
+	}
+
+	for _, env := range []map[string]EnvVar{
+		{"MKENV_CONTROL_API": {Value: "evil"}},
+		{"PASSWD": {FromFile: "/etc/passwd"}},
+	} {
+		cfg.Env_ = env
+		if _, err := ResolveContainerEnv(cfg); err == nil {
+			t.Fatalf("%v: expected an error", env)
+		}
+	}
+}
+
+// envPolicy is a policy that only denies passthrough of host variables matching denied.
+type envPolicy struct {
+	guardrails.Policy
+	denied []string
+}
+
+func (p envPolicy) EnableBricks() []bricksengine.BrickID                      { return nil }
+func (p envPolicy) DisableBricks() []bricksengine.BrickID                     { return nil }
+func (p envPolicy) DisableAuto() bool                                         { return false }
+func (p envPolicy) BricksConfigs() map[bricksengine.BrickID]map[string]string { return nil }
+func (p envPolicy) AllowCustomBrick(bricksengine.BrickID) bool                { return true }
+func (p envPolicy) EgressEnforced() bool                                      { return false }
+func (p envPolicy) MountsRestricted() bool                                    { return false }
+
+func (p envPolicy) AllowEnvPassthrough(name string) bool {
+	for _, pattern := range p.denied {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return false
+		}
+	}
+	return true
+}
+
+func TestApplyPolicyDeniesEnvPassthrough(t *testing.T) {
+	t.Parallel()
+
+	policy := envPolicy{denied: []string{"AWS_*", "GITHUB_TOKEN"}}
+	tests := []struct {
+		env     map[string]EnvVar
+		wantErr string
+	}{
+		{map[string]EnvVar{"APP_ENV": {Value: "dev"}, "NPM_TOKEN": {FromHost: "NPM_TOKEN"}}, ""},
+		// the host variable is checked, not the name it gets in the container
+		{map[string]EnvVar{"TOKEN": {FromHost: "GITHUB_TOKEN"}}, "GITHUB_TOKEN (requested as TOKEN) is not allowed by policy"},
+		{map[string]EnvVar{"AWS_SECRET_ACCESS_KEY": {FromHost: "AWS_SECRET_ACCESS_KEY"}}, "AWS_SECRET_ACCESS_KEY"},
+		// literal values and files are not passthrough
+		{map[string]EnvVar{"AWS_REGION": {Value: "eu-west-1"}}, ""},
+		{map[string]EnvVar{"MKENV_CONTROL_API": {Value: "evil"}}, "reserved by mkenv"},
+	}
+	for _, tt := range tests {
+		cfg := buildDefaultEnvConfig()
+		cfg.Env_ = tt.env
+		err := applyPolicy(cfg, policy)
+		if tt.wantErr == "" && err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.env, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("%v: expected error containing %q, got %v", tt.env, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package runtime
+
+import "fmt"
+
+// ExitError is returned by commands that want mkenv to exit with a specific code
+// (e.g. to propagate exit code of a command executed in the sandbox).
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
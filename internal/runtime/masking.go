@@ -0,0 +1,71 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// addMaskedPaths adds patterns to masked_paths of the .mkenv file in the project root, creating the file if needed.
+// Other settings of the file are kept. If the file was trusted before, the new content is trusted too.
+func (p *Project) addMaskedPaths(ctx context.Context, patterns []string) error {
+	// TODO: put .mkenv filename to constants
+	path := filepath.Join(p.Path(), ".mkenv")
+
+	fields := map[string]json.RawMessage{}
+	trusted := true
+	mode := os.FileMode(0o644)
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("failed to parse preferences %s: %w", path, err)
+		}
+		pin, err := p.stateDB.mkenvFilePin(ctx, path)
+		if err != nil {
+			return err
+		}
+		trusted = pin != nil && pin.Hash == mkenvFileHash(data)
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+	case os.IsNotExist(err):
+	default:
+		return err
+	}
+
+	masked := []string{}
+	if raw, ok := fields["masked_paths"]; ok {
+		if err := json.Unmarshal(raw, &masked); err != nil {
+			return fmt.Errorf("failed to parse masked_paths of %s: %w", path, err)
+		}
+	}
+	for _, pattern := range patterns {
+		if !slices.Contains(masked, pattern) {
+			masked = append(masked, pattern)
+		}
+	}
+	raw, err := json.Marshal(masked)
+	if err != nil {
+		return err
+	}
+	fields["masked_paths"] = raw
+
+	out, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if err := os.WriteFile(path, out, mode); err != nil {
+		return err
+	}
+
+	if trusted {
+		p.stateDB.pinMkenvFile(ctx, path, mkenvFilePin{Hash: mkenvFileHash(out), Content: string(out)})
+	}
+	return nil
+}
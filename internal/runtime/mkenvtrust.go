@@ -0,0 +1,133 @@
+package runtime
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/state"
+	"github.com/0xa1bed0/mkenv/internal/utils"
+)
+
+// mkenvFilePin is the content of a .mkenv file the user approved.
+// The content is kept to show what changed when the file changes.
+type mkenvFilePin struct {
+	Hash    string `json:"hash"`
+	Content string `json:"content"`
+}
+
+func (st *projectStateDB) deriveMkenvFileKey(path string) state.KVStoreKey {
+	return state.KVStoreKey("mkenv-file:" + path)
+}
+
+// mkenvFilePin returns the approved content of the .mkenv file, nil if the file was never approved.
+func (st *projectStateDB) mkenvFilePin(ctx context.Context, path string) (*mkenvFilePin, error) {
+	if st == nil || st.kvStore == nil {
+		logs.Debugf("[projectState:mkenvFilePin] no state provided. Assuming %s is not approved", path)
+		return nil, nil
+	}
+
+	entry, found, err := st.kvStore.Get(ctx, st.deriveMkenvFileKey(path))
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var pin mkenvFilePin
+	if err := json.Unmarshal([]byte(entry.Value), &pin); err != nil {
+		logs.Warnf("[projectState:mkenvFilePin] broken approval of %s, asking again: %v", path, err)
+		return nil, nil
+	}
+	return &pin, nil
+}
+
+func (st *projectStateDB) pinMkenvFile(ctx context.Context, path string, pin mkenvFilePin) {
+	if st == nil || st.kvStore == nil {
+		logs.Warnf("[projectState:pinMkenvFile] can't remember approval of %s because no database provided. Skipping", path)
+		return
+	}
+
+	data, err := json.Marshal(pin)
+	if err != nil {
+		logs.Warnf("[projectState:pinMkenvFile] can't remember approval of %s: %v", path, err)
+		return
+	}
+	if err := st.kvStore.Upsert(ctx, st.deriveMkenvFileKey(path), string(data)); err != nil {
+		logs.Warnf("[projectState:pinMkenvFile] can't remember approval of %s: %v", path, err)
+	}
+}
+
+// mkenvFileTrusted returns true if exactly this content of the .mkenv file was approved before.
+func (p *Project) mkenvFileTrusted(ctx context.Context, path string, data []byte) (bool, error) {
+	pin, err := p.stateDB.mkenvFilePin(ctx, path)
+	if err != nil || pin == nil {
+		return false, err
+	}
+	return pin.Hash == mkenvFileHash(data), nil
+}
+
+// ensureMkenvFileTrusted asks the user to approve the .mkenv file content unless exactly this content was approved before.
+// A changed file is shown as a diff against the approved version.
+func (p *Project) ensureMkenvFileTrusted(ctx context.Context, path string, data []byte) error {
+	hash := mkenvFileHash(data)
+
+	pin, err := p.stateDB.mkenvFilePin(ctx, path)
+	if err != nil {
+		return err
+	}
+	if pin != nil && pin.Hash == hash {
+		return nil
+	}
+
+	ok, err := logs.PromptConfirm(mkenvTrustPrompt(path, data, pin))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf(".mkenv file %s is not trusted", path)
+	}
+
+	p.stateDB.pinMkenvFile(ctx, path, mkenvFilePin{Hash: hash, Content: string(data)})
+	return nil
+}
+
+// mkenvTrustPrompt returns the question asking to trust the .mkenv file content data, shown as a diff if pin is set.
+// The file comes from the repository: control characters are stripped from every line, so escape sequences
+// or carriage returns can't hide lines (volumes, root_run...) from the text the user approves.
+func mkenvTrustPrompt(path string, data []byte, pin *mkenvFilePin) string {
+	path = utils.StripControlChars(path)
+	if pin == nil {
+		return fmt.Sprintf("The .mkenv file %s is not trusted yet. It configures the sandbox:\n\n%s\nTrust it?", path, indentLines(sanitizeLines(string(data))))
+	}
+	return fmt.Sprintf("The .mkenv file %s changed since you trusted it:\n\n%s\nTrust the new version?", path, indentLines(sanitizeLines(utils.LineDiff(pin.Content, string(data)))))
+}
+
+// sanitizeLines strips control characters from every line of s, tabs are kept as spaces.
+func sanitizeLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = utils.StripControlChars(strings.ReplaceAll(line, "\t", "    "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func mkenvFileHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func indentLines(s string) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line != "" {
+			sb.WriteString("\t" + line)
+		}
+	}
+	if !strings.HasSuffix(s, "\n") {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,79 @@
+// Tests in this file exercise pinning of trusted .mkenv files.
+package runtime
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMkenvFilePins(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	p := &Project{name: "app", path: t.TempDir(), stateDB: newTestStateDB(t)}
+	path := filepath.Join(p.Path(), ".mkenv")
+	data := []byte(`{"enabled_bricks": ["go"]}`)
+
+	if pin, err := p.stateDB.mkenvFilePin(ctx, path); err != nil || pin != nil {
+		t.Fatalf("expected no pin, got %v %v", pin, err)
+	}
+	if trusted, err := p.mkenvFileTrusted(ctx, path, data); err != nil || trusted {
+		t.Fatalf("expected a new file not to be trusted, got %v %v", trusted, err)
+	}
+
+	p.stateDB.pinMkenvFile(ctx, path, mkenvFilePin{Hash: mkenvFileHash(data), Content: string(data)})
+	pin, err := p.stateDB.mkenvFilePin(ctx, path)
+	if err != nil || pin == nil || pin.Content != string(data) {
+		t.Fatalf("expected the pinned content back, got %v %v", pin, err)
+	}
+	if trusted, err := p.mkenvFileTrusted(ctx, path, data); err != nil || !trusted {
+		t.Fatalf("expected the pinned file to be trusted, got %v %v", trusted, err)
+	}
+	// a single changed byte is a different file
+	if trusted, _ := p.mkenvFileTrusted(ctx, path, []byte(`{"enabled_bricks": ["go"] }`)); trusted {
+		t.Fatalf("expected a changed file not to be trusted")
+	}
+	// pins are per path
+	if trusted, _ := p.mkenvFileTrusted(ctx, filepath.Join(p.Path(), "app", ".mkenv"), data); trusted {
+		t.Fatalf("expected the same content at another path not to be trusted")
+	}
+
+	// a broken pin asks again instead of failing
+	if err := p.stateDB.kvStore.Upsert(ctx, p.stateDB.deriveMkenvFileKey(path), "not json"); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if pin, err := p.stateDB.mkenvFilePin(ctx, path); err != nil || pin != nil {
+		t.Fatalf("expected a broken pin to be ignored, got %v %v", pin, err)
+	}
+
+	// without a state store nothing is trusted
+	noState := &Project{name: "app", path: p.Path()}
+	noState.stateDB.pinMkenvFile(ctx, path, mkenvFilePin{Hash: mkenvFileHash(data), Content: string(data)})
+	if trusted, err := noState.mkenvFileTrusted(ctx, path, data); err != nil || trusted {
+		t.Fatalf("expected nothing to be trusted without a store, got %v %v", trusted, err)
+	}
+}
+
+func TestMkenvTrustPromptStripsControlChars(t *testing.T) {
+	t.Parallel()
+
+	// the volumes line is erased and overwritten on a terminal which interprets the escapes
+	data := []byte("{\n\t\"enabled_bricks\": [\"go\"],\n\t\"volumes\": [\"~/.ssh:/ssh\"],\x1b[1A\x1b[2K\r\x1b]0;title\x07\n\t\"extra_pkgs\": [\"curl\"]\u202e\n}\n")
+	pin := &mkenvFilePin{Hash: "old", Content: "{\n\t\"enabled_bricks\": [\"go\"]\n}\n"}
+
+	for _, text := range []string{
+		mkenvTrustPrompt("/repo/.mkenv", data, nil),
+		mkenvTrustPrompt("/repo/\x1b[8m/.mkenv", data, pin),
+	} {
+		if strings.ContainsAny(text, "\x1b\r\x07\u202e") {
+			t.Fatalf("expected control characters to be stripped, got %q", text)
+		}
+		for _, want := range []string{`"volumes": ["~/.ssh:/ssh"]`, `"extra_pkgs": ["curl"]`} {
+			if !strings.Contains(text, want) {
+				t.Fatalf("expected %s in the prompt, got %q", want, text)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/0xa1bed0/mkenv/internal/fswalk"
+	"github.com/0xa1bed0/mkenv/internal/guardrails"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/ui"
+)
+
+const (
+	sensitiveFilesMount = "mount"
+	sensitiveFilesMask  = "mask"
+	sensitiveFilesAbort = "abort"
+)
+
+// SecretScanResult is the outcome of the project secret scan.
+type SecretScanResult struct {
+	Pending  []*guardrails.SensitivityWarning // findings the user hasn't decided on
+	Accepted []*guardrails.SensitivityWarning // findings in the project allowlist
+	Masked   []*guardrails.SensitivityWarning // findings in masked paths, the sandbox doesn't see them
+}
+
+// SecretScanAllowlist returns the findings the user accepted for the project.
+func (p *Project) SecretScanAllowlist() *guardrails.ScanAllowlist {
+	if p.stateDB == nil {
+		return guardrails.NewScanAllowlist(nil, p.Name())
+	}
+	return guardrails.NewScanAllowlist(p.stateDB.kvStore, p.Name())
+}
+
+// ScanSecrets scans the project with the rules of the policy and the .mkenv files of the project.
+// The .mkenv files are read without asking the user to trust them, see scanEnvConfig.
+func (p *Project) ScanSecrets(ctx context.Context) (*SecretScanResult, error) {
+	globalPolicy, err := guardrails.LoadPolicy()
+	if err != nil {
+		return nil, err
+	}
+	policy := globalPolicy.ForProject(p.Path())
+
+	prefsChain, err := resolvePreferencesChain(p.Path())
+	if err != nil {
+		return nil, err
+	}
+	envCfg, err := p.scanEnvConfig(ctx, prefsChain)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.scanSecrets(ctx, policy, envCfg)
+}
+
+// scanEnvConfig merges the scanner settings of the .mkenv files at paths. Rules of every file are used, they
+// can only add findings. masked_paths hide findings, so they are only used from files the user trusts (pinned):
+// otherwise a change to the repository could mask its own secrets and pass the scan in CI.
+func (p *Project) scanEnvConfig(ctx context.Context, paths []string) (*envConfig, error) {
+	envCfg := buildDefaultEnvConfig()
+	for _, prefPath := range paths {
+		data, err := os.ReadFile(prefPath)
+		if err != nil {
+			return nil, err
+		}
+		pref, err := parsePreferencesFile(prefPath, data)
+		if err != nil {
+			return nil, err
+		}
+		if len(pref.MaskedPaths_) > 0 {
+			trusted, err := p.mkenvFileTrusted(ctx, prefPath, data)
+			if err != nil {
+				return nil, err
+			}
+			if !trusted {
+				logs.Warnf("masked_paths of %s are ignored: the file is not trusted (run mkenv in the project to trust it)", prefPath)
+				pref.MaskedPaths_ = nil
+			}
+		}
+		envCfg.Merge(pref)
+	}
+	return envCfg, nil
+}
+
+func (p *Project) scanSecrets(ctx context.Context, policy guardrails.Policy, envCfg EnvConfig) (*SecretScanResult, error) {
+	scanner, err := guardrails.NewScanner(append(policy.SecretScan(), envCfg.SecretScan())...)
+	if err != nil {
+		return nil, err
+	}
+	// both scans walk the whole project, the shared walker lists it once
+	walker := fswalk.New()
+	warnings, err := scanner.Scan(ctx, walker, p.Path())
+	if err != nil {
+		return nil, err
+	}
+	linkWarnings, err := guardrails.ScanLinkEscapes(ctx, walker, p.Path())
+	if err != nil {
+		return nil, err
+	}
+	warnings = append(warnings, linkWarnings...)
+
+	result := &SecretScanResult{Masked: []*guardrails.SensitivityWarning{}}
+	unmasked := []*guardrails.SensitivityWarning{}
+	masks := envCfg.MaskedPaths()
+	for _, warn := range warnings {
+		// hardlinks are masked like files, symlinks can't be masked
+		if warn.Rule != guardrails.ScanRuleSymlink && guardrails.IsMaskedFile(masks, warn.RelPath) {
+			result.Masked = append(result.Masked, warn)
+			continue
+		}
+		unmasked = append(unmasked, warn)
+	}
+
+	result.Pending, result.Accepted, err = p.SecretScanAllowlist().Filter(ctx, unmasked)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ensureNoPendingSecrets scans the project and asks the user what to do with new findings:
+// accept them, mask them or abort. Accepted findings are not reported again.
+func (p *Project) ensureNoPendingSecrets(ctx context.Context, policy guardrails.Policy, envCfg *envConfig) error {
+	logs.Infof("Scanning files of %s for secrets...", p.Path())
+	result, err := p.scanSecrets(ctx, policy, envCfg)
+	if err != nil {
+		return err
+	}
+	if len(result.Pending) == 0 {
+		return nil
+	}
+
+	text := "It looks like the project folder contain potentially sensitive files. If you continue they will be mounted to the sandbox: \n\n"
+	for _, warn := range result.Pending {
+		location := warn.Path
+		if warn.Line > 0 {
+			location = fmt.Sprintf("%s:%d", warn.Path, warn.Line)
+		}
+		text += "\n\t" + "- " + location + " - " + warn.Reason + "\n"
+		if len(warn.Content) > 0 {
+			text += "\n"
+		}
+		for _, line := range warn.Content {
+			text += "\t\t" + line + "\n"
+		}
+		text += "\n"
+	}
+	text += "\n"
+	text += "It looks like the project folder contain potentially sensitive files. If you mount them they will be visible in the sandbox. Please scroll up and review all of them before choosing\n"
+	selected, err := logs.PromptSelectOne(text, []ui.SelectOption{
+		logs.NewSelectOption("Mount them and don't warn about these findings again", sensitiveFilesMount),
+		logs.NewSelectOption("Mask them: the sandbox sees empty files (saved to masked_paths in .mkenv)", sensitiveFilesMask),
+		logs.NewSelectOption("Abort", sensitiveFilesAbort),
+	})
+	if err != nil {
+		return err
+	}
+	switch selected.OptionID() {
+	case sensitiveFilesMount:
+		if err := p.SecretScanAllowlist().Add(ctx, result.Pending...); err != nil {
+			logs.Warnf("Failed to remember accepted findings, you will be asked again: %v", err)
+		}
+	case sensitiveFilesMask:
+		patterns := []string{}
+		for _, warn := range result.Pending {
+			if info, err := os.Lstat(warn.Path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				// bind mounts can't hide a symlink, the sandbox resolves it in its own filesystem
+				logs.Warnf("%s is a symlink and can't be masked, remove it from the project or abort", warn.Path)
+				continue
+			}
+			pattern := "/" + filepath.ToSlash(warn.RelPath)
+			if !slices.Contains(patterns, pattern) {
+				patterns = append(patterns, pattern)
+			}
+		}
+		if err := p.addMaskedPaths(ctx, patterns); err != nil {
+			return fmt.Errorf("save masked paths: %w", err)
+		}
+		// the .mkenv file is already loaded, mask the paths in this run too
+		masked := buildDefaultEnvConfig()
+		masked.name = "secret scan"
+		masked.MaskedPaths_ = patterns
+		envCfg.Merge(masked)
+	default:
+		return errors.New("user prompt failed")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Tests in this file exercise which .mkenv settings the secret scan uses.
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/0xa1bed0/mkenv/internal/state"
+)
+
+// newTestStateDB returns a project state backed by a database in a temp folder.
+func newTestStateDB(t *testing.T) *projectStateDB {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	db, err := state.Open(ctx, state.Config{Path: filepath.Join(t.TempDir(), "state.db")})
+	if err != nil {
+		t.Fatalf("state.Open: %v", err)
+	}
+	kvStore, err := state.NewKVStore(ctx, db)
+	if err != nil {
+		t.Fatalf("NewKVStore: %v", err)
+	}
+	return newProjectStateDB(kvStore)
+}
+
+func TestScanEnvConfigIgnoresUntrustedMasks(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	trustedPath := filepath.Join(root, ".mkenv")
+	untrustedPath := filepath.Join(root, "app", ".mkenv")
+	if err := os.MkdirAll(filepath.Dir(untrustedPath), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	trusted := []byte(`{"masked_paths": ["/secrets.env"]}`)
+	untrusted := []byte(`{"masked_paths": ["**"]}`)
+	if err := os.WriteFile(trustedPath, trusted, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(untrustedPath, untrusted, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	ctx := context.Background()
+	p := &Project{name: "app", path: filepath.Dir(untrustedPath), stateDB: newTestStateDB(t)}
+	p.stateDB.pinMkenvFile(ctx, trustedPath, mkenvFilePin{Hash: mkenvFileHash(trusted), Content: string(trusted)})
+
+	envCfg, err := p.scanEnvConfig(ctx, []string{trustedPath, untrustedPath})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := envCfg.MaskedPaths(); !slices.Equal(got, []string{"/secrets.env"}) {
+		t.Fatalf("MaskedPaths = %v, want only the trusted mask", got)
+	}
+
+	// a trusted file changed by a pull request is not trusted anymore
+	if err := os.WriteFile(trustedPath, []byte(`{"masked_paths": ["/secrets.env", "/.env"]}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	envCfg, err = p.scanEnvConfig(ctx, []string{trustedPath, untrustedPath})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := envCfg.MaskedPaths(); len(got) != 0 {
+		t.Fatalf("MaskedPaths = %v, want none", got)
+	}
+}
@@ -49,8 +49,19 @@ type Runtime struct {
 	// logWriter is the destination for log entries (host only).
 	// Used by orchestrator to write forwarded agent logs directly.
 	logWriter io.Writer
+
+	// approver asks the user on the host to approve sensitive sandbox requests
+	approver Approver
+	chooser  Chooser
+	deniedAt map[string]time.Time // request kind => when the user last denied it
 }
 
+// Approver asks the user to approve a sensitive request coming from the sandbox (e.g. SSH signature).
+type Approver func(ctx context.Context, text string) (bool, error)
+
+// Chooser asks the user to pick one of choices about a sandbox request and returns the ID of the picked one.
+type Chooser func(ctx context.Context, text string, choices []Choice) (string, error)
+
 func (rt *Runtime) Type() RuntimeType {
 	return rt.t
 }
@@ -87,6 +98,87 @@ func (rt *Runtime) Term() *TerminalGuard {
 	return rt.term
 }
 
+// SetApprover sets who is asked to approve sensitive sandbox requests.
+func (rt *Runtime) SetApprover(approver Approver) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.approver = approver
+}
+
+// Approve asks the approver to approve the request. Requests are denied if there is nobody to ask.
+func (rt *Runtime) Approve(ctx context.Context, text string) bool {
+	rt.mu.Lock()
+	approver := rt.approver
+	rt.mu.Unlock()
+
+	if approver == nil {
+		logs.Warnf("nobody to approve the request, denied: %s", text)
+		return false
+	}
+
+	approved, err := approver(ctx, text)
+	if err != nil {
+		logs.Warnf("request is denied: %s: %v", text, err)
+		return false
+	}
+	return approved
+}
+
+// approvalCooldown is how long requests of a kind are denied without asking after the user denies one.
+const approvalCooldown = 30 * time.Second
+
+// ApproveKind is Approve for requests the sandbox can repeat at will (e.g. SSH signatures). After the user denies
+// a request of kind, requests of the same kind are denied without asking for approvalCooldown,
+// so the sandbox can't keep the question up until a stray key press approves it.
+func (rt *Runtime) ApproveKind(ctx context.Context, kind, text string) bool {
+	rt.mu.Lock()
+	deniedAt, denied := rt.deniedAt[kind]
+	rt.mu.Unlock()
+
+	if denied && time.Since(deniedAt) < approvalCooldown {
+		logs.Warnf("denied without asking, a %s request was denied less than %s ago: %s", kind, approvalCooldown, text)
+		return false
+	}
+
+	approved := rt.Approve(ctx, text)
+	if !approved {
+		rt.mu.Lock()
+		if rt.deniedAt == nil {
+			rt.deniedAt = map[string]time.Time{}
+		}
+		rt.deniedAt[kind] = time.Now()
+		rt.mu.Unlock()
+	}
+	return approved
+}
+
+// SetChooser sets who is asked to pick answers about sandbox requests.
+func (rt *Runtime) SetChooser(chooser Chooser) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.chooser = chooser
+}
+
+// Choose asks the chooser to pick one of choices. An empty ID is returned if there is nobody to ask
+// or the user gives no valid answer, callers must treat it as a denial.
+func (rt *Runtime) Choose(ctx context.Context, text string, choices []Choice) string {
+	rt.mu.Lock()
+	chooser := rt.chooser
+	rt.mu.Unlock()
+
+	if chooser == nil {
+		logs.Warnf("nobody to answer the request, denied: %s", text)
+		return ""
+	}
+
+	id, err := chooser(ctx, text, choices)
+	if err != nil {
+		logs.Warnf("request is denied: %s: %v", text, err)
+		return ""
+	}
+	return id
+}
+
 type runtimeKey struct{}
 
 func NewHostRuntime() *Runtime {
@@ -277,6 +369,13 @@ func (rt *Runtime) Finalize(appName, helpHint string, execErr *error) {
 	rt.CancelCtx()
 	waitErr := rt.Wait()
 
+	// propagate exit code as is, it is not a failure of mkenv itself
+	var exitErr *ExitError
+	if execErr != nil && errors.As(*execErr, &exitErr) {
+		logs.Close()
+		os.Exit(exitErr.ExitCode())
+	}
+
 	// log first failure if any
 	if execErr != nil && *execErr != nil {
 		logs.Errorf("%s error: %v", appName, *execErr)
@@ -0,0 +1,38 @@
+// Tests in this file exercise asking the user to approve sandbox requests.
+package runtime
+
+import (
+	"context"
+	"testing"
+)
+
+func TestApproveKindCooldown(t *testing.T) {
+	t.Parallel()
+
+	asked := 0
+	answer := false
+	rt := &Runtime{}
+	rt.SetApprover(func(ctx context.Context, text string) (bool, error) {
+		asked++
+		return answer, nil
+	})
+
+	ctx := context.Background()
+	if rt.ApproveKind(ctx, "ssh-agent", "sign?") {
+		t.Fatalf("expected the request to be denied")
+	}
+	answer = true
+	if rt.ApproveKind(ctx, "ssh-agent", "sign?") {
+		t.Fatalf("expected a request right after a denial to be denied")
+	}
+	if asked != 1 {
+		t.Fatalf("expected the user to be asked once, asked %d times", asked)
+	}
+
+	if !rt.ApproveKind(ctx, "git-credential", "fill?") {
+		t.Fatalf("expected other kinds to be asked")
+	}
+	if asked != 2 {
+		t.Fatalf("expected the user to be asked for another kind, asked %d times", asked)
+	}
+}
@@ -16,6 +16,10 @@ type TerminalGuard struct {
 	resizeCh   chan os.Signal
 	resizeDone chan struct{}
 	resizeWg   sync.WaitGroup
+
+	// stdin and promptMu let Confirm ask the user while the terminal is attached to a container
+	stdin    stdinMux
+	promptMu sync.Mutex
 }
 
 // NewTerminalGuard creates an empty guard.
@@ -81,6 +85,12 @@ func (g *TerminalGuard) EnterRawAndWatch(onResize func(width, height uint)) erro
 	return nil
 }
 
+// StdinIsTerminal reports whether stdin is attached to a terminal.
+func (g *TerminalGuard) StdinIsTerminal() bool {
+	_, isTerm := term.GetFdInfo(os.Stdin)
+	return isTerm
+}
+
 func (g *TerminalGuard) Size() (width uint, height uint, err error) {
 	ws, err := term.GetWinsize(g.inFd)
 	if err != nil {
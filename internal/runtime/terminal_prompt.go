@@ -0,0 +1,269 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/ui"
+	"github.com/0xa1bed0/mkenv/internal/utils"
+)
+
+// promptTimeout is how long Confirm and Choose wait for the answer before denying.
+const promptTimeout = time.Minute
+
+// promptTypeahead is how long input is discarded after a question is printed: keys the user was
+// already typing for the container must not answer a question they haven't read.
+const promptTypeahead = 500 * time.Millisecond
+
+// stdinMux reads stdin once and hands the input either to the attached container
+// or, while a prompt is waiting for an answer, to the prompt.
+type stdinMux struct {
+	startOnce sync.Once
+	in        io.Reader // os.Stdin if nil
+
+	mu     sync.Mutex
+	prompt *promptInput
+
+	dataCh  chan []byte
+	errCh   chan error
+	pending []byte
+}
+
+// promptInput receives the input while a prompt is waiting for an answer.
+type promptInput struct {
+	ch   chan []byte
+	done chan struct{}
+}
+
+func (m *stdinMux) start() {
+	m.startOnce.Do(func() {
+		m.dataCh = make(chan []byte)
+		m.errCh = make(chan error, 1)
+		in := m.in
+		if in == nil {
+			in = os.Stdin
+		}
+		go func() {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := in.Read(buf)
+				if n > 0 {
+					chunk := append([]byte(nil), buf[:n]...)
+
+					m.mu.Lock()
+					prompt := m.prompt
+					m.mu.Unlock()
+
+					sent := false
+					if prompt != nil {
+						select {
+						case prompt.ch <- chunk:
+							sent = true
+						case <-prompt.done:
+						}
+					}
+					if !sent {
+						m.dataCh <- chunk
+					}
+				}
+				if err != nil {
+					m.errCh <- err
+					return
+				}
+			}
+		}()
+	})
+}
+
+func (m *stdinMux) Read(p []byte) (int, error) {
+	m.start()
+
+	if len(m.pending) == 0 {
+		select {
+		case chunk := <-m.dataCh:
+			m.pending = chunk
+		case err := <-m.errCh:
+			m.errCh <- err
+			return 0, err
+		}
+	}
+
+	n := copy(p, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+// takeInput routes the input to the returned prompt instead of the container until release is called.
+func (m *stdinMux) takeInput() (prompt *promptInput, release func()) {
+	m.start()
+
+	prompt = &promptInput{ch: make(chan []byte), done: make(chan struct{})}
+	m.mu.Lock()
+	m.prompt = prompt
+	m.mu.Unlock()
+
+	return prompt, func() {
+		m.mu.Lock()
+		if m.prompt == prompt {
+			m.prompt = nil
+		}
+		m.mu.Unlock()
+		close(prompt.done)
+	}
+}
+
+// readLine routes the input to the caller instead of the container until Enter is pressed and returns the line.
+// Input that arrives within typeahead is discarded. Typed characters are echoed to echo, the terminal is in raw mode.
+func (m *stdinMux) readLine(echo io.Writer, typeahead, timeout time.Duration) (string, error) {
+	prompt, release := m.takeInput()
+	defer release()
+
+	deadline := time.After(timeout)
+	draining := time.After(typeahead)
+	ready := typeahead <= 0
+	line := []byte{}
+	for {
+		select {
+		case <-draining:
+			ready = true
+		case chunk := <-prompt.ch:
+			if !ready {
+				continue
+			}
+			for _, b := range chunk {
+				switch {
+				case b == '\r' || b == '\n':
+					fmt.Fprint(echo, "\r\n")
+					return string(line), nil
+				case b == 0x03 || b == 0x04: // Ctrl-C, Ctrl-D
+					fmt.Fprint(echo, "\r\n")
+					return "", errors.New("cancelled")
+				case b == 0x7f || b == 0x08: // backspace
+					if len(line) > 0 {
+						line = line[:len(line)-1]
+						fmt.Fprint(echo, "\b \b")
+					}
+				case b >= 0x20 && b < 0x7f:
+					line = append(line, b)
+					_, _ = echo.Write([]byte{b})
+				}
+			}
+		case <-deadline:
+			fmt.Fprint(echo, "\r\n")
+			return "", errors.New("no answer")
+		}
+	}
+}
+
+// Stdin returns the reader the attached container should consume stdin from,
+// so Confirm can take over the terminal while attached.
+func (g *TerminalGuard) Stdin() io.Reader {
+	return &g.stdin
+}
+
+// Confirm asks the user a yes/no question. While the terminal is attached to a container (raw mode),
+// the question is printed inline and the user types y or yes and presses Enter to approve.
+// Concurrent questions are asked one by one. Any other answer denies.
+func (g *TerminalGuard) Confirm(text string) (bool, error) {
+	g.promptMu.Lock()
+	defer g.promptMu.Unlock()
+
+	g.mu.Lock()
+	raw := g.oldState != nil
+	g.mu.Unlock()
+
+	if !raw {
+		if !g.StdinIsTerminal() {
+			return false, errors.New("stdin is not a terminal")
+		}
+		return logs.PromptConfirm(text)
+	}
+
+	fmt.Fprintf(os.Stdout, "\r\n\x1b[0m\x1b[1;33m[mkenv]\x1b[0m %s\r\n  Type y and press Enter to approve [y/N]: ", utils.StripControlChars(text))
+
+	answer, err := g.stdin.readLine(os.Stdout, promptTypeahead, promptTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "%v, denied\r\n", err)
+		return false, nil
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	approved := answer == "y" || answer == "yes"
+	if approved {
+		fmt.Fprint(os.Stdout, "approved\r\n")
+	} else {
+		fmt.Fprint(os.Stdout, "denied\r\n")
+	}
+
+	return approved, nil
+}
+
+// Choice is an answer the user can pick when asked about a sandbox request.
+// Key is typed (then Enter) to pick it while the terminal is attached to a container.
+type Choice struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Key   string `json:"key"`
+}
+
+// OptionLabel implements ui.SelectOption.
+func (c Choice) OptionLabel() string {
+	return c.Label
+}
+
+// OptionID implements ui.SelectOption.
+func (c Choice) OptionID() string {
+	return c.ID
+}
+
+// Choose asks the user to pick one of choices and returns the ID of the picked one.
+// While the terminal is attached to a container, the user types the Key of the choice and presses Enter.
+// An empty ID is returned if the answer matches no choice or the user doesn't answer in time.
+func (g *TerminalGuard) Choose(text string, choices []Choice) (string, error) {
+	g.promptMu.Lock()
+	defer g.promptMu.Unlock()
+
+	g.mu.Lock()
+	raw := g.oldState != nil
+	g.mu.Unlock()
+
+	if !raw {
+		if !g.StdinIsTerminal() {
+			return "", errors.New("stdin is not a terminal")
+		}
+		choice, err := logs.PromptSelectOne(text, ui.ToSelectOptions(choices))
+		if err != nil {
+			return "", err
+		}
+		return choice.OptionID(), nil
+	}
+
+	keys := make([]string, len(choices))
+	for i, c := range choices {
+		keys[i] = fmt.Sprintf("[%s] %s", c.Key, utils.StripControlChars(c.Label))
+	}
+	fmt.Fprintf(os.Stdout, "\r\n\x1b[0m\x1b[1;33m[mkenv]\x1b[0m %s\r\n  %s\r\n  Type a key and press Enter: ", utils.StripControlChars(text), strings.Join(keys, ", "))
+
+	answer, err := g.stdin.readLine(os.Stdout, promptTypeahead, promptTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "%v, denied\r\n", err)
+		return "", nil
+	}
+
+	answer = strings.TrimSpace(answer)
+	for _, c := range choices {
+		if answer != "" && strings.EqualFold(answer, c.Key) {
+			fmt.Fprintf(os.Stdout, "%s\r\n", c.Label)
+			return c.ID, nil
+		}
+	}
+
+	fmt.Fprint(os.Stdout, "no such choice, denied\r\n")
+	return "", nil
+}
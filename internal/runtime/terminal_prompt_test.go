@@ -0,0 +1,82 @@
+// Tests in this file exercise reading prompt answers from the stdin shared with the attached container.
+package runtime
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+// promptMux returns a mux reading from the returned writer.
+func promptMux(t *testing.T) (*stdinMux, *io.PipeWriter) {
+	t.Helper()
+	r, w := io.Pipe()
+	t.Cleanup(func() { _ = w.Close() })
+	return &stdinMux{in: r}, w
+}
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+// startReadLine starts readLine and waits until the mux routes the input to it.
+func startReadLine(m *stdinMux, typeahead time.Duration) <-chan lineResult {
+	out := make(chan lineResult, 1)
+	go func() {
+		line, err := m.readLine(io.Discard, typeahead, 5*time.Second)
+		out <- lineResult{line, err}
+	}()
+	for {
+		m.mu.Lock()
+		taken := m.prompt != nil
+		m.mu.Unlock()
+		if taken {
+			return out
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReadLineDiscardsTypeahead(t *testing.T) {
+	t.Parallel()
+
+	m, w := promptMux(t)
+	result := startReadLine(m, 100*time.Millisecond)
+
+	// the user was typing "pip install" for the container when the question appeared
+	if _, err := w.Write([]byte("p")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if _, err := w.Write([]byte("x\x7fd\r")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	got := <-result
+	if got.err != nil || got.line != "d" {
+		t.Fatalf("readLine = %q, %v; want \"d\"", got.line, got.err)
+	}
+
+	// the container gets the input again once the prompt is answered
+	go func() { _, _ = w.Write([]byte("ip")) }()
+	buf := make([]byte, 8)
+	n, err := m.Read(buf)
+	if err != nil || string(buf[:n]) != "ip" {
+		t.Fatalf("container read %q, %v; want \"ip\"", buf[:n], err)
+	}
+}
+
+func TestReadLineCancel(t *testing.T) {
+	t.Parallel()
+
+	m, w := promptMux(t)
+	result := startReadLine(m, 0)
+
+	if _, err := w.Write([]byte("y\x03")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := <-result; got.err == nil {
+		t.Fatalf("expected Ctrl-C to cancel the prompt, got %q", got.line)
+	}
+}
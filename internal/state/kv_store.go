@@ -147,3 +147,67 @@ WHERE last_used < ?;
 	n, _ := res.RowsAffected()
 	return n, nil
 }
+
+// ListUnusedBefore returns entries that haven't been used since cutoff.
+// It does not touch returned entries.
+func (s *KVStore) ListUnusedBefore(ctx context.Context, cutoff time.Time) ([]Entry, error) {
+	const q = `
+SELECT key, value, created_at, last_used
+FROM kv_store
+WHERE last_used < ?
+ORDER BY last_used;
+`
+	rows, err := s.db.Raw().QueryContext(ctx, q, cutoff.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("kv_store: list unused: %w", err)
+	}
+	defer rows.Close()
+
+	out := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		var createdAtUnix, lastUsedUnix int64
+		if err := rows.Scan(&entry.Key, &entry.Value, &createdAtUnix, &lastUsedUnix); err != nil {
+			return nil, fmt.Errorf("kv_store: list unused: %w", err)
+		}
+		entry.CreatedAt = time.Unix(createdAtUnix, 0).UTC()
+		entry.LastUsed = time.Unix(lastUsedUnix, 0).UTC()
+		out = append(out, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("kv_store: list unused: %w", err)
+	}
+	return out, nil
+}
+
+// ListPrefix returns entries whose key starts with prefix, ordered by key.
+// It does not touch returned entries.
+func (s *KVStore) ListPrefix(ctx context.Context, prefix KVStoreKey) ([]Entry, error) {
+	const q = `
+SELECT key, value, created_at, last_used
+FROM kv_store
+WHERE substr(key, 1, ?) = ?
+ORDER BY key;
+`
+	rows, err := s.db.Raw().QueryContext(ctx, q, len(prefix), prefix)
+	if err != nil {
+		return nil, fmt.Errorf("kv_store: list prefix: %w", err)
+	}
+	defer rows.Close()
+
+	out := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		var createdAtUnix, lastUsedUnix int64
+		if err := rows.Scan(&entry.Key, &entry.Value, &createdAtUnix, &lastUsedUnix); err != nil {
+			return nil, fmt.Errorf("kv_store: list prefix: %w", err)
+		}
+		entry.CreatedAt = time.Unix(createdAtUnix, 0).UTC()
+		entry.LastUsed = time.Unix(lastUsedUnix, 0).UTC()
+		out = append(out, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("kv_store: list prefix: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/networking/transport"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+)
+
+// Client talks to the project's supervisor. A session attached with Attach
+// is detached when the client is closed.
+type Client struct {
+	conn *protocol.ControlConn
+
+	prompter Prompter
+}
+
+// Prompter asks the user about sensitive sandbox requests. It is implemented by runtime.TerminalGuard.
+type Prompter interface {
+	Confirm(text string) (bool, error)
+	Choose(text string, choices []runtime.Choice) (string, error)
+}
+
+// Dial connects to the supervisor of the project. It fails if no supervisor is running.
+func Dial(ctx context.Context, projectName string) (*Client, error) {
+	socketPath, err := hostappconfig.SupervisorSocketPath(projectName)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := transport.DialUnix(ctx, socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: protocol.NewControlConn(raw)}, nil
+}
+
+func (c *Client) Close() error {
+	if c == nil || c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// HandleApprovals makes the session interactive: prompter asks the user whenever the sandbox
+// requests something sensitive (e.g. SSH signature). Must be called before Attach.
+func (c *Client) HandleApprovals(prompter Prompter) {
+	c.prompter = prompter
+	c.conn.OnMessage(func(env protocol.ControlSignalEnvelope) {
+		if env.Type != "mkenv.supervisor.approve" {
+			return
+		}
+		go c.onApprove(env)
+	})
+}
+
+func (c *Client) onApprove(env protocol.ControlSignalEnvelope) {
+	var request ApprovalRequest
+	if err := protocol.UnpackControlSignalEnvelope(env, &request); err != nil {
+		_ = c.conn.Send(protocol.ControlSignalEnvelope{ID: env.ID, Type: env.Type + ".resp", Err: err.Error()})
+		return
+	}
+
+	var answer ApprovalResponse
+	var err error
+	if len(request.Choices) > 0 {
+		answer.Choice, err = c.prompter.Choose(request.Text, request.Choices)
+	} else {
+		answer.Approved, err = c.prompter.Confirm(request.Text)
+	}
+	if err != nil {
+		_ = c.conn.Send(protocol.ControlSignalEnvelope{ID: env.ID, Type: env.Type + ".resp", Err: err.Error()})
+		return
+	}
+
+	response, err := protocol.PackControlSignalEnvelope(env.ID, env.Type+".resp", &answer)
+	if err != nil {
+		return
+	}
+	_ = c.conn.Send(response)
+}
+
+// Attach registers a new session and returns the container it should use.
+func (c *Client) Attach(ctx context.Context, kind string) (*AttachResponse, error) {
+	var response AttachResponse
+	request := &AttachRequest{Kind: kind, Interactive: c.prompter != nil}
+	if err := call(ctx, c.conn, "mkenv.supervisor.attach", request, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// SetKeepAlive controls whether the container keeps running when no sessions are attached.
+func (c *Client) SetKeepAlive(ctx context.Context, keepAlive bool) error {
+	var response KeepAliveRequest
+	return call(ctx, c.conn, "mkenv.supervisor.keep-alive", &KeepAliveRequest{KeepAlive: keepAlive}, &response)
+}
+
+func call[Req any, Resp any](ctx context.Context, conn *protocol.ControlConn, typ string, request Req, response *Resp) error {
+	req, err := protocol.PackControlSignalEnvelope(protocol.NewID(), typ, request)
+	if err != nil {
+		return err
+	}
+
+	responseEnvelope, err := conn.Call(ctx, req)
+	if err != nil {
+		return err
+	}
+	if responseEnvelope.Err != "" {
+		return errors.New(responseEnvelope.Err)
+	}
+
+	return protocol.UnpackControlSignalEnvelope(responseEnvelope, response)
+}
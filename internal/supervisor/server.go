@@ -0,0 +1,263 @@
+// Package supervisor implements the per-project host process that owns the container's
+// networking (control plane, port forwarders, reverse proxy) and a local unix-socket API
+// which lets any number of CLI sessions attach to and detach from the container.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	hostappconfig "github.com/0xa1bed0/mkenv/internal/apps/mkenv/config"
+	"github.com/0xa1bed0/mkenv/internal/logs"
+	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/networking/transport"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+)
+
+const (
+	// firstAttachTimeout is how long the supervisor waits for the first session
+	// before it considers the container unused.
+	firstAttachTimeout = time.Minute
+	// detachGracePeriod lets a session re-attach (e.g. 'mkenv run' right after exit)
+	// before the container is stopped.
+	detachGracePeriod = 3 * time.Second
+)
+
+type Server struct {
+	projectName string
+	containerID string
+	socketPath  string
+	protocol    *protocol.ControlServerProtocol
+
+	mu        sync.Mutex
+	keepAlive bool
+	sessions  map[string]*Session
+	// changed gets a signal on every session or keep alive change
+	changed chan struct{}
+}
+
+// Listen starts the supervisor API on the project's unix socket.
+// If keepAlive is true the container is kept running when no sessions are attached.
+func Listen(rt *runtime.Runtime, projectName, containerID string, keepAlive bool) (*Server, error) {
+	socketPath, err := hostappconfig.SupervisorSocketPath(projectName)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := transport.ListenUnix(socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		projectName: projectName,
+		containerID: containerID,
+		socketPath:  socketPath,
+		protocol:    protocol.NewControlServerProtocol(rt, ln),
+		keepAlive:   keepAlive,
+		sessions:    map[string]*Session{},
+		changed:     make(chan struct{}, 1),
+	}
+
+	s.protocol.Handle(s.onAttach())
+	s.protocol.Handle(s.onStatus())
+	s.protocol.Handle(s.onKeepAlive())
+
+	rt.GoNamed("Supervisor API", func() {
+		if err := s.protocol.Serve(); err != nil {
+			logs.Errorf("supervisor API stopped: %v", err)
+		}
+	})
+
+	logs.Debugf("supervisor API listens on %s", socketPath)
+
+	return s, nil
+}
+
+func (s *Server) Close() error {
+	err := s.protocol.Close()
+	_ = os.Remove(s.socketPath)
+	return err
+}
+
+// WaitIdle blocks until the container is not needed anymore: keep alive is off and
+// no sessions are attached (for detachGracePeriod after the last one, or firstAttachTimeout
+// if none ever attached). Returns ctx error if ctx is done first.
+func (s *Server) WaitIdle(ctx context.Context) error {
+	timer := time.NewTimer(firstAttachTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.changed:
+			if s.isIdle() {
+				timer.Reset(detachGracePeriod)
+			} else {
+				timer.Stop()
+			}
+		case <-timer.C:
+			if s.isIdle() {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *Server) isIdle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.keepAlive && len(s.sessions) == 0
+}
+
+func (s *Server) notifyChanged() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) addSession(request AttachRequest, conn *protocol.ControlConn) *Session {
+	session := &Session{
+		ID:          protocol.NewID(),
+		Kind:        request.Kind,
+		Interactive: request.Interactive,
+		AttachedAt:  time.Now(),
+		conn:        conn,
+	}
+
+	s.mu.Lock()
+	s.sessions[session.ID] = session
+	s.mu.Unlock()
+
+	logs.Infof("session %s (%s) attached", session.ID, session.Kind)
+	s.notifyChanged()
+
+	return session
+}
+
+func (s *Server) removeSession(id string) {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	logs.Infof("session %s detached", id)
+	s.notifyChanged()
+}
+
+// RequestApproval asks the most recently attached interactive session to approve the request.
+// It implements runtime.Approver.
+func (s *Server) RequestApproval(ctx context.Context, text string) (bool, error) {
+	response, err := s.ask(ctx, &ApprovalRequest{Text: text})
+	if err != nil {
+		return false, err
+	}
+	return response.Approved, nil
+}
+
+// RequestChoice asks the most recently attached interactive session to pick one of choices.
+// It implements runtime.Chooser.
+func (s *Server) RequestChoice(ctx context.Context, text string, choices []runtime.Choice) (string, error) {
+	response, err := s.ask(ctx, &ApprovalRequest{Text: text, Choices: choices})
+	if err != nil {
+		return "", err
+	}
+	return response.Choice, nil
+}
+
+func (s *Server) ask(ctx context.Context, request *ApprovalRequest) (*ApprovalResponse, error) {
+	s.mu.Lock()
+	var latest *Session
+	for _, session := range s.sessions {
+		if !session.Interactive || session.conn == nil {
+			continue
+		}
+		if latest == nil || session.AttachedAt.After(latest.AttachedAt) {
+			latest = session
+		}
+	}
+	s.mu.Unlock()
+
+	if latest == nil {
+		return nil, errors.New("no interactive sessions attached")
+	}
+
+	var response ApprovalResponse
+	if err := call(ctx, latest.conn, "mkenv.supervisor.approve", request, &response); err != nil {
+		return nil, err
+	}
+
+	if len(request.Choices) > 0 {
+		logs.Infof("session %s picked %q for: %s", latest.ID, response.Choice, request.Text)
+	} else {
+		logs.Infof("session %s answered %v to: %s", latest.ID, response.Approved, request.Text)
+	}
+
+	return &response, nil
+}
+
+func (s *Server) onAttach() (string, protocol.ControlCommandHandler) {
+	return "mkenv.supervisor.attach", func(ctx context.Context, req protocol.ControlSignalEnvelope) (any, error) {
+		var request AttachRequest
+		if err := protocol.UnpackControlSignalEnvelope(req, &request); err != nil {
+			return nil, err
+		}
+
+		conn := protocol.ControlConnFromContext(ctx)
+
+		session := s.addSession(request, conn)
+
+		// session lives as long as the client keeps the connection open
+		go func() {
+			if conn != nil {
+				<-conn.Done()
+			}
+			s.removeSession(session.ID)
+		}()
+
+		return &AttachResponse{SessionID: session.ID, ContainerID: s.containerID}, nil
+	}
+}
+
+func (s *Server) onStatus() (string, protocol.ControlCommandHandler) {
+	return "mkenv.supervisor.status", func(ctx context.Context, req protocol.ControlSignalEnvelope) (any, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		sessions := make([]*Session, 0, len(s.sessions))
+		for _, session := range s.sessions {
+			sessions = append(sessions, session)
+		}
+		sort.Slice(sessions, func(i, j int) bool {
+			return sessions[i].AttachedAt.Before(sessions[j].AttachedAt)
+		})
+
+		return &StatusResponse{
+			ProjectName: s.projectName,
+			ContainerID: s.containerID,
+			KeepAlive:   s.keepAlive,
+			Sessions:    sessions,
+		}, nil
+	}
+}
+
+func (s *Server) onKeepAlive() (string, protocol.ControlCommandHandler) {
+	return "mkenv.supervisor.keep-alive", func(ctx context.Context, req protocol.ControlSignalEnvelope) (any, error) {
+		var request KeepAliveRequest
+		if err := protocol.UnpackControlSignalEnvelope(req, &request); err != nil {
+			return nil, err
+		}
+
+		s.mu.Lock()
+		s.keepAlive = request.KeepAlive
+		s.mu.Unlock()
+
+		s.notifyChanged()
+
+		return &request, nil
+	}
+}
@@ -0,0 +1,55 @@
+package supervisor
+
+import (
+	"time"
+
+	"github.com/0xa1bed0/mkenv/internal/networking/protocol"
+	"github.com/0xa1bed0/mkenv/internal/runtime"
+)
+
+const (
+	SessionKindShell = "shell"
+	SessionKindExec  = "exec"
+)
+
+type AttachRequest struct {
+	Kind string `json:"kind"`
+	// Interactive sessions can be asked to approve sensitive sandbox requests
+	Interactive bool `json:"interactive"`
+}
+
+type AttachResponse struct {
+	SessionID   string `json:"session_id"`
+	ContainerID string `json:"container_id"`
+}
+
+type KeepAliveRequest struct {
+	KeepAlive bool `json:"keep_alive"`
+}
+
+type Session struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Interactive bool      `json:"interactive"`
+	AttachedAt  time.Time `json:"attached_at"`
+
+	conn *protocol.ControlConn
+}
+
+// ApprovalRequest is a yes/no question, or a pick-one question if it has Choices.
+type ApprovalRequest struct {
+	Text    string           `json:"text"`
+	Choices []runtime.Choice `json:"choices,omitempty"`
+}
+
+type ApprovalResponse struct {
+	Approved bool   `json:"approved"`
+	Choice   string `json:"choice,omitempty"` // ID of the picked choice
+}
+
+type StatusResponse struct {
+	ProjectName string     `json:"project_name"`
+	ContainerID string     `json:"container_id"`
+	KeepAlive   bool       `json:"keep_alive"`
+	Sessions    []*Session `json:"sessions"`
+}
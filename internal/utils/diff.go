@@ -0,0 +1,43 @@
+package utils
+
+import "strings"
+
+// LineDiff returns a line by line diff of a and b: removed lines are prefixed with "- ",
+// added ones with "+ " and unchanged ones with two spaces.
+func LineDiff(a, b string) string {
+	oldLines := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	newLines := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			sb.WriteString("  " + oldLines[i] + "\n")
+			i++
+			j++
+		case i < len(oldLines) && (j == len(newLines) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + oldLines[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + newLines[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,25 @@
+// Tests in this file exercise the line diff shown when a .mkenv file changes.
+package utils
+
+import "testing"
+
+func TestLineDiff(t *testing.T) {
+	t.Parallel()
+
+	oldContent := "{\n  \"enabled_bricks\": [\"go\"],\n  \"extra_pkgs\": []\n}\n"
+	newContent := "{\n  \"enabled_bricks\": [\"go\"],\n  \"extra_pkgs\": [\"curl\"],\n  \"volumes\": [\"/:/host\"]\n}\n"
+
+	want := "  {\n" +
+		"    \"enabled_bricks\": [\"go\"],\n" +
+		"-   \"extra_pkgs\": []\n" +
+		"+   \"extra_pkgs\": [\"curl\"],\n" +
+		"+   \"volumes\": [\"/:/host\"]\n" +
+		"  }\n"
+	if got := LineDiff(oldContent, newContent); got != want {
+		t.Fatalf("LineDiff =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := LineDiff("a\nb\n", "a\nb\n"); got != "  a\n  b\n" {
+		t.Fatalf("LineDiff of equal content = %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JSONKeyLine returns the 1-based line of the object key at path in the JSON document data,
+// e.g. JSONKeyLine(data, "bricks_config", "nodejs", "version"). It returns 0 if the key is not found.
+func JSONKeyLine(data []byte, path ...string) int {
+	if len(path) == 0 {
+		return 0
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	offset, ok := findJSONKey(dec, path)
+	if !ok {
+		return 0
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// findJSONKey reads the value at the decoder position and returns the offset right after the key at path.
+func findJSONKey(dec *json.Decoder, path []string) (int64, bool) {
+	tok, err := dec.Token()
+	if err != nil || tok != json.Delim('{') {
+		if tok == json.Delim('[') {
+			skipJSONContainer(dec)
+		}
+		return 0, false
+	}
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return 0, false
+		}
+		key, _ := keyTok.(string)
+		if key != path[0] {
+			if !skipJSONValue(dec) {
+				return 0, false
+			}
+			continue
+		}
+		if len(path) == 1 {
+			return dec.InputOffset(), true
+		}
+		return findJSONKey(dec, path[1:])
+	}
+	return 0, false
+}
+
+// skipJSONValue reads the next value, nested ones included.
+func skipJSONValue(dec *json.Decoder) bool {
+	tok, err := dec.Token()
+	if err != nil {
+		return false
+	}
+	if tok == json.Delim('{') || tok == json.Delim('[') {
+		return skipJSONContainer(dec)
+	}
+	return true
+}
+
+// skipJSONContainer reads up to the end of the object or array whose opening delimiter was read.
+func skipJSONContainer(dec *json.Decoder) bool {
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+// Tests in this file exercise locating keys of JSON documents for error messages.
+package utils
+
+import "testing"
+
+func TestJSONKeyLine(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`{
+  "enabled_bricks": ["nodejs", {"version": "x"}],
+  "bricks_config": {
+    "golang": {"version": "1.22"},
+    "nodejs": {
+      "version": "20",
+      "node_verison": "18"
+    }
+  }
+}`)
+
+	cases := []struct {
+		path []string
+		want int
+	}{
+		{[]string{"bricks_config", "nodejs", "node_verison"}, 7},
+		{[]string{"bricks_config", "golang", "version"}, 4},
+		{[]string{"bricks_config", "nodejs"}, 5},
+		{[]string{"bricks_config", "python"}, 0},
+		{[]string{"enabled_bricks", "version"}, 0},
+	}
+	for _, c := range cases {
+		if got := JSONKeyLine(data, c.path...); got != c.want {
+			t.Fatalf("JSONKeyLine(%v) = %d, want %d", c.path, got, c.want)
+		}
+	}
+}
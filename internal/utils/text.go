@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// StripControlChars removes control characters (ESC, CSI, BEL, newlines...) and bidi overrides from s,
+// so text that comes from the sandbox can't rewrite or hide what is printed on the host terminal.
+func StripControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(s, ""))
+}
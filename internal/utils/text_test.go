@@ -0,0 +1,25 @@
+// Tests in this file exercise cleaning sandbox provided text before it is printed on the host terminal.
+package utils
+
+import "testing"
+
+func TestStripControlChars(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in, want string
+	}{
+		{"python3", "python3"},
+		{"curl\x1b[2K\x1b[1Ggit", "curl[2K[1Ggit"},
+		{"a\x9b31mb", "a31mb"},
+		{"node\r\nApproved", "nodeApproved"},
+		{"evil\u202etxt.exe", "eviltxt.exe"},
+		{"bad\xffutf8", "badutf8"},
+		{"héllo wörld", "héllo wörld"},
+	}
+	for _, c := range cases {
+		if got := StripControlChars(c.in); got != c.want {
+			t.Fatalf("StripControlChars(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -29,8 +29,9 @@ const (
 	RequestTimeout = 5 * time.Second
 
 	// Cache keys for KVStore
-	cacheKeyStable = state.KVStoreKey("versioncheck:stable")
-	cacheKeyDev    = state.KVStoreKey("versioncheck:dev")
+	cacheKeyPrefix = state.KVStoreKey("versioncheck:")
+	cacheKeyStable = cacheKeyPrefix + "stable"
+	cacheKeyDev    = cacheKeyPrefix + "dev"
 )
 
 // InstallMethod represents how mkenv was installed.
@@ -80,6 +81,11 @@ type Result struct {
 	InstallMethod   InstallMethod
 }
 
+// IsCacheKey returns true if the KV store key belongs to the version check cache.
+func IsCacheKey(key state.KVStoreKey) bool {
+	return strings.HasPrefix(string(key), string(cacheKeyPrefix))
+}
+
 // Check checks for a new version of mkenv.
 // Returns nil if the current version is "local" (dev build) or if the check fails silently.
 func Check(ctx context.Context) *Result {
@@ -329,22 +335,23 @@ func detectInstallMethod() InstallMethod {
 
 // PrintUpdateBanner prints an update notification banner if an update is available.
 // This should be called after command execution to avoid interrupting the main flow.
+// The banner goes to stderr so it never mixes with machine-readable command output.
 func PrintUpdateBanner(result *Result) {
 	if result == nil || !result.UpdateAvailable {
 		return
 	}
 
-	fmt.Printf("\n")
-	fmt.Printf("  A new version of mkenv is available: %s -> %s\n", result.CurrentVersion, result.LatestVersion)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "  A new version of mkenv is available: %s -> %s\n", result.CurrentVersion, result.LatestVersion)
 
 	switch result.InstallMethod {
 	case InstallMethodHomebrew:
-		fmt.Printf("  Run: brew upgrade mkenv\n")
+		fmt.Fprintf(os.Stderr, "  Run: brew upgrade mkenv\n")
 	case InstallMethodCompiledBuild:
-		fmt.Printf("  Pull latest changes and rebuild: git pull && make\n")
+		fmt.Fprintf(os.Stderr, "  Pull latest changes and rebuild: git pull && make\n")
 	case InstallMethodDevBuild, InstallMethodDownload, InstallMethodUnknown:
-		fmt.Printf("  Download: %s\n", result.UpdateURL)
+		fmt.Fprintf(os.Stderr, "  Download: %s\n", result.UpdateURL)
 	}
 
-	fmt.Printf("\n")
+	fmt.Fprintf(os.Stderr, "\n")
 }